@@ -0,0 +1,86 @@
+package tdigest
+
+import "math"
+
+// RankErrorBound describes how precisely a digest can estimate a quantile
+// given its current centroids.
+type RankErrorBound struct {
+	// Quantile is the quantile the bound applies to.
+	Quantile float64
+
+	// RankError is the weight of the centroid containing the rank of
+	// Quantile and of the two centroids on either side of it, as a fraction
+	// of Count. The estimate is interpolated between neighbors of that
+	// centroid whose samples overlap, so it may be off by that many ranks.
+	RankError float64
+
+	// ValueError is the distance between the means of the outermost of
+	// those centroids.
+	ValueError float64
+}
+
+// AccuracyProfile returns bounds on the error of the quantile estimates of
+// the digest at every quantile of qs, derived from the current layout of
+// its centroids without knowledge of the actual samples.
+//
+// The bounds hold for samples drawn from a continuous distribution. They
+// grow when weight concentrates in a few centroids, which makes them useful
+// to detect a digest whose accuracy has degraded. Fields other than Quantile
+// are NaN for an empty digest. Values of qs must be between 0 and 1
+// (inclusive), will panic otherwise.
+func (t *TDigest) AccuracyProfile(qs []float64) []RankErrorBound {
+	for _, q := range qs {
+		if q < 0 || q > 1 {
+			panic("q must be between 0 and 1 (inclusive)")
+		}
+	}
+	t.checkRead()
+
+	bounds := make([]RankErrorBound, len(qs))
+	for i, q := range qs {
+		bounds[i] = RankErrorBound{Quantile: q, RankError: math.NaN(), ValueError: math.NaN()}
+		if t.summary.Len() == 0 {
+			continue
+		}
+
+		index, _ := t.centroidAt(q)
+		lo, hi := index-2, index+2
+		if lo < 0 {
+			lo = 0
+		}
+		if hi >= t.summary.Len() {
+			hi = t.summary.Len() - 1
+		}
+
+		ranks := t.summary.HeadSum(hi+1) - t.summary.HeadSum(lo)
+		bounds[i].RankError = ranks / float64(t.count)
+		bounds[i].ValueError = t.summary.Mean(hi) - t.summary.Mean(lo)
+	}
+	return bounds
+}
+
+// QuantileError returns a bound on the error of Quantile(q) in ranks, as a
+// fraction of Count: half the weight of the centroid containing the rank of
+// q, besides the sample at its center. The samples of that centroid are
+// only known through their mean, so the estimate may be off by as many
+// ranks as they span on either side of it, although it is usually much
+// closer. It is 0 for a centroid of a single sample and largest in the
+// middle of the distribution, where the centroids are the heaviest.
+// AccuracyProfile gives a more conservative bound which also accounts for
+// the neighbors of that centroid.
+//
+// It returns NaN for an empty digest. Values of q must be between 0 and 1
+// (inclusive), will panic otherwise.
+func (t *TDigest) QuantileError(q float64) float64 {
+	if q < 0 || q > 1 {
+		panic("q must be between 0 and 1 (inclusive)")
+	}
+	t.checkRead()
+
+	if t.summary.Len() == 0 {
+		return math.NaN()
+	}
+
+	index, _ := t.centroidAt(q)
+	return float64(t.summary.Count(index)-1) / 2 / float64(t.count)
+}
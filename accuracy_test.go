@@ -0,0 +1,128 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestAccuracyProfile(t *testing.T) {
+	qs := make([]float64, 1001)
+	for i := range qs {
+		qs[i] = float64(i) / 1000
+	}
+
+	streams := map[string]func() float64{
+		"uniform":     rand.Float64,
+		"normal":      rand.NormFloat64,
+		"exponential": rand.ExpFloat64,
+	}
+
+	for name, next := range streams {
+		for _, compression := range []float64{10, 100} {
+			tdigest := New(compression)
+			data := make([]float64, 100000)
+			for i := range data {
+				data[i] = next()
+				assertNoError(t, tdigest.Add(data[i]))
+			}
+			sort.Float64s(data)
+			n := float64(len(data))
+
+			for _, bound := range tdigest.AccuracyProfile(qs) {
+				q := bound.Quantile
+				estimate := tdigest.Quantile(q)
+
+				// the estimate has ranks [lo, hi] among the samples
+				lo := float64(sort.SearchFloat64s(data, estimate)) / n
+				hi := float64(sort.Search(len(data), func(i int) bool { return data[i] > estimate })) / n
+				rankErr := math.Max(0, math.Max(lo-q, q-hi))
+				if rankErr > bound.RankError {
+					t.Errorf("%s c=%v: rank error %.5f at q=%.3f exceeds bound %.5f",
+						name, compression, rankErr, q, bound.RankError)
+				}
+
+				if valueErr := math.Abs(estimate - quantile(q, data)); valueErr > bound.ValueError {
+					t.Errorf("%s c=%v: value error %.5f at q=%.3f exceeds bound %.5f",
+						name, compression, valueErr, q, bound.ValueError)
+				}
+			}
+		}
+	}
+}
+
+func TestAccuracyProfileDegrades(t *testing.T) {
+	fine, coarse := New(100), New(5)
+	for i := 0; i < 100000; i++ {
+		x := rand.Float64()
+		assertNoError(t, fine.Add(x))
+		assertNoError(t, coarse.Add(x))
+	}
+
+	qs := []float64{0.01, 0.5, 0.99}
+	fineBounds, coarseBounds := fine.AccuracyProfile(qs), coarse.AccuracyProfile(qs)
+	for i := range qs {
+		if coarseBounds[i].RankError <= fineBounds[i].RankError {
+			t.Errorf("q=%.2f: expected a larger bound at lower compression, got %.5f and %.5f",
+				qs[i], coarseBounds[i].RankError, fineBounds[i].RankError)
+		}
+	}
+
+	empty := New(100).AccuracyProfile(qs)
+	if !math.IsNaN(empty[0].RankError) || !math.IsNaN(empty[0].ValueError) {
+		t.Errorf("Expected NaN bounds for an empty digest, got %+v", empty[0])
+	}
+
+	shouldPanic(func() { fine.AccuracyProfile([]float64{1.5}) }, t, "An invalid quantile should panic")
+}
+
+func TestQuantileError(t *testing.T) {
+	if !math.IsNaN(New(100).QuantileError(0.5)) {
+		t.Errorf("Expected NaN for an empty digest")
+	}
+
+	few := New(100)
+	for i := 0; i < 10; i++ {
+		assertNoError(t, few.Add(float64(i)))
+	}
+	for _, q := range []float64{0, 0.5, 1} {
+		if got := few.QuantileError(q); got != 0 {
+			t.Errorf("Expected no error among single samples at q=%v, got %v", q, got)
+		}
+	}
+
+	tdigest := New(100)
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rand.Float64()
+		assertNoError(t, tdigest.Add(data[i]))
+	}
+	sort.Float64s(data)
+	n := float64(len(data))
+
+	if tail, middle := tdigest.QuantileError(0.001), tdigest.QuantileError(0.5); !(tail < middle) {
+		t.Errorf("Expected a smaller error in the tail, got %v and %v", tail, middle)
+	}
+
+	// the observed rank errors are typically within the estimate
+	var observed, estimated float64
+	for i := 1; i < 1000; i++ {
+		q := float64(i) / 1000
+		rank := float64(sort.SearchFloat64s(data, tdigest.Quantile(q))) / n
+		observed += math.Abs(rank - q)
+		estimated += tdigest.QuantileError(q)
+	}
+	if observed > estimated {
+		t.Errorf("observed rank errors add up to %v, estimated %v", observed, estimated)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic for an invalid quantile")
+			}
+		}()
+		tdigest.QuantileError(1.5)
+	}()
+}
@@ -0,0 +1,426 @@
+package tdigest
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// AddBatch adds every value of values, with the same result as adding them
+// one at a time with Add up to the accuracy of the digest, but faster. A
+// sorted copy of values is merged with the centroids in a single pass,
+// which also clusters them when the digest would otherwise need compressing.
+// Digests created with WithSmallFootprint or WithIncrementalCompression, or
+// compacted by a Concurrent, add the values one at a time instead.
+//
+// It returns ErrInvalidValue and adds nothing if some value is rejected by
+// Add.
+func (t *TDigest) AddBatch(values []float64) (err error) {
+	for _, value := range values {
+		if err := t.checkValue(value); err != nil {
+			return err
+		}
+	}
+
+	t.beginWrite()
+	err = t.addSorted(values, 0)
+	t.endWrite()
+	t.checkThresholds()
+	return err
+}
+
+// AddSorted is like AddBatch for values already sorted in increasing or
+// decreasing order, which are merged with the centroids without sorting
+// them first.
+//
+// It returns ErrInvalidValue if some value is rejected by Add, and an error
+// if values are not sorted, adding nothing in either case.
+func (t *TDigest) AddSorted(values []float64) (err error) {
+	// order is 1 or -1 once the values are seen increasing or decreasing
+	var order int
+	for i, value := range values {
+		if err := t.checkValue(value); err != nil {
+			return err
+		}
+		if i == 0 || values[i-1] == value {
+			continue
+		}
+		o := 1
+		if value < values[i-1] {
+			o = -1
+		}
+		if order != 0 && o != order {
+			return errors.New("values are not sorted")
+		}
+		order = o
+	}
+	if order == 0 {
+		order = 1
+	}
+
+	t.beginWrite()
+	err = t.addSorted(values, order)
+	t.endWrite()
+	t.checkThresholds()
+	return err
+}
+
+// NewFromValues creates a digest configured by compression and opts holding
+// every value of values. Like AddBatch it clusters a sorted copy of values
+// in a single pass, which is much faster than adding them one at a time and
+// at least as accurate. Min and Max are the exact extremes of values, and an
+// empty values gives an empty digest.
+//
+// It returns ErrInvalidValue if some value is rejected by Add.
+func NewFromValues(compression float64, values []float64, opts ...Option) (*TDigest, error) {
+	t := New(compression, opts...)
+	if err := t.AddBatch(values); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// addSorted adds values, none of which is rejected by checkValue, at once.
+// order is 1 or -1 if they are sorted in increasing or decreasing order,
+// and 0 if they must be sorted first.
+func (t *TDigest) addSorted(values []float64, order int) error {
+	if t.addsEach() {
+		_, err := t.addEach(values)
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := append([]float64(nil), values...)
+	if order < 0 {
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	}
+	if t.step > 0 {
+		for i, value := range sorted {
+			sorted[i] = t.quantize(value)
+		}
+	}
+	if order == 0 {
+		radixSort(sorted)
+	}
+
+	t.combine(momentsOf(sorted))
+	if t.tails != nil {
+		for _, value := range sorted {
+			t.tails.add(value, 1)
+		}
+	}
+	t.observe(sorted[0], sorted[len(sorted)-1])
+
+	t.mergeSorted(sorted)
+	return nil
+}
+
+// mergeSorted merges sorted, in increasing order, with the centroids in a
+// single pass, the moments of the digest already counting its values. Runs
+// of equal values make a single centroid, and when the result would have
+// more centroids than the compression trigger every centroid absorbs the
+// next one while mergeable, as in cluster, so that the digest does not need
+// compressing afterwards.
+func (t *TDigest) mergeSorted(sorted []float64) {
+	s := t.summary
+	size := s.Len() + len(sorted)
+	m := t.newSortedMerge(size, float64(size) > t.compressionTrigger())
+
+	i := 0
+	for j := 0; j < len(sorted); {
+		for ; i < s.Len() && s.means[i] <= sorted[j]; i++ {
+			m.push(s.Mean(i), s.Count(i), s.M2(i))
+		}
+
+		run := j + 1
+		for run < len(sorted) && sorted[run] == sorted[j] && uint64(run-j) < math.MaxUint32 {
+			run++
+		}
+		m.push(sorted[j], uint32(run-j), 0)
+		j = run
+	}
+	for ; i < s.Len(); i++ {
+		m.push(s.Mean(i), s.Count(i), s.M2(i))
+	}
+	m.finish()
+}
+
+// mergeSortedSummary merges the centroids of data with those of the digest
+// in a single pass, the moments of the digest already counting them, and
+// clusters the result as in cluster. The centroids of the digest come first
+// among equal means. data is left untouched, and may be the summary of the
+// digest itself.
+func (t *TDigest) mergeSortedSummary(data *summary) {
+	s := t.summary
+	m := t.newSortedMerge(s.Len()+data.Len(), true)
+
+	i, j := 0, 0
+	for i < s.Len() || j < data.Len() {
+		if j == data.Len() || (i < s.Len() && s.means[i] <= data.means[j]) {
+			m.push(s.Mean(i), s.Count(i), s.M2(i))
+			i++
+		} else {
+			m.push(data.Mean(j), data.Count(j), data.M2(j))
+			j++
+		}
+	}
+	m.finish()
+}
+
+// sortedMerge builds the centroids of a digest from centroids pushed in
+// increasing order of mean. When clustered, every centroid absorbs the next
+// one while mergeable.
+type sortedMerge struct {
+	t         *TDigest
+	clustered bool
+
+	means  []float64
+	counts []uint32
+	m2     []float64
+
+	// lo is the rank of the first sample of the last centroid
+	lo float64
+}
+
+// newSortedMerge returns a sortedMerge with room for size centroids.
+func (t *TDigest) newSortedMerge(size int, clustered bool) *sortedMerge {
+	m := &sortedMerge{
+		t:         t,
+		clustered: clustered,
+		means:     make([]float64, 0, size),
+		counts:    make([]uint32, 0, size),
+	}
+	if t.summary.m2 != nil {
+		m.m2 = make([]float64, 0, size)
+	}
+	return m
+}
+
+func (m *sortedMerge) push(mean float64, count uint32, dev float64) {
+	n := len(m.means) - 1
+	if n >= 0 && m.clustered {
+		c, ci := float64(m.counts[n]), float64(count)
+		if m.t.mergeable(m.lo, c+ci) {
+			if m.m2 != nil {
+				d := mean - m.means[n]
+				m.m2[n] += dev + d*d*c*ci/(c+ci)
+			}
+			m.means[n] = weightedAverage(m.means[n], c, mean, ci)
+			m.counts[n] += count
+			return
+		}
+	}
+	if n >= 0 {
+		m.lo += float64(m.counts[n])
+	}
+	m.means, m.counts = append(m.means, mean), append(m.counts, count)
+	if m.m2 != nil {
+		m.m2 = append(m.m2, dev)
+	}
+}
+
+// finish replaces the centroids of the digest with the merged ones.
+func (m *sortedMerge) finish() {
+	s := m.t.summary
+	s.means, s.counts, s.m2 = m.means, m.counts, m.m2
+	s.rebuildTree(0)
+	m.t.sweeping = false
+}
+
+// addsEach reports whether the digest adds batches of values one at a time,
+// as digests created with WithSmallFootprint or WithIncrementalCompression,
+// or compacted by a Concurrent, do to keep their bounds.
+func (t *TDigest) addsEach() bool {
+	return t.maxCentroids > 0 || t.budget > 0 || t.background
+}
+
+// radixSort sorts values in increasing order, as sort.Float64s does for
+// values without NaN, in a fixed number of linear passes over the bits of
+// the values, which is faster for large slices.
+func radixSort(values []float64) {
+	if len(values) < 256 {
+		sort.Float64s(values)
+		return
+	}
+
+	// flipping the sign bit of positive values and every bit of negative
+	// ones makes the order of the bits that of the values
+	keys, scratch := make([]uint64, len(values)), make([]uint64, len(values))
+	var counts [8][256]int
+	for i, value := range values {
+		k := math.Float64bits(value)
+		if k>>63 == 1 {
+			k = ^k
+		} else {
+			k |= 1 << 63
+		}
+		keys[i] = k
+		for d := range counts {
+			counts[d][byte(k>>(8*d))]++
+		}
+	}
+
+	for d := range counts {
+		// a digit shared by every key does not change the order
+		if counts[d][byte(keys[0]>>(8*d))] == len(keys) {
+			continue
+		}
+		var offset int
+		for b, c := range counts[d] {
+			counts[d][b], offset = offset, offset+c
+		}
+		for _, k := range keys {
+			b := byte(k >> (8 * d))
+			scratch[counts[d][b]] = k
+			counts[d][b]++
+		}
+		keys, scratch = scratch, keys
+	}
+
+	for i, k := range keys {
+		if k>>63 == 1 {
+			k &^= 1 << 63
+		} else {
+			k = ^k
+		}
+		values[i] = math.Float64frombits(k)
+	}
+}
+
+// Quantiles returns the estimates of Quantile for every value of qs, in the
+// same order. The quantiles are computed in a single pass over the
+// centroids, in increasing order, so asking for several of them at once is
+// cheaper than calling Quantile for each and gives the same results.
+//
+// Values of qs must be between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) Quantiles(qs []float64) []float64 {
+	for _, q := range qs {
+		if q < 0 || q > 1 {
+			panic("q must be between 0 and 1 (inclusive)")
+		}
+	}
+	t.checkRead()
+
+	out := make([]float64, len(qs))
+	t.quantilesInOrder(qs, sortedOrder(qs), true, out)
+	return out
+}
+
+// quantilesInOrder sets out[i] to the quantile qs[i] for the indexes i of
+// order, which must be in increasing order of qs[i] with NaN values first.
+// The quantiles are subject to WithMinSamples if gated is set.
+func (t *TDigest) quantilesInOrder(qs []float64, order []int, gated bool, out []float64) {
+	// next and total follow FloorSum for the increasing indexes
+	var next int
+	var total float64
+	for _, i := range order {
+		q := qs[i]
+		switch {
+		case gated && !t.enoughSamples(q, t.minSamples):
+			out[i] = math.NaN()
+		case t.summary.Len() < 2 || t.summary.m2 != nil || t.interpolation != InterpolationLinear || math.IsNaN(q):
+			out[i] = t.quantile(q)
+		default:
+			index := q * float64(t.count-1)
+			for next+1 < t.summary.Len() && total+float64(t.summary.Count(next)) <= index {
+				total += float64(t.summary.Count(next))
+				next++
+			}
+			out[i] = t.tails.stitch(q, t.count, t.pin(q, t.quantileFrom(index, next, total)))
+		}
+	}
+}
+
+// QuantilePoint is a point of the quantile curve of a digest.
+type QuantilePoint struct {
+	Q     float64
+	Value float64
+}
+
+// ExportQuantileTable returns the quantiles of the digest at n evenly spaced
+// values of q from 0 to 1, which can be used to plot its quantile curve or
+// to choose histogram buckets. They are computed in a single pass over the
+// centroids, the first and last are the exact Min and Max, and the values
+// never decrease. Unlike Quantile, they are not subject to WithMinSamples.
+//
+// It returns nil for an empty digest, and panics if n is less than 2.
+func (t *TDigest) ExportQuantileTable(n int) []QuantilePoint {
+	if n < 2 {
+		panic("n must be at least 2")
+	}
+	t.checkRead()
+
+	if t.summary.Len() == 0 {
+		return nil
+	}
+
+	qs, values := t.evenQuantiles(n, false)
+	table := make([]QuantilePoint, n)
+	for i := range table {
+		table[i] = QuantilePoint{Q: qs[i], Value: values[i]}
+	}
+	return table
+}
+
+// evenQuantiles returns n evenly spaced values of q from 0 to 1 and the
+// quantiles of the digest at them, computed in a single pass and subject to
+// WithMinSamples if gated is set.
+func (t *TDigest) evenQuantiles(n int, gated bool) (qs, values []float64) {
+	qs, order := make([]float64, n), make([]int, n)
+	for i := range qs {
+		qs[i], order[i] = float64(i)/float64(n-1), i
+	}
+
+	values = make([]float64, n)
+	t.quantilesInOrder(qs, order, gated, values)
+	return qs, values
+}
+
+// sortedOrder returns the indexes of values in increasing order of value,
+// NaN values first.
+func sortedOrder(values []float64) []int {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := values[order[i]], values[order[j]]
+		return a < b || (math.IsNaN(a) && !math.IsNaN(b))
+	})
+	return order
+}
+
+// CDFs returns the estimates of CDF for every value of xs, in the same
+// order. When xs is sorted in increasing order they are computed in a single
+// pass over the centroids, which is cheaper than calling CDF for each, and
+// otherwise they are computed one at a time. The results are the same
+// either way.
+func (t *TDigest) CDFs(xs []float64) []float64 {
+	t.checkRead()
+
+	out := make([]float64, len(xs))
+	sorted := t.summary.Len() > 0 && t.summary.m2 == nil
+	for i := 1; i < len(xs) && sorted; i++ {
+		sorted = xs[i-1] <= xs[i]
+	}
+
+	w := cdfWalk{t: t}
+	for i, x := range xs {
+		var c float64
+		if sorted {
+			c = w.cdf(x)
+		} else {
+			c = t.cdf(x)
+		}
+
+		if !math.IsInf(x, 0) && !t.enoughSamples(c, t.minSamples) {
+			c = math.NaN()
+		}
+		out[i] = c
+	}
+	return out
+}
@@ -0,0 +1,461 @@
+package tdigest
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func assertSameFloats(t *testing.T, name string, got, expected []float64) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("%s: got %d values, expected %d", name, len(got), len(expected))
+	}
+	for i := range got {
+		if got[i] != expected[i] && !(math.IsNaN(got[i]) && math.IsNaN(expected[i])) {
+			t.Errorf("%s: got %v at %d, expected %v", name, got[i], i, expected[i])
+		}
+	}
+}
+
+func nans(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	return values
+}
+
+func TestQuantiles(t *testing.T) {
+	qs := []float64{0.99, 0.5, 0, 1, 0.5, 0.999, 0.001, 0.25, 0.9, 0.5}
+	for i := 0; i < 100; i++ {
+		qs = append(qs, rand.Float64())
+	}
+
+	for name, opts := range optionVariants(map[string][]Option{
+		"minSamples": {WithMinSamples(20)},
+	}) {
+		tdigest := New(100, opts...)
+		assertSameFloats(t, name+" empty", tdigest.Quantiles(qs), nans(len(qs)))
+		for _, n := range []int{1, 2, 3, 100, 100000} {
+			for tdigest.Count() < uint64(n) {
+				assertNoError(t, tdigest.Add(rand.ExpFloat64()))
+			}
+
+			expected := make([]float64, len(qs))
+			for i, q := range qs {
+				expected[i] = tdigest.Quantile(q)
+			}
+			assertSameFloats(t, name, tdigest.Quantiles(qs), expected)
+		}
+	}
+
+	if got := New(100).Quantiles(nil); len(got) != 0 {
+		t.Errorf("Expected no quantiles, got %v", got)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic for an invalid quantile")
+			}
+		}()
+		New(100).Quantiles([]float64{0.5, 1.5})
+	}()
+}
+
+var benchmarkQuantiles = []float64{0.5, 0.9, 0.95, 0.99, 0.999}
+
+func benchmarkDigest(b *testing.B) *TDigest {
+	tdigest := New(100)
+	for i := 0; i < 100000; i++ {
+		if err := tdigest.Add(rand.NormFloat64()); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return tdigest
+}
+
+func BenchmarkQuantile5(b *testing.B) {
+	tdigest := benchmarkDigest(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, q := range benchmarkQuantiles {
+			tdigest.Quantile(q)
+		}
+	}
+}
+
+func BenchmarkQuantiles5(b *testing.B) {
+	tdigest := benchmarkDigest(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tdigest.Quantiles(benchmarkQuantiles)
+	}
+}
+
+func TestCDFs(t *testing.T) {
+	sorted := []float64{math.Inf(-1), -5, -1, -0.5, 0, 0, 0.1, 0.5, 1, 1, 2, 5, math.Inf(1)}
+	unsorted := []float64{0.5, -1, 2, 0, math.NaN(), 0, 5, -5}
+
+	for name, opts := range optionVariants(map[string][]Option{
+		"minSamples": {WithMinSamples(20)},
+	}) {
+		tdigest := New(100, opts...)
+		empty := nans(len(sorted))
+		empty[0], empty[len(empty)-1] = 0, 1
+		assertSameFloats(t, name+" empty", tdigest.CDFs(sorted), empty)
+		for _, n := range []int{1, 2, 3, 100, 100000} {
+			for tdigest.Count() < uint64(n) {
+				assertNoError(t, tdigest.Add(rand.NormFloat64()))
+			}
+
+			for _, xs := range [][]float64{sorted, unsorted} {
+				expected := make([]float64, len(xs))
+				for i, x := range xs {
+					expected[i] = tdigest.CDF(x)
+				}
+				assertSameFloats(t, name, tdigest.CDFs(xs), expected)
+			}
+		}
+	}
+}
+
+var benchmarkThresholds = []float64{-2, -1, 0, 0.5, 1, 1.5, 2, 3}
+
+func BenchmarkCDF8(b *testing.B) {
+	tdigest := benchmarkDigest(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, x := range benchmarkThresholds {
+			tdigest.CDF(x)
+		}
+	}
+}
+
+func BenchmarkCDFs8(b *testing.B) {
+	tdigest := benchmarkDigest(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tdigest.CDFs(benchmarkThresholds)
+	}
+}
+
+func BenchmarkCDF4(b *testing.B) {
+	tdigest := benchmarkDigest(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, x := range benchmarkThresholds[:4] {
+			tdigest.CDF(x)
+		}
+	}
+}
+
+func BenchmarkCDFs4(b *testing.B) {
+	tdigest := benchmarkDigest(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tdigest.CDFs(benchmarkThresholds[:4])
+	}
+}
+
+func TestExportQuantileTable(t *testing.T) {
+	if table := New(100).ExportQuantileTable(10); table != nil {
+		t.Errorf("Expected nil for an empty digest, got %v", table)
+	}
+	shouldPanic(func() { New(100).ExportQuantileTable(1) }, t, "ExportQuantileTable(1) should panic")
+
+	for name, opts := range optionVariants(map[string][]Option{
+		"minSamples": {WithMinSamples(20)},
+		"midpoint":   {WithInterpolation(InterpolationMidpoint)},
+		"tails":      {WithExactTails(10)},
+	}) {
+		tdigest := New(100, opts...)
+		for _, n := range []int{1, 2, 100, 100000} {
+			for tdigest.Count() < uint64(n) {
+				assertNoError(t, tdigest.Add(rand.ExpFloat64()))
+			}
+
+			table := tdigest.ExportQuantileTable(101)
+			if len(table) != 101 || table[0].Value != tdigest.Min() || table[100].Value != tdigest.Max() {
+				t.Fatalf("%s %d: got %d points from %v to %v, expected 101 from %v to %v",
+					name, n, len(table), table[0].Value, table[len(table)-1].Value, tdigest.Min(), tdigest.Max())
+			}
+			for i, p := range table {
+				if p.Q != float64(i)/100 || p.Value != tdigest.quantile(p.Q) {
+					t.Errorf("%s %d: got %+v, expected %v at %v", name, n, p, tdigest.quantile(float64(i)/100), float64(i)/100)
+				}
+				if i > 0 && p.Value < table[i-1].Value {
+					t.Errorf("%s %d: got %v after %v", name, n, p.Value, table[i-1].Value)
+				}
+			}
+		}
+	}
+}
+
+func TestAddBatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xba7c))
+	data := make([]float64, 50000)
+	for i := range data {
+		data[i] = rng.ExpFloat64()
+		if i%10 == 0 {
+			// runs of equal values
+			data[i] = 1
+		}
+	}
+	sorted := append([]float64{}, data...)
+	sort.Float64s(sorted)
+
+	for name, opts := range optionVariants(map[string][]Option{
+		"quantized":   {WithQuantization(0.001)},
+		"tails":       {WithExactTails(10)},
+		"footprint":   {WithSmallFootprint(64)},
+		"incremental": {WithIncrementalCompression(8)},
+	}) {
+		looped, batched := New(100, opts...), New(100, opts...)
+		for _, x := range data {
+			assertNoError(t, looped.Add(x))
+		}
+		assertNoError(t, batched.AddBatch(nil))
+		for i := 0; i < len(data); i += 10000 {
+			assertNoError(t, batched.AddBatch(data[i:i+10000]))
+		}
+
+		if batched.Count() != looped.Count() || batched.Min() != looped.Min() || batched.Max() != looped.Max() {
+			t.Errorf("%s: got count %d in [%v, %v], expected %d in [%v, %v]", name,
+				batched.Count(), batched.Min(), batched.Max(), looped.Count(), looped.Min(), looped.Max())
+		}
+		if math.Abs(batched.Mean()-looped.Mean()) > 1e-9 || math.Abs(batched.Variance()-looped.Variance()) > 1e-9 {
+			t.Errorf("%s: got mean %v and variance %v, expected %v and %v", name,
+				batched.Mean(), batched.Variance(), looped.Mean(), looped.Variance())
+		}
+		if n := batched.summary.Len(); float64(n) > batched.compressionTrigger()+1 {
+			t.Errorf("%s: got %d centroids, more than the compression trigger", name, n)
+		}
+
+		for _, q := range []float64{0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999} {
+			// the samples equal to x span the ranks [lo, hi]
+			x := batched.Quantile(q)
+			lo := float64(sort.SearchFloat64s(sorted, x)) / float64(len(sorted))
+			hi := float64(sort.SearchFloat64s(sorted, math.Nextafter(x, math.Inf(1)))) / float64(len(sorted))
+			if q < lo-0.01 || q > hi+0.01 {
+				t.Errorf("%s: Quantile(%v) = %v has ranks [%v, %v]", name, q, x, lo, hi)
+			}
+		}
+	}
+
+	mixed := []float64{math.Inf(1), math.Inf(-1), math.MaxFloat64, -math.MaxFloat64, math.SmallestNonzeroFloat64, -1, 0}
+	for len(mixed) < 1000 {
+		mixed = append(mixed, rng.NormFloat64()*math.Pow(10, float64(rng.Intn(40)-20)))
+	}
+	expected := append([]float64{}, mixed...)
+	sort.Float64s(expected)
+	radixSort(mixed)
+	assertSameFloats(t, "radixSort", mixed, expected)
+
+	tdigest := New(100)
+	assertNoError(t, tdigest.AddBatch(data[:100]))
+	before := tdigest.Marshal(nil)
+	if err := tdigest.AddBatch([]float64{1, 2, math.NaN(), 3}); err != ErrInvalidValue {
+		t.Errorf("Expected ErrInvalidValue for a batch with NaN, got %v", err)
+	}
+	if after := tdigest.Marshal(nil); !bytes.Equal(before, after) {
+		t.Errorf("A rejected batch changed the digest")
+	}
+}
+
+func benchmarkBatch() []float64 {
+	values := make([]float64, 10000)
+	for i := range values {
+		values[i] = rand.NormFloat64()
+	}
+	return values
+}
+
+func BenchmarkAddLoop10k(b *testing.B) {
+	tdigest, values := New(100), benchmarkBatch()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, x := range values {
+			if err := tdigest.Add(x); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkAddBatch10k(b *testing.B) {
+	tdigest, values := New(100), benchmarkBatch()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tdigest.AddBatch(values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNewFromValues(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf705))
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rng.NormFloat64()
+	}
+	sorted := append([]float64{}, data...)
+	sort.Float64s(sorted)
+
+	looped := New(100)
+	for _, x := range data {
+		assertNoError(t, looped.Add(x))
+	}
+	built, err := NewFromValues(100, data)
+	assertNoError(t, err)
+
+	if built.Count() != uint64(len(data)) || built.Min() != sorted[0] || built.Max() != sorted[len(sorted)-1] {
+		t.Errorf("got count %d in [%v, %v], expected %d in [%v, %v]",
+			built.Count(), built.Min(), built.Max(), len(data), sorted[0], sorted[len(sorted)-1])
+	}
+	if got, loop := maxRankError(built, sorted), maxRankError(looped, sorted); got > loop+0.001 {
+		t.Errorf("got a rank error of %v, expected at most that of Add, %v", got, loop)
+	}
+
+	bounded, err := NewFromValues(100, data, WithMaxCentroidBound(50))
+	assertNoError(t, err)
+	if n := bounded.summary.Len(); n > 50 {
+		t.Errorf("got %d centroids, expected at most 50", n)
+	}
+
+	empty, err := NewFromValues(100, nil)
+	assertNoError(t, err)
+	if empty.Count() != 0 || !math.IsNaN(empty.Quantile(0.5)) {
+		t.Errorf("expected an empty digest, got %d samples", empty.Count())
+	}
+
+	if _, err := NewFromValues(100, []float64{1, math.NaN()}); err != ErrInvalidValue {
+		t.Errorf("Expected ErrInvalidValue for values with NaN, got %v", err)
+	}
+}
+
+func benchmarkValues(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = rand.NormFloat64()
+	}
+	return values
+}
+
+func BenchmarkNewAddLoop1M(b *testing.B) {
+	values := benchmarkValues(1000000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tdigest := New(100)
+		for _, x := range values {
+			if err := tdigest.Add(x); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkNewFromValues1M(b *testing.B) {
+	values := benchmarkValues(1000000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewFromValues(100, values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// maxRankError returns the largest error in rank of the quantiles of the
+// digest over sorted, the samples it was built from.
+func maxRankError(tdigest *TDigest, sorted []float64) (worst float64) {
+	for i := 1; i < 1000; i++ {
+		q := float64(i) / 1000
+		x := tdigest.Quantile(q)
+		lo := float64(sort.SearchFloat64s(sorted, x)) / float64(len(sorted))
+		hi := float64(sort.SearchFloat64s(sorted, math.Nextafter(x, math.Inf(1)))) / float64(len(sorted))
+		worst = math.Max(worst, math.Max(lo-q, q-hi))
+	}
+	return worst
+}
+
+func TestAddSorted(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5047))
+	ascending := make([]float64, 100000)
+	for i := range ascending {
+		ascending[i] = rng.NormFloat64()
+	}
+	sort.Float64s(ascending)
+	descending := make([]float64, len(ascending))
+	for i, x := range ascending {
+		descending[len(descending)-1-i] = x
+	}
+
+	for name, opts := range optionVariants(nil) {
+		looped := New(100, opts...)
+		for _, x := range ascending {
+			assertNoError(t, looped.Add(x))
+		}
+		worst := maxRankError(looped, ascending)
+
+		for order, data := range map[string][]float64{"ascending": ascending, "descending": descending} {
+			tdigest := New(100, opts...)
+			for i := 0; i < len(data); i += 10000 {
+				assertNoError(t, tdigest.AddSorted(data[i:i+10000]))
+			}
+			if tdigest.Count() != looped.Count() || tdigest.Min() != looped.Min() || tdigest.Max() != looped.Max() {
+				t.Errorf("%s %s: got count %d in [%v, %v], expected %d in [%v, %v]", name, order,
+					tdigest.Count(), tdigest.Min(), tdigest.Max(), looped.Count(), looped.Min(), looped.Max())
+			}
+			// clustering the centroids of WithMaxCentroidBound in order is
+			// about as accurate as adding samples one at a time
+			if got := maxRankError(tdigest, ascending); got > worst+0.0005 {
+				t.Errorf("%s %s: got a rank error of %v, more than %v with Add", name, order, got, worst)
+			}
+		}
+	}
+
+	tdigest := New(100)
+	assertNoError(t, tdigest.AddSorted([]float64{1, 1, 2, 3}))
+	assertNoError(t, tdigest.AddSorted([]float64{3, 3, 2, 0}))
+	assertNoError(t, tdigest.AddSorted(nil))
+	before := tdigest.Marshal(nil)
+	if err := tdigest.AddSorted([]float64{1, 2, 1}); err == nil {
+		t.Errorf("Expected an error for unsorted values")
+	}
+	if err := tdigest.AddSorted([]float64{1, math.NaN(), 3}); err != ErrInvalidValue {
+		t.Errorf("Expected ErrInvalidValue for sorted values with NaN, got %v", err)
+	}
+	if after := tdigest.Marshal(nil); !bytes.Equal(before, after) {
+		t.Errorf("A rejected batch changed the digest")
+	}
+	if tdigest.Count() != 8 || tdigest.Min() != 0 || tdigest.Max() != 3 || tdigest.Median() != 2 {
+		t.Errorf("Expected 8 samples in [0, 3] with median 2, got %d in [%v, %v] with median %v",
+			tdigest.Count(), tdigest.Min(), tdigest.Max(), tdigest.Median())
+	}
+}
+
+func BenchmarkAddLoopSorted10k(b *testing.B) {
+	tdigest, values := New(100), benchmarkBatch()
+	sort.Float64s(values)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, x := range values {
+			if err := tdigest.Add(x); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkAddSorted10k(b *testing.B) {
+	tdigest, values := New(100), benchmarkBatch()
+	sort.Float64s(values)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tdigest.AddSorted(values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package tdigest
+
+import "math"
+
+// CountBetween estimates the number of samples greater than a and at most
+// b, consistently with CDF: it is Count times the difference between CDF(b)
+// and CDF(a), rounded so that the counts of adjacent ranges add up exactly.
+// Ranges outside of the samples, and empty ones where a is not lower than
+// b or either is NaN, hold no samples. Like CDF, samples equal to a or b
+// count for half, and (-Inf, +Inf] holds every sample.
+func (t *TDigest) CountBetween(a, b float64) uint64 {
+	t.checkRead()
+
+	if !(a < b) || t.summary.Len() == 0 {
+		return 0
+	}
+
+	// lo is the weight at or below a and mass the weight in (a, b]
+	var lo, mass float64
+	if t.summary.m2 != nil {
+		lo = t.cdf(a) * float64(t.count)
+		mass = t.cdf(b)*float64(t.count) - lo
+	} else {
+		ia, fa := t.locate(a)
+		ib, fb := t.locate(b)
+		lo = t.summary.HeadSum(ia) + fa
+		mass = t.summary.RangeSum(ia, ib) + fb - fa
+	}
+
+	hi := math.Round(lo + mass)
+	if hi <= math.Round(lo) {
+		return 0
+	}
+	return uint64(hi - math.Round(lo))
+}
+
+// locate returns the weight at or below x as that of the centroids before
+// index plus weight, as estimated by CDF. The digest must have centroids
+// without variances.
+func (t *TDigest) locate(x float64) (index int, weight float64) {
+	w := t.seekCDFWalk(x)
+	return w.rank(x)
+}
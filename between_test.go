@@ -0,0 +1,64 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestCountBetween(t *testing.T) {
+	data := make([]float64, 100000)
+	tdigest := New(100)
+	spread := New(100, WithCentroidVariance())
+	for i := range data {
+		data[i] = math.Exp(rand.NormFloat64()) * 100
+		assertNoError(t, tdigest.Add(data[i]))
+		assertNoError(t, spread.Add(data[i]))
+	}
+	sort.Float64s(data)
+
+	for _, d := range []*TDigest{tdigest, spread} {
+		n := float64(d.Count())
+		for _, bounds := range [][2]float64{{100, 250}, {0, 10}, {50, 60}, {500, 1e6}, {-1, 1e9}} {
+			a, b := bounds[0], bounds[1]
+			got := d.CountBetween(a, b)
+
+			// within rounding of the CDF, and within its error of the data
+			fromCDF := (d.CDF(b) - d.CDF(a)) * n
+			if math.Abs(float64(got)-fromCDF) > 1 {
+				t.Errorf("(%v, %v]: got %d, expected %v from the CDF", a, b, got, fromCDF)
+			}
+			exact := sort.SearchFloat64s(data, math.Nextafter(b, math.Inf(1))) - sort.SearchFloat64s(data, math.Nextafter(a, math.Inf(1)))
+			if math.Abs(float64(got)-float64(exact)) > 0.01*n {
+				t.Errorf("(%v, %v]: got %d, expected about %d", a, b, got, exact)
+			}
+		}
+
+		// adjacent ranges add up to the count
+		var total uint64
+		bounds := []float64{math.Inf(-1), 10, 50, 100, 100.5, 250, 1000, math.Inf(1)}
+		for i := 1; i < len(bounds); i++ {
+			total += d.CountBetween(bounds[i-1], bounds[i])
+		}
+		if total != d.Count() {
+			t.Errorf("Expected adjacent ranges to add up to %d, got %d", d.Count(), total)
+		}
+
+		for _, bounds := range [][2]float64{{100, 100}, {250, 100}, {-10, -1}, {1e9, 1e10}, {math.NaN(), 100}} {
+			if got := d.CountBetween(bounds[0], bounds[1]); got != 0 {
+				t.Errorf("(%v, %v]: got %d, expected 0", bounds[0], bounds[1], got)
+			}
+		}
+	}
+
+	if New(100).CountBetween(0, 1) != 0 {
+		t.Errorf("Expected 0 for an empty digest")
+	}
+
+	single := New(100)
+	assertNoError(t, single.AddWeighted(5, 3))
+	if single.CountBetween(4, 6) != 3 || single.CountBetween(6, 7) != 0 {
+		t.Errorf("Expected the point mass in (4, 6] and not in (6, 7]")
+	}
+}
@@ -0,0 +1,132 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+)
+
+// MarshalWithBudget is like Marshal but keeps the serialization within
+// maxBytes. When the full encoding does not fit, it is computed from a copy
+// of the digest re-clustered at progressively lower compressions until it
+// does, so the result decodes to a coarser digest with the same Count. The
+// digest itself is left untouched.
+//
+// It returns an error if even a digest merged down to the fewest possible
+// centroids does not fit.
+func (t *TDigest) MarshalWithBudget(buf []byte, maxBytes int) ([]byte, error) {
+	t.checkRead()
+
+	out := t.Marshal(buf)
+	if len(out) <= maxBytes {
+		return out, nil
+	}
+
+	// every attempt starts over from the original centroids so that the
+	// error of successive clusterings does not add up. The compression
+	// stops at 1 like that of Rescale, after which the centroids cannot be
+	// merged any further.
+	scale, previous := 1.0, t.compression
+	for {
+		scale *= 0.75
+
+		c := t.rescaled(scale)
+		if c.delta == 0 {
+			c.compression = math.Max(1, c.compression)
+		}
+		c.cluster()
+
+		out = c.Marshal(buf)
+		if len(out) <= maxBytes {
+			return out, nil
+		}
+		if c.compression == previous || c.summary.Len() <= 1 {
+			break
+		}
+		previous = c.compression
+	}
+
+	return nil, fmt.Errorf("cannot fit digest in %d bytes, %d needed", maxBytes, len(out))
+}
+
+// CompressTo merges centroids until the digest holds at most maxCentroids
+// of them, whatever its compression allows, trading accuracy for memory
+// and serialized size. The centroids are clustered as by a digest of a
+// lower compression, the highest one that leaves few enough of them, so
+// that the tails keep more centroids than the middle. Count, Min, Max and
+// the other statistics are kept, as is the compression the digest goes on
+// with. It does nothing if the digest is already small enough.
+//
+// It returns an error if maxCentroids is less than 1, or if the counts of
+// the centroids cannot fit in so few of them.
+func (t *TDigest) CompressTo(maxCentroids int) error {
+	if maxCentroids < 1 {
+		return fmt.Errorf("invalid number of centroids: %d", maxCentroids)
+	}
+
+	defer t.checkThresholds()
+	t.beginWrite()
+	defer t.endWrite()
+
+	if t.summary.Len() <= maxCentroids {
+		return nil
+	}
+
+	// fit clusters the original centroids at the compression scaled by
+	// scale, returning the result if it is small enough
+	fit := func(scale float64) *summary {
+		c := t.rescaled(scale)
+		c.cluster()
+		if c.summary.Len() > maxCentroids {
+			return nil
+		}
+		return c.summary
+	}
+
+	// halve the scale until the centroids fit, then bisect between the
+	// scales known to fit and not to fit
+	hi, lo := 1.0, 1.0
+	best := fit(lo)
+	for i := 0; best == nil && i < 64; i++ {
+		hi, lo = lo, lo/2
+		best = fit(lo)
+	}
+	if best == nil {
+		return fmt.Errorf("cannot fit %d samples in %d centroids", t.count, maxCentroids)
+	}
+	for i := 0; i < 20 && hi/lo > 1.01; i++ {
+		mid := math.Sqrt(lo * hi)
+		if s := fit(mid); s != nil {
+			lo, best = mid, s
+		} else {
+			hi = mid
+		}
+	}
+
+	t.summary = best
+	t.sweeping, t.cursor = false, 0
+	return nil
+}
+
+// rescaled returns a copy of the digest with its compression multiplied by
+// scale.
+func (t *TDigest) rescaled(scale float64) *TDigest {
+	c := &TDigest{
+		summary:  t.summary.Clone(),
+		count:    t.count,
+		sum:      t.sum,
+		m2:       t.m2,
+		m3:       t.m3,
+		m4:       t.m4,
+		min:      t.min,
+		max:      t.max,
+		tails:    t.tails.clone(),
+		metadata: t.metadata,
+	}
+	t.copyOptions(c)
+	c.compression = t.compression * scale
+	if t.delta > 0 {
+		c.delta = int(math.Max(1, math.Round(float64(t.delta)*scale)))
+		c.compression = float64(c.delta) / math.Pi
+	}
+	return c
+}
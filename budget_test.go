@@ -0,0 +1,163 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMarshalWithBudget(t *testing.T) {
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rand.Float64()
+	}
+
+	for name, opts := range optionVariants(map[string][]Option{
+		"bounded": {WithMaxCentroidBound(314)},
+	}) {
+		tdigest := New(100, opts...)
+		for _, x := range data {
+			assertNoError(t, tdigest.Add(x))
+		}
+		fingerprint := tdigest.Fingerprint()
+		full := tdigest.Marshal(nil)
+
+		sorted := append([]float64(nil), data...)
+		sort.Float64s(sorted)
+
+		// the worst absolute error over a range of quantiles must not shrink
+		// as the budget does, up to some noise
+		previousErr := 0.0
+		for _, budget := range []int{len(full), 2048, 1024, 512, 256, 128, 96} {
+			buf, err := tdigest.MarshalWithBudget(nil, budget)
+			assertNoError(t, err)
+
+			if len(buf) > budget {
+				t.Fatalf("%s: budget=%d: got %d bytes", name, budget, len(buf))
+			}
+
+			decoded, err := FromBytes(buf)
+			assertNoError(t, err)
+			if decoded.Count() != tdigest.Count() {
+				t.Fatalf("%s: budget=%d: decoded count %d, expected %d", name, budget, decoded.Count(), tdigest.Count())
+			}
+
+			var worst float64
+			for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+				worst = math.Max(worst, math.Abs(decoded.Quantile(q)-quantile(q, sorted)))
+			}
+			if worst+0.005 < previousErr {
+				t.Errorf("%s: budget=%d: error %.5f is lower than %.5f with a larger budget", name, budget, worst, previousErr)
+			}
+			if budget >= 512 && worst > 0.01 {
+				t.Errorf("%s: budget=%d: error %.5f is too large", name, budget, worst)
+			}
+			previousErr = worst
+		}
+
+		if tdigest.Fingerprint() != fingerprint {
+			t.Errorf("%s: MarshalWithBudget modified the digest", name)
+		}
+
+		if _, err := tdigest.MarshalWithBudget(nil, 4); err == nil {
+			t.Errorf("%s: Expected an error for a budget too small for any centroid", name)
+		}
+	}
+}
+
+func TestMarshalWithBudgetMinimalCompression(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 100000; i++ {
+		assertNoError(t, tdigest.Add(rand.Float64()))
+	}
+
+	// only the fewest centroids fit, which takes the lowest compression,
+	// and FromBytes would reset one below 1
+	buf, err := tdigest.MarshalWithBudget(nil, 80)
+	assertNoError(t, err)
+	if c := encodedCompression(buf); c != 1 {
+		t.Errorf("encoded a compression of %v, expected 1", c)
+	}
+}
+
+// encodedCompression returns the compression in the header of buf.
+func encodedCompression(buf []byte) float64 {
+	if buf[0]>>4 == tinyEncoding {
+		if index := int(buf[1]); index < len(tinyCompressions) {
+			return tinyCompressions[index]
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(buf[2:]))
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(buf[4:]))
+}
+
+func TestCompressTo(t *testing.T) {
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rand.NormFloat64()
+	}
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+
+	// the variances of a handful of centroids make their error too noisy
+	// for it to grow steadily as the target shrinks
+	variants := optionVariants(map[string][]Option{
+		"bounded": {WithMaxCentroidBound(314)},
+	})
+	delete(variants, "variance")
+
+	for name, opts := range variants {
+		tdigest := New(100, opts...)
+		for _, x := range data {
+			assertNoError(t, tdigest.Add(x))
+		}
+
+		// the worst rank error over a range of quantiles grows as the
+		// target shrinks, up to some noise
+		previousErr := 0.0
+		for _, target := range []int{tdigest.summary.Len(), 100, 50, 20, 10, 5, 1} {
+			c := tdigest.Clone()
+			assertNoError(t, c.CompressTo(target))
+
+			if c.summary.Len() > target {
+				t.Fatalf("%s: target=%d: got %d centroids", name, target, c.summary.Len())
+			}
+			if target == tdigest.summary.Len() && !reflect.DeepEqual(c.Centroids(), tdigest.Centroids()) {
+				t.Errorf("%s: Expected a digest already small enough to be left as is", name)
+			}
+			if c.Count() != tdigest.Count() || c.Min() != tdigest.Min() || c.Max() != tdigest.Max() {
+				t.Fatalf("%s: target=%d: got %d samples in [%v, %v], expected %d in [%v, %v]", name, target,
+					c.Count(), c.Min(), c.Max(), tdigest.Count(), tdigest.Min(), tdigest.Max())
+			}
+			checkSorted(c.summary, t)
+			assertHeadSums(c.summary, t)
+
+			var worst float64
+			for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+				worst = math.Max(worst, math.Abs(cdf(c.Quantile(q), sorted)-q))
+			}
+			t.Logf("target=%d: %d centroids, worst rank error %.5f", target, c.summary.Len(), worst)
+			if worst+0.005 < previousErr {
+				t.Errorf("%s: target=%d: error %.5f is lower than %.5f with a larger target", name, target, worst, previousErr)
+			}
+			if target >= 50 && worst > 0.005 {
+				t.Errorf("%s: target=%d: error %.5f is too large", name, target, worst)
+			}
+			previousErr = worst
+
+			// the digest goes on accepting samples as before
+			assertNoError(t, c.Add(0))
+		}
+	}
+
+	tdigest := New(100)
+	assertNoError(t, tdigest.Add(1))
+	for _, n := range []int{0, -1} {
+		if err := tdigest.CompressTo(n); err == nil {
+			t.Errorf("Expected an error compressing to %d centroids", n)
+		}
+	}
+}
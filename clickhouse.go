@@ -0,0 +1,85 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ClickHouseCompression is the compression of digests decoded from a
+// ClickHouse state, matching the default epsilon of 0.01 of ClickHouse's
+// quantileTDigest.
+const ClickHouseCompression = 100
+
+// FromClickHouseState decodes a digest from the state of a ClickHouse
+// quantileTDigest aggregate function, as returned by quantileTDigestState.
+//
+// The state is a LEB128 centroid count followed by little endian
+// (float32 mean, float32 count) pairs. Counts are rounded to the nearest
+// integer, and centroids whose count rounds to zero are dropped.
+func FromClickHouseState(buf []byte) (*TDigest, error) {
+	n, read := binary.Uvarint(buf)
+	if read <= 0 {
+		return nil, errors.New("invalid ClickHouse state: bad centroid count")
+	}
+	buf = buf[read:]
+
+	if n > DefaultMaxCentroids || uint64(len(buf)) != 8*n {
+		return nil, fmt.Errorf("invalid ClickHouse state: %d bytes for %d centroids", len(buf), n)
+	}
+
+	t := New(ClickHouseCompression)
+	for i := uint64(0); i < n; i++ {
+		mean := float64(math.Float32frombits(binary.LittleEndian.Uint32(buf)))
+		count := float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4:])))
+		buf = buf[8:]
+
+		if math.IsNaN(mean) || math.IsInf(mean, 0) {
+			return nil, fmt.Errorf("invalid ClickHouse state: centroid mean %v", mean)
+		}
+		if !(count >= 0) || count > math.MaxUint32 {
+			return nil, fmt.Errorf("invalid ClickHouse state: centroid count %v", count)
+		}
+
+		c := uint32(math.Round(count))
+		if c == 0 {
+			continue
+		}
+
+		t.summary.means = append(t.summary.means, mean)
+		t.summary.counts = append(t.summary.counts, c)
+		t.accumulate(uint64(c), mean*float64(c), 0)
+		t.observe(mean, mean)
+	}
+
+	sort.Stable(t.summary)
+	t.summary.rebuildTree(0)
+
+	return t, nil
+}
+
+// ToClickHouseState encodes the digest as the state of a ClickHouse
+// quantileTDigest aggregate function, suitable for inserting into an
+// AggregateFunction(quantileTDigest, ...) column.
+//
+// ClickHouse stores means and counts as float32, so means lose precision
+// beyond about 7 significant digits and counts above 2^24 are rounded.
+func (t *TDigest) ToClickHouseState() []byte {
+	t.checkRead()
+
+	var scratch [binary.MaxVarintLen64]byte
+	l := binary.PutUvarint(scratch[:], uint64(t.summary.Len()))
+
+	buf := make([]byte, 0, l+8*t.summary.Len())
+	buf = append(buf, scratch[:l]...)
+	t.summary.ForEach(func(mean float64, count uint32) bool {
+		binary.LittleEndian.PutUint32(scratch[:], math.Float32bits(float32(mean)))
+		binary.LittleEndian.PutUint32(scratch[4:], math.Float32bits(float32(count)))
+		buf = append(buf, scratch[:8]...)
+		return true
+	})
+
+	return buf
+}
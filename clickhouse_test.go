@@ -0,0 +1,78 @@
+package tdigest
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// testdata/clickhouse_uniform_1_1000.bin is a quantileTDigestState in
+// ClickHouse's layout summarizing the integers 1 to 1000: singletons at 1
+// and 1000 and 100 centroids of (about) 10 consecutive integers in between.
+func TestFromClickHouseState(t *testing.T) {
+	buf, err := os.ReadFile("testdata/clickhouse_uniform_1_1000.bin")
+	assertNoError(t, err)
+
+	tdigest, err := FromClickHouseState(buf)
+	assertNoError(t, err)
+
+	if tdigest.Count() != 1000 {
+		t.Fatalf("Expected 1000 samples, got %d", tdigest.Count())
+	}
+	if tdigest.summary.Len() != 102 {
+		t.Fatalf("Expected 102 centroids, got %d", tdigest.summary.Len())
+	}
+
+	for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+		exp := 1 + q*999
+		if got := tdigest.Quantile(q); math.Abs(got-exp) > 5 {
+			t.Errorf("Quantile(%.2f) = %.4f, expected about %.4f", q, got, exp)
+		}
+	}
+
+	// encoding it back reproduces the fixture exactly
+	if !bytes.Equal(tdigest.ToClickHouseState(), buf) {
+		t.Errorf("ToClickHouseState did not round trip the fixture")
+	}
+}
+
+func TestClickHouseStateRoundTrip(t *testing.T) {
+	t1 := New(100)
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, t1.Add(rand.NormFloat64()))
+	}
+
+	t2, err := FromClickHouseState(t1.ToClickHouseState())
+	assertNoError(t, err)
+
+	if t1.Count() != t2.Count() || t1.summary.Len() != t2.summary.Len() {
+		t.Fatalf("Round trip changed the digest: %d/%d samples, %d/%d centroids",
+			t1.Count(), t2.Count(), t1.summary.Len(), t2.summary.Len())
+	}
+	for _, q := range []float64{0.001, 0.1, 0.5, 0.9, 0.999} {
+		if d := math.Abs(t1.Quantile(q) - t2.Quantile(q)); d > 1e-5 {
+			t.Errorf("Quantile(%.3f) differs by %v", q, d)
+		}
+	}
+}
+
+func TestFromClickHouseStateInvalid(t *testing.T) {
+	valid := New(100)
+	assertNoError(t, valid.Add(1))
+	buf := valid.ToClickHouseState()
+
+	invalid := [][]byte{
+		nil,
+		buf[:len(buf)-1],
+		append(append([]byte(nil), buf...), 0),
+		{1, 0, 0, 0xc0, 0x7f, 0, 0, 0x80, 0x3f}, // NaN mean
+		{1, 0, 0, 0x80, 0x3f, 0, 0, 0x80, 0xbf}, // negative count
+	}
+	for _, b := range invalid {
+		if _, err := FromClickHouseState(b); err == nil {
+			t.Errorf("Expected %v to be rejected", b)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package tdigest
+
+// Clone returns a deep copy of the digest, which shares no state with it:
+// adding to or merging into either one leaves the other as it was. The copy
+// has the same options, and makes the same random choices as the original
+// from then on. Callbacks registered with OnThreshold are not copied.
+func (t *TDigest) Clone() *TDigest {
+	t.checkRead()
+
+	c := &TDigest{
+		summary:  t.summary.Clone(),
+		count:    t.count,
+		sweeping: t.sweeping,
+		cursor:   t.cursor,
+		metadata: append(t.metadata[:0:0], t.metadata...),
+		sum:      t.sum,
+		m2:       t.m2,
+		m3:       t.m3,
+		m4:       t.m4,
+		min:      t.min,
+		max:      t.max,
+		tails:    t.tails.clone(),
+	}
+	t.copyOptions(c)
+	c.pcg = t.pcg
+	return c
+}
+
+// copyOptions copies the options of the digest to dst, whose generator
+// starts over from the same seed. Clone, Split and the rescaled copies all
+// get their options from here, so a new option only needs adding once.
+func (t *TDigest) copyOptions(dst *TDigest) {
+	dst.compression = t.compression
+	dst.delta = t.delta
+	dst.budget = t.budget
+	dst.variance = t.variance
+	dst.step = t.step
+	dst.greedy = t.greedy
+	dst.deterministic = t.deterministic
+	dst.infinite = t.infinite
+	dst.interpolation = t.interpolation
+	dst.minSamples = t.minSamples
+	dst.maxCentroids = t.maxCentroids
+	dst.capacity = t.capacity
+	dst.trigger = t.trigger
+	dst.seed = t.seed
+	dst.pcg = newPCG(t.seed, 0)
+}
@@ -0,0 +1,107 @@
+package tdigest
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	for name, opts := range optionVariants(map[string][]Option{
+		"footprint":   {WithSmallFootprint(64)},
+		"incremental": {WithIncrementalCompression(8)},
+		"tails":       {WithExactTails(5), WithQuantization(0.001), WithGreedyCandidates()},
+	}) {
+		// twin is built like original, to tell whether cloning changed it
+		original, twin := New(100, opts...), New(100, opts...)
+		for _, tdigest := range []*TDigest{original, twin} {
+			rng := rand.New(rand.NewSource(0xc10e))
+			assertNoError(t, tdigest.SetMetadata([]byte("baseline")))
+			for i := 0; i < 10000; i++ {
+				assertNoError(t, tdigest.Add(rng.Float64()))
+			}
+		}
+
+		clone := original.Clone()
+		if !bytes.Equal(clone.Marshal(nil), original.Marshal(nil)) {
+			t.Errorf("%s: the clone differs from the original", name)
+		}
+		assertDisjoint(t, name, original, clone)
+
+		rng := rand.New(rand.NewSource(0xd1f))
+		for i := 0; i < 10000; i++ {
+			x := rng.Float64()
+			assertNoError(t, original.Add(x))
+			assertNoError(t, twin.Add(x))
+			assertNoError(t, clone.Add(10+x))
+		}
+		assertNoError(t, clone.SetMetadata([]byte("what if")))
+
+		if !bytes.Equal(original.Marshal(nil), twin.Marshal(nil)) {
+			t.Errorf("%s: adding to the clone changed the original", name)
+		}
+		if q := original.Quantile(0.75); q > 1 {
+			t.Errorf("%s: the original has its 3rd quartile at %v, expected at most 1", name, q)
+		}
+		if q := clone.Quantile(0.75); q < 10 {
+			t.Errorf("%s: the clone has its 3rd quartile at %v, expected at least 10", name, q)
+		}
+		if clone.Count() != 20000 || clone.Max() < 10 || original.Max() > 1 {
+			t.Errorf("%s: got %d samples up to %v in the clone, and up to %v in the original", name,
+				clone.Count(), clone.Max(), original.Max())
+		}
+	}
+}
+
+// assertDisjoint checks that a and b share none of their buffers.
+func assertDisjoint(t *testing.T, name string, a, b *TDigest) {
+	t.Helper()
+	if a.summary == b.summary || &a.summary.means[0] == &b.summary.means[0] ||
+		&a.summary.counts[0] == &b.summary.counts[0] || &a.summary.bitree.buf[0] == &b.summary.bitree.buf[0] ||
+		&a.metadata[0] == &b.metadata[0] {
+		t.Errorf("%s: the digests share their summary or metadata", name)
+	}
+	if a.summary.m2 != nil && &a.summary.m2[0] == &b.summary.m2[0] {
+		t.Errorf("%s: the digests share their centroid variances", name)
+	}
+	if a.tails != nil && (a.tails == b.tails || &a.tails.low[0] == &b.tails.low[0]) {
+		t.Errorf("%s: the digests share their exact tails", name)
+	}
+}
+
+func TestCopyOptions(t *testing.T) {
+	// fields holding the state of the digest rather than its options
+	state := map[string]bool{
+		"summary": true, "count": true, "pcg": true, "writing": true, "sweeping": true,
+		"cursor": true, "background": true, "metadata": true, "thresholds": true,
+		"sum": true, "m2": true, "m3": true, "m4": true, "min": true, "max": true,
+		"tails": true, "compressing": true, "generation": true,
+	}
+
+	tdigest := New(100, WithSmallFootprint(64), WithIncrementalCompression(8),
+		WithCentroidVariance(), WithQuantization(0.5), WithGreedyCandidates(),
+		WithDeterministicCompress(), WithInfiniteValues(), WithInterpolation(InterpolationMidpoint),
+		WithMinSamples(5), WithCapacity(10), WithAutoCompressThreshold(500), WithSeed(3))
+	source := reflect.ValueOf(tdigest).Elem()
+
+	for name, c := range map[string]*TDigest{
+		"Clone":     tdigest.Clone(),
+		"emptyCopy": tdigest.emptyCopy(),
+		"rescaled":  tdigest.rescaled(1),
+	} {
+		copied := reflect.ValueOf(c).Elem()
+		for i := 0; i < source.NumField(); i++ {
+			field := source.Type().Field(i).Name
+			if state[field] {
+				continue
+			}
+			if source.Field(i).IsZero() {
+				t.Fatalf("The option field %s is not set by the test, or is missing from the state fields", field)
+			}
+			if !source.Field(i).Equal(copied.Field(i)) {
+				t.Errorf("%s: the option field %s was not copied", name, field)
+			}
+		}
+	}
+}
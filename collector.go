@@ -0,0 +1,137 @@
+package tdigest
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Collector ingests samples sent over a channel into a digest from a
+// goroutine of its own, so that many producers can add samples without
+// contending on a mutex. The goroutine drains whatever is buffered in the
+// channel at once and adds it with AddBatch.
+type Collector struct {
+	ch   chan float64
+	drop bool
+
+	// dropped is the number of samples Add dropped, updated atomically.
+	dropped uint64
+
+	mu sync.Mutex
+	t  *TDigest
+
+	done  chan struct{}
+	close sync.Once
+}
+
+// CollectorOption configures a Collector created with NewCollector.
+type CollectorOption func(*Collector)
+
+// WithDropWhenFull makes Add drop the samples that do not fit in the buffer
+// of the channel, counting them in Dropped, instead of waiting for the
+// collector to make room for them.
+func WithDropWhenFull() CollectorOption {
+	return func(c *Collector) {
+		c.drop = true
+	}
+}
+
+// NewCollector creates a collector of a digest of the given compression,
+// reading samples from a channel buffering up to buffer of them. Its
+// goroutine runs until Close is called. It panics if buffer is negative.
+func NewCollector(compression float64, buffer int, opts ...CollectorOption) *Collector {
+	if buffer < 0 {
+		panic("buffer must not be negative")
+	}
+	c := &Collector{
+		ch:   make(chan float64, buffer),
+		t:    New(compression),
+		done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	go c.collect()
+	return c
+}
+
+func (c *Collector) collect() {
+	defer close(c.done)
+
+	batch := make([]float64, 0, cap(c.ch)+1)
+	for value := range c.ch {
+		batch = c.append(batch[:0], value)
+	drain:
+		for len(batch) < cap(batch) {
+			select {
+			case value, ok := <-c.ch:
+				if !ok {
+					break drain
+				}
+				batch = c.append(batch, value)
+			default:
+				break drain
+			}
+		}
+
+		c.mu.Lock()
+		// the batch holds no value rejected by Add
+		_ = c.t.AddBatch(batch)
+		c.mu.Unlock()
+	}
+}
+
+// append appends value to batch unless Add would reject it, which drops it
+// like TDigest.Observe.
+func (c *Collector) append(batch []float64, value float64) []float64 {
+	if c.t.checkValue(value) != nil {
+		return batch
+	}
+	return append(batch, value)
+}
+
+// C returns the channel the collector reads samples from. Sending to it
+// waits for room in its buffer whatever the options of the collector, and
+// panics once Close is called. Samples rejected by Add are dropped.
+func (c *Collector) C() chan<- float64 {
+	return c.ch
+}
+
+// Add sends value to the collector, waiting for room in the buffer of the
+// channel unless the collector was created with WithDropWhenFull. It must
+// not be called once Close is called.
+func (c *Collector) Add(value float64) {
+	if !c.drop {
+		c.ch <- value
+		return
+	}
+	select {
+	case c.ch <- value:
+	default:
+		atomic.AddUint64(&c.dropped, 1)
+	}
+}
+
+// Dropped returns the number of samples Add dropped because the buffer was
+// full.
+func (c *Collector) Dropped() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// Snapshot returns a copy of the digest of the samples collected so far,
+// not including those still buffered in the channel.
+func (c *Collector) Snapshot() *TDigest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.Clone()
+}
+
+// Close closes the channel and waits for the collector to add the samples
+// buffered in it, after which Snapshot holds every sample sent before Close.
+// It is safe to call Close more than once.
+func (c *Collector) Close() error {
+	c.close.Do(func() {
+		close(c.ch)
+		<-c.done
+	})
+	return nil
+}
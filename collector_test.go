@@ -0,0 +1,74 @@
+package tdigest
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector(100, 64)
+
+	const writers, perWriter = 8, 10000
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if w%2 == 0 {
+					c.Add(float64(i))
+				} else {
+					c.C() <- float64(i)
+				}
+			}
+		}(w)
+	}
+
+	// snapshots taken under load are consistent digests
+	var last uint64
+	for i := 0; i < 100; i++ {
+		snapshot := c.Snapshot()
+		if snapshot.Count() < last {
+			t.Errorf("got a snapshot of %d samples after one of %d", snapshot.Count(), last)
+		}
+		last = snapshot.Count()
+		if total := snapshot.summary.HeadSum(snapshot.summary.Len()); uint64(total) != last {
+			t.Errorf("got centroids of %v samples in a snapshot of %d", total, last)
+		}
+	}
+
+	wg.Wait()
+	c.C() <- math.NaN()
+	assertNoError(t, c.Close())
+	assertNoError(t, c.Close())
+
+	// no buffered sample is lost by Close
+	snapshot := c.Snapshot()
+	if snapshot.Count() != writers*perWriter || c.Dropped() != 0 {
+		t.Errorf("got %d samples and %d dropped, expected %d and none", snapshot.Count(), c.Dropped(), writers*perWriter)
+	}
+	if q := snapshot.Quantile(0.5); math.Abs(q-perWriter/2) > perWriter/50 {
+		t.Errorf("Quantile(0.5) = %v, expected %v", q, perWriter/2)
+	}
+
+	shouldPanic(func() { NewCollector(100, -1) }, t, "A negative buffer should panic")
+}
+
+func TestCollectorDropWhenFull(t *testing.T) {
+	c := NewCollector(100, 0, WithDropWhenFull())
+	defer c.Close()
+
+	// the collector is busy adding the first sample while holding the lock
+	c.mu.Lock()
+	c.C() <- 1
+	for i := 0; i < 100; i++ {
+		c.Add(2)
+	}
+	c.mu.Unlock()
+	assertNoError(t, c.Close())
+
+	if dropped, count := c.Dropped(), c.Snapshot().Count(); dropped != 100 || count != 1 {
+		t.Errorf("got %d samples and %d dropped, expected 1 and 100", count, dropped)
+	}
+}
@@ -0,0 +1,121 @@
+package tdigest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// combineGridSize is the number of quantiles per unit of compression at
+// which CombineQuantileAverage evaluates the digests.
+const combineGridSize = 10
+
+// CombineQuantileAverage returns a digest whose quantiles are the weighted
+// averages of the quantiles of the given digests, a combination known as
+// vincentization. Unlike Merge, which pools the samples, averaging two
+// copies of a distribution shifted by d gives the distribution shifted by
+// d/2 rather than a wider, possibly bimodal, one.
+//
+// The quantile curves are evaluated on a grid of 10 points per unit of the
+// largest compression, and the result holds the weighted average of the
+// counts of the digests, spread evenly over the grid. weights may be nil to
+// give every digest the same weight.
+//
+// It returns an error if there are no digests, if some digest is nil or
+// empty, or if the weights do not match the digests, are negative or are
+// all zero.
+func CombineQuantileAverage(digests []*TDigest, weights []float64) (*TDigest, error) {
+	if len(digests) == 0 {
+		return nil, errors.New("no digests to combine")
+	}
+	if weights != nil && len(weights) != len(digests) {
+		return nil, fmt.Errorf("got %d weights for %d digests", len(weights), len(digests))
+	}
+
+	var compression, total float64
+	var delta int
+	var count, lo, hi float64
+	for i, d := range digests {
+		if d == nil {
+			return nil, errors.New("cannot combine a nil digest")
+		}
+		d.checkRead()
+		if d.count == 0 {
+			return nil, errors.New("cannot combine an empty digest")
+		}
+
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		if !(w >= 0) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("invalid weight: %v", w)
+		}
+
+		compression = math.Max(compression, d.compression)
+		if d.delta > delta {
+			delta = d.delta
+		}
+		total += w
+		count += w * float64(d.count)
+		if w > 0 {
+			lo += w * d.min
+			hi += w * d.max
+		}
+	}
+	if total == 0 {
+		return nil, errors.New("weights must not all be zero")
+	}
+
+	var t *TDigest
+	if delta > 0 {
+		t = New(0, WithMaxCentroidBound(delta))
+	} else {
+		t = New(compression)
+	}
+
+	n := uint64(math.Max(1, math.Round(count/total)))
+	size := uint64(math.Ceil(combineGridSize * compression))
+	if size > n {
+		size = n
+	}
+
+	var prev uint64
+	for i := uint64(0); i < size; i++ {
+		// the rank boundaries are computed exactly so that the weights add
+		// up to the count, as in RepresentativePoints
+		next := n/size*(i+1) + n%size*(i+1)/size
+		q := (float64(i) + 0.5) / float64(size)
+
+		var value float64
+		for j, d := range digests {
+			w := 1.0
+			if weights != nil {
+				w = weights[j]
+			}
+			if w > 0 {
+				value += w / total * d.quantile(q)
+			}
+		}
+
+		for weight := next - prev; weight > 0; {
+			c := uint32(math.MaxUint32)
+			if weight < uint64(c) {
+				c = uint32(weight)
+			}
+			if err := t.addCentroid(value, c, 0); err != nil {
+				return nil, err
+			}
+			weight -= uint64(c)
+		}
+		prev = next
+	}
+	if err := t.compress(); err != nil {
+		return nil, err
+	}
+
+	// the extremes are the averages of the quantiles 0 and 1
+	t.min, t.max = lo/total, hi/total
+
+	return t, nil
+}
@@ -0,0 +1,82 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCombineQuantileAverage(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xc0ffee))
+
+	a, b := New(100), New(100)
+	for i := 0; i < 100000; i++ {
+		x := rng.NormFloat64()
+		assertNoError(t, a.Add(x))
+		assertNoError(t, b.Add(x+4))
+	}
+
+	pooled := New(100)
+	assertNoError(t, pooled.Merge(a))
+	assertNoError(t, pooled.Merge(b))
+
+	for _, tt := range []struct {
+		weights []float64
+		shift   float64
+	}{
+		{nil, 2},
+		{[]float64{1, 1}, 2},
+		{[]float64{3, 1}, 1},
+		{[]float64{0, 1}, 4},
+	} {
+		combined, err := CombineQuantileAverage([]*TDigest{a, b}, tt.weights)
+		assertNoError(t, err)
+
+		if combined.Count() != 100000 {
+			t.Errorf("weights %v: expected the average count. Got %d", tt.weights, combined.Count())
+		}
+
+		for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+			expected := a.Quantile(q) + tt.shift
+			if got := combined.Quantile(q); math.Abs(got-expected) > 0.05 {
+				t.Errorf("weights %v: Quantile(%v) = %.4f, expected %.4f", tt.weights, q, got, expected)
+			}
+		}
+	}
+
+	// pooling the samples gives a much wider, bimodal distribution instead
+	combined, err := CombineQuantileAverage([]*TDigest{a, b}, nil)
+	assertNoError(t, err)
+	iqr := func(d *TDigest) float64 { return d.Quantile(0.75) - d.Quantile(0.25) }
+	if iqr(pooled) < 2*iqr(combined) {
+		t.Errorf("Expected pooling to widen the distribution. Pooled IQR %.4f, averaged IQR %.4f", iqr(pooled), iqr(combined))
+	}
+}
+
+func TestCombineQuantileAverageErrors(t *testing.T) {
+	full := New(100)
+	assertNoError(t, full.Add(1))
+
+	for _, tt := range []struct {
+		digests []*TDigest
+		weights []float64
+	}{
+		{nil, nil},
+		{[]*TDigest{full, nil}, nil},
+		{[]*TDigest{full, New(100)}, nil},
+		{[]*TDigest{full}, []float64{1, 1}},
+		{[]*TDigest{full}, []float64{-1}},
+		{[]*TDigest{full}, []float64{math.NaN()}},
+		{[]*TDigest{full, full}, []float64{0, 0}},
+	} {
+		if _, err := CombineQuantileAverage(tt.digests, tt.weights); err == nil {
+			t.Errorf("Expected an error combining %v with weights %v", tt.digests, tt.weights)
+		}
+	}
+
+	single, err := CombineQuantileAverage([]*TDigest{full}, nil)
+	assertNoError(t, err)
+	if single.Count() != 1 || single.Quantile(0.5) != 1 {
+		t.Errorf("Expected a single sample at 1. Got %d samples with median %v", single.Count(), single.Quantile(0.5))
+	}
+}
@@ -0,0 +1,96 @@
+package tdigest
+
+import (
+	"errors"
+	"math"
+)
+
+// compareGridSize is the number of intervals of the grid of quantiles at
+// which Compare evaluates the digests.
+const compareGridSize = 100
+
+// Difference describes where two digests differ the most.
+type Difference struct {
+	// Quantile is the quantile at which the estimates differ the most.
+	Quantile float64
+	// Deviation is how much they differ there, as a fraction of the range
+	// covered by the samples of both digests.
+	Deviation float64
+}
+
+// Compare evaluates the quantiles of both digests at every percentile from
+// 1 to 99 and returns the one at which they differ the most. The extreme
+// quantiles are left out as they depend on the number of samples more than
+// on their distribution. Normalizing the difference by the combined range
+// of the samples makes it independent of their scale, and of the
+// compressions of the digests.
+//
+// Two empty digests do not differ, and an empty digest differs infinitely
+// from a non-empty one.
+func (t *TDigest) Compare(other *TDigest) Difference {
+	t.checkRead()
+	if other != t {
+		other.checkRead()
+	}
+
+	switch {
+	case t.count == 0 && other.count == 0:
+		return Difference{}
+	case t.count == 0 || other.count == 0:
+		return Difference{Deviation: math.Inf(1)}
+	}
+
+	width := math.Max(t.max, other.max) - math.Min(t.min, other.min)
+
+	var worst Difference
+	for i := 1; i < compareGridSize; i++ {
+		q := float64(i) / compareGridSize
+		d := math.Abs(t.quantile(q) - other.quantile(q))
+		if d == 0 {
+			continue
+		}
+		if d /= width; d > worst.Deviation {
+			worst = Difference{Quantile: q, Deviation: d}
+		}
+	}
+	return worst
+}
+
+// ApproxEqual reports whether the quantiles of the digests are within tol
+// of each other, as a fraction of the range covered by their samples. See
+// Compare for the details, and to find where they differ.
+func (t *TDigest) ApproxEqual(other *TDigest, tol float64) bool {
+	return t.Compare(other).Deviation <= tol
+}
+
+// QQ returns the points of the quantile-quantile plot of a and b: the
+// quantiles of a and of b at n evenly spaced values of q from 0 to 1, as
+// the first and second coordinates. The quantiles of each digest are
+// computed in a single pass over its centroids, and the values of q at which
+// either digest has no estimate, because of WithMinSamples, are skipped.
+//
+// It returns an error if n is less than 2, and ErrEmptyDigest if either
+// digest is empty.
+func QQ(a, b *TDigest, n int) ([][2]float64, error) {
+	if n < 2 {
+		return nil, errors.New("n must be at least 2")
+	}
+	a.checkRead()
+	if b != a {
+		b.checkRead()
+	}
+	if a.count == 0 || b.count == 0 {
+		return nil, ErrEmptyDigest
+	}
+
+	_, as := a.evenQuantiles(n, true)
+	_, bs := b.evenQuantiles(n, true)
+
+	points := make([][2]float64, 0, n)
+	for i := range as {
+		if !math.IsNaN(as[i]) && !math.IsNaN(bs[i]) {
+			points = append(points, [2]float64{as[i], bs[i]})
+		}
+	}
+	return points, nil
+}
@@ -0,0 +1,105 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestApproxEqual(t *testing.T) {
+	fill := func(compression float64, n int, gen func() float64) *TDigest {
+		tdigest := New(compression)
+		for i := 0; i < n; i++ {
+			assertNoError(t, tdigest.Add(gen()))
+		}
+		return tdigest
+	}
+
+	a := fill(100, 100000, rand.NormFloat64)
+	b := fill(50, 1000, rand.NormFloat64)
+	if !a.ApproxEqual(b, 0.05) {
+		t.Errorf("Expected samples of the same distribution to be equal, got %+v", a.Compare(b))
+	}
+	if !a.ApproxEqual(a, 0) {
+		t.Errorf("Expected a digest to equal itself, got %+v", a.Compare(a))
+	}
+
+	// scaling both does not change the deviation
+	scaled := fill(100, 100000, func() float64 { return 1000 * rand.NormFloat64() })
+	other := fill(100, 100000, func() float64 { return 1000 * rand.NormFloat64() })
+	if !scaled.ApproxEqual(other, 0.01) {
+		t.Errorf("Expected scaled samples to be equal, got %+v", scaled.Compare(other))
+	}
+
+	shifted := fill(100, 100000, func() float64 { return rand.NormFloat64() + 1 })
+	d := a.Compare(shifted)
+	if a.ApproxEqual(shifted, 0.05) {
+		t.Errorf("Expected shifted samples to differ, got %+v", d)
+	}
+	if d.Quantile <= 0 || d.Quantile >= 1 {
+		t.Errorf("Expected the largest difference inside the distribution, got %+v", d)
+	}
+	if other := shifted.Compare(a); other != d {
+		t.Errorf("Expected Compare to be symmetric, got %+v and %+v", d, other)
+	}
+
+	empty := New(100)
+	if !empty.ApproxEqual(New(10), 0) {
+		t.Errorf("Expected empty digests to be equal")
+	}
+	if d := empty.Compare(a); !math.IsInf(d.Deviation, 1) || a.ApproxEqual(empty, 1e9) {
+		t.Errorf("Expected an empty digest to differ from a non-empty one, got %+v", d)
+	}
+
+	constant := fill(100, 10, func() float64 { return 5 })
+	if d := constant.Compare(fill(10, 1000, func() float64 { return 5 })); d.Deviation != 0 {
+		t.Errorf("Expected digests of the same constant to be equal, got %+v", d)
+	}
+	if constant.ApproxEqual(fill(100, 10, func() float64 { return 6 }), 0.5) {
+		t.Errorf("Expected digests of different constants to differ")
+	}
+}
+
+func TestQQ(t *testing.T) {
+	a, b := New(100), New(100, WithMinSamples(10))
+	if _, err := QQ(a, b, 10); err != ErrEmptyDigest {
+		t.Errorf("Expected ErrEmptyDigest for empty digests, got %v", err)
+	}
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, a.Add(rand.NormFloat64()))
+	}
+	if _, err := QQ(a, b, 10); err != ErrEmptyDigest {
+		t.Errorf("Expected ErrEmptyDigest for an empty digest, got %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, b.Add(2*rand.NormFloat64()+1))
+	}
+	for _, n := range []int{-1, 0, 1} {
+		if _, err := QQ(a, b, n); err == nil {
+			t.Errorf("Expected an error for n=%d", n)
+		}
+	}
+
+	points, err := QQ(a, b, 101)
+	assertNoError(t, err)
+
+	// b has too few samples for the first and last percent
+	if len(points) != 99 {
+		t.Fatalf("got %d points, expected 99", len(points))
+	}
+	for i, p := range points {
+		q := float64(i+1) / 100
+		if p[0] != a.Quantile(q) || p[1] != b.Quantile(q) {
+			t.Errorf("point %d: got %v, expected %v and %v", i, p, a.Quantile(q), b.Quantile(q))
+		}
+		if i > 0 && (p[0] < points[i-1][0] || p[1] < points[i-1][1]) {
+			t.Errorf("point %d: got %v after %v", i, p, points[i-1])
+		}
+	}
+
+	points, err = QQ(a, a, 2)
+	assertNoError(t, err)
+	if len(points) != 2 || points[0] != [2]float64{a.Min(), a.Min()} || points[1] != [2]float64{a.Max(), a.Max()} {
+		t.Errorf("got %v, expected the extremes of a", points)
+	}
+}
@@ -0,0 +1,155 @@
+package tdigest
+
+import (
+	"sync"
+	"time"
+)
+
+// Concurrent wraps a digest to make it safe for concurrent use, serializing
+// every operation with a mutex.
+type Concurrent struct {
+	mu sync.Mutex
+	t  *TDigest
+
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	close    sync.Once
+}
+
+// ConcurrentOption configures a Concurrent created with NewConcurrent.
+type ConcurrentOption func(*Concurrent)
+
+// WithBackgroundCompaction compresses the digest from a background
+// goroutine every interval, unless it holds no more than half the centroids
+// that would trigger a compression, instead of compressing it inside Add.
+// Insertions then only compress the digest as a last resort, if it grows
+// to ten times that trigger before the next compaction. The goroutine runs
+// until Close is called. It panics if interval is not positive.
+func WithBackgroundCompaction(interval time.Duration) ConcurrentOption {
+	if interval <= 0 {
+		panic("interval must be positive")
+	}
+	return func(c *Concurrent) {
+		c.interval = interval
+	}
+}
+
+// NewConcurrent wraps t, which must not be used directly afterwards.
+func NewConcurrent(t *TDigest, opts ...ConcurrentOption) *Concurrent {
+	c := &Concurrent{t: t}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.interval > 0 {
+		t.background = true
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.compactLoop()
+	}
+	return c
+}
+
+func (c *Concurrent) compactLoop() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			if float64(c.t.summary.Len()) > c.t.compressionTrigger()/2 {
+				// compressing a valid summary does not fail
+				_ = c.t.Compress()
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// Close stops the background compaction, if any, and waits for it to
+// finish. The digest remains usable, compressing itself inside Add as
+// usual. It is safe to call Close more than once.
+func (c *Concurrent) Close() error {
+	c.close.Do(func() {
+		if c.stop == nil {
+			return
+		}
+		close(c.stop)
+		<-c.done
+
+		c.mu.Lock()
+		c.t.background = false
+		c.mu.Unlock()
+	})
+	return nil
+}
+
+// Add is like TDigest.Add.
+func (c *Concurrent) Add(value float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.Add(value)
+}
+
+// AddWeighted is like TDigest.AddWeighted.
+func (c *Concurrent) AddWeighted(value float64, count uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.AddWeighted(value, count)
+}
+
+// Observe is like TDigest.Observe, and makes a Concurrent a goroutine-safe
+// Observer.
+func (c *Concurrent) Observe(value float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t.Observe(value)
+}
+
+// Merge is like TDigest.Merge. other must not be modified concurrently.
+func (c *Concurrent) Merge(other *TDigest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.Merge(other)
+}
+
+// Compress is like TDigest.Compress.
+func (c *Concurrent) Compress() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.Compress()
+}
+
+// Quantile is like TDigest.Quantile.
+func (c *Concurrent) Quantile(q float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.Quantile(q)
+}
+
+// CDF is like TDigest.CDF.
+func (c *Concurrent) CDF(value float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.CDF(value)
+}
+
+// Count is like TDigest.Count.
+func (c *Concurrent) Count() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.Count()
+}
+
+// Marshal is like TDigest.Marshal.
+func (c *Concurrent) Marshal(buf []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.Marshal(buf)
+}
@@ -0,0 +1,126 @@
+//go:build !tinygo
+
+package tdigest
+
+import (
+	"bytes"
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// compactLoopRunning reports whether some background compaction goroutine
+// is alive.
+func compactLoopRunning() bool {
+	buf := make([]byte, 1<<20)
+	return bytes.Contains(buf[:runtime.Stack(buf, true)], []byte("(*Concurrent).compactLoop"))
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestConcurrentBackgroundCompaction(t *testing.T) {
+	c := NewConcurrent(New(10), WithBackgroundCompaction(time.Millisecond))
+	defer c.Close()
+
+	trigger := int(c.t.compressionTrigger())
+	size := func() int {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.t.summary.Len()
+	}
+
+	// ascending values make a new centroid each, and the digest no longer
+	// compresses itself until ten times the trigger
+	const writers, perWriter = 4, 500
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := c.Add(float64(i*writers + w)); err != nil {
+					t.Error(err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if c.Count() != writers*perWriter {
+		t.Fatalf("Expected %d samples, got %d", writers*perWriter, c.Count())
+	}
+	waitFor(t, "background compaction", func() bool { return size() <= trigger/2 })
+
+	// the digest is never compacted below half the trigger
+	for i := 0; i < trigger/4; i++ {
+		assertNoError(t, c.Add(float64(-i)))
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got, exp := size(), trigger/4; got < exp {
+		t.Errorf("Expected small digests to be left alone, got %d centroids", got)
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	c := NewConcurrent(New(10), WithBackgroundCompaction(time.Millisecond))
+	waitFor(t, "the compaction goroutine to start", compactLoopRunning)
+
+	assertNoError(t, c.Close())
+	assertNoError(t, c.Close())
+	waitFor(t, "the compaction goroutine to exit", func() bool { return !compactLoopRunning() })
+
+	// the digest goes back to compressing itself
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, c.Add(float64(i)))
+	}
+	if float64(c.t.summary.Len()) > c.t.compressionTrigger() {
+		t.Errorf("Expected the digest to compress itself after Close, got %d centroids", c.t.summary.Len())
+	}
+
+	assertNoError(t, NewConcurrent(New(10)).Close())
+	shouldPanic(func() { WithBackgroundCompaction(0) }, t, "A zero interval should panic")
+}
+
+// observer is the Observer interface of Prometheus client libraries.
+type observer interface {
+	Observe(float64)
+}
+
+func TestObserve(t *testing.T) {
+	tdigest := New(100)
+	var o observer = tdigest
+	for i := 0; i < 1000; i++ {
+		o.Observe(float64(i))
+	}
+	o.Observe(math.NaN())
+	if tdigest.Count() != 1000 || tdigest.Max() != 999 {
+		t.Errorf("got %d samples up to %v, expected 1000 up to 999", tdigest.Count(), tdigest.Max())
+	}
+
+	c := NewConcurrent(New(100))
+	o = c
+	const writers, perWriter = 4, 1000
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				o.Observe(float64(i))
+			}
+		}()
+	}
+	wg.Wait()
+	if c.Count() != writers*perWriter {
+		t.Errorf("Expected %d samples, got %d", writers*perWriter, c.Count())
+	}
+}
@@ -0,0 +1,74 @@
+package tdigest
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrStaleCursor is returned by Cursor.Err when the digest was modified
+// after the cursor was created.
+var ErrStaleCursor = errors.New("tdigest: digest modified during iteration")
+
+// Cursor iterates over the centroids of a digest in order of their means,
+// in either direction, without modifying it. It sits between two centroids,
+// or at either end. A cursor is invalidated by any modification of the
+// digest, after which Next and Prev return false and Err returns
+// ErrStaleCursor.
+type Cursor struct {
+	t          *TDigest
+	index      int
+	generation uint64
+}
+
+// SeekQuantile returns a cursor positioned right before the centroid
+// containing the rank q*Count(), the one returned by CentroidAt, so that the
+// upper tail of the digest can be iterated without walking the rest of it.
+// Positioning the cursor takes O(log n) for n centroids.
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) SeekQuantile(q float64) Cursor {
+	if !(q >= 0 && q <= 1) {
+		panic("q must be between 0 and 1 (inclusive)")
+	}
+	t.checkRead()
+
+	index := t.summary.SeekSum(math.Floor(q * float64(t.count)))
+	if index < 0 {
+		index = 0
+	}
+	return Cursor{t: t, index: index, generation: t.generation}
+}
+
+// Next returns the centroid after the cursor and moves the cursor past it.
+// It returns false at the end of the digest or if the cursor is stale.
+func (c *Cursor) Next() (Centroid, bool) {
+	if c.t == nil || c.Err() != nil || c.index >= c.t.summary.Len() {
+		return Centroid{}, false
+	}
+	c.t.checkRead()
+
+	centroid := Centroid{Mean: c.t.summary.Mean(c.index), Count: c.t.summary.Count(c.index)}
+	c.index++
+	return centroid, true
+}
+
+// Prev returns the centroid before the cursor and moves the cursor before
+// it. It returns false at the start of the digest or if the cursor is stale.
+func (c *Cursor) Prev() (Centroid, bool) {
+	if c.t == nil || c.Err() != nil || c.index <= 0 {
+		return Centroid{}, false
+	}
+	c.t.checkRead()
+
+	c.index--
+	return Centroid{Mean: c.t.summary.Mean(c.index), Count: c.t.summary.Count(c.index)}, true
+}
+
+// Err returns ErrStaleCursor if the digest was modified since the cursor
+// was created, and nil otherwise.
+func (c *Cursor) Err() error {
+	if c.t != nil && c.generation != c.t.generation {
+		return ErrStaleCursor
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package tdigest
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSeekQuantile(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 100000; i++ {
+		assertNoError(t, tdigest.Add(rand.ExpFloat64()))
+	}
+
+	for _, q := range []float64{0, 0.001, 0.1, 0.25, 0.5, 0.9, 0.95, 0.99, 0.999, 1} {
+		expected, _, _, err := tdigest.CentroidAt(q)
+		assertNoError(t, err)
+
+		cursor := tdigest.SeekQuantile(q)
+		got, ok := cursor.Next()
+		if !ok || got != expected {
+			t.Errorf("SeekQuantile(%v).Next() = %v, %v, expected %v", q, got, ok, expected)
+		}
+	}
+
+	var centroids []Centroid
+	tdigest.ForEachCentroid(func(mean float64, count uint32) bool {
+		centroids = append(centroids, Centroid{Mean: mean, Count: count})
+		return true
+	})
+
+	cursor := tdigest.SeekQuantile(0)
+	for i, expected := range centroids {
+		got, ok := cursor.Next()
+		if !ok || got != expected {
+			t.Fatalf("centroid %d: got %v, %v, expected %v", i, got, ok, expected)
+		}
+	}
+	if _, ok := cursor.Next(); ok {
+		t.Errorf("Expected the cursor to stop after the last centroid")
+	}
+
+	for i := len(centroids) - 1; i >= 0; i-- {
+		got, ok := cursor.Prev()
+		if !ok || got != centroids[i] {
+			t.Fatalf("centroid %d backwards: got %v, %v, expected %v", i, got, ok, centroids[i])
+		}
+	}
+	if _, ok := cursor.Prev(); ok {
+		t.Errorf("Expected the cursor to stop before the first centroid")
+	}
+	assertNoError(t, cursor.Err())
+
+	shouldPanic(func() { tdigest.SeekQuantile(1.1) }, t, "SeekQuantile(1.1) should panic")
+}
+
+func TestSeekQuantileStale(t *testing.T) {
+	tdigest := New(100)
+
+	cursor := tdigest.SeekQuantile(0.5)
+	if _, ok := cursor.Next(); ok {
+		t.Errorf("Expected no centroids in an empty digest")
+	}
+
+	assertNoError(t, tdigest.Add(1))
+	assertNoError(t, tdigest.Add(2))
+
+	cursor = tdigest.SeekQuantile(0.5)
+	if c, ok := cursor.Next(); !ok || c.Mean != 2 {
+		t.Errorf("Expected the centroid at 2, got %v", c)
+	}
+
+	assertNoError(t, tdigest.Add(3))
+	if _, ok := cursor.Prev(); ok {
+		t.Errorf("Expected a stale cursor to stop")
+	}
+	if cursor.Err() != ErrStaleCursor {
+		t.Errorf("Expected ErrStaleCursor, got %v", cursor.Err())
+	}
+
+	var zero Cursor
+	if _, ok := zero.Next(); ok || zero.Err() != nil {
+		t.Errorf("Expected the zero cursor to be empty")
+	}
+}
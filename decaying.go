@@ -0,0 +1,183 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// decayingSteps is the number of steps per half-life in which a
+// DecayingTDigest decays its samples.
+const decayingSteps = 64
+
+// decayingVersion is the first byte of the encoding of a DecayingTDigest.
+const decayingVersion = 1
+
+// DecayingTDigest wraps a digest whose samples lose half of their weight
+// every half-life, so that its estimates follow the recent samples. The
+// samples are decayed lazily with DecayWeights, before every addition and
+// query, in steps of 1/64 of the half-life: the elapsed time left over from
+// a step is carried into the next one, and the estimates lag the exact
+// decay by at most a step, about 1% of the weights.
+type DecayingTDigest struct {
+	digest   *TDigest
+	halfLife time.Duration
+	now      func() time.Time
+
+	// at is the time the samples were last decayed to.
+	at time.Time
+}
+
+// NewDecayingTDigest wraps t, which must not be used directly afterwards,
+// decaying its samples with the given half-life from the current time of
+// now, or of time.Now if now is nil. It panics if halfLife is not positive.
+func NewDecayingTDigest(t *TDigest, halfLife time.Duration, now func() time.Time) *DecayingTDigest {
+	if halfLife <= 0 {
+		panic("half-life must be positive")
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &DecayingTDigest{digest: t, halfLife: halfLife, now: now, at: now()}
+}
+
+// HalfLife returns the half-life of the samples.
+func (d *DecayingTDigest) HalfLife() time.Duration {
+	return d.halfLife
+}
+
+// step returns the duration of a decay step.
+func (d *DecayingTDigest) step() time.Duration {
+	if step := d.halfLife / decayingSteps; step > 0 {
+		return step
+	}
+	return 1
+}
+
+// decayTo decays the samples to the given time in whole steps, and ignores
+// times before the last one they were decayed to.
+func (d *DecayingTDigest) decayTo(at time.Time) {
+	steps := at.Sub(d.at) / d.step()
+	if steps <= 0 {
+		return
+	}
+	d.at = d.at.Add(steps * d.step())
+	decayFor(d.digest, steps*d.step(), d.halfLife)
+}
+
+// decayFor decays the samples of t by elapsed time of the given half-life.
+func decayFor(t *TDigest, elapsed, halfLife time.Duration) {
+	factor := math.Exp2(-float64(elapsed) / float64(halfLife))
+
+	t.beginWrite()
+	t.decayWeights(math.Max(factor, math.SmallestNonzeroFloat64))
+	t.endWrite()
+	t.checkThresholds()
+}
+
+// Add decays the samples to the current time, then adds value like
+// TDigest.Add.
+func (d *DecayingTDigest) Add(value float64) error {
+	d.decayTo(d.now())
+	return d.digest.Add(value)
+}
+
+// AddWeighted decays the samples to the current time, then adds value like
+// TDigest.AddWeighted.
+func (d *DecayingTDigest) AddWeighted(value float64, count uint32) error {
+	d.decayTo(d.now())
+	return d.digest.AddWeighted(value, count)
+}
+
+// Merge decays the samples of both digests to the same time, the latest of
+// the times they were last decayed to and the current time of d, then
+// merges those of other, which is left unchanged.
+//
+// It returns an error if other has a different half-life.
+func (d *DecayingTDigest) Merge(other *DecayingTDigest) error {
+	if other.halfLife != d.halfLife {
+		return fmt.Errorf("cannot merge digests with half-lives of %v and %v", d.halfLife, other.halfLife)
+	}
+
+	d.decayTo(d.now())
+	if other == d {
+		return d.digest.Merge(d.digest)
+	}
+
+	if other.at.After(d.at) {
+		decayFor(d.digest, other.at.Sub(d.at), d.halfLife)
+		d.at = other.at
+	}
+	c := other.digest.Clone()
+	if elapsed := d.at.Sub(other.at); elapsed > 0 {
+		decayFor(c, elapsed, other.halfLife)
+	}
+	return d.digest.Merge(c)
+}
+
+// Quantile decays the samples to the current time, then returns the
+// estimate of TDigest.Quantile.
+func (d *DecayingTDigest) Quantile(q float64) float64 {
+	d.decayTo(d.now())
+	return d.digest.Quantile(q)
+}
+
+// CDF decays the samples to the current time, then returns the estimate of
+// TDigest.CDF.
+func (d *DecayingTDigest) CDF(value float64) float64 {
+	d.decayTo(d.now())
+	return d.digest.CDF(value)
+}
+
+// Count decays the samples to the current time, then returns their decayed
+// weight.
+func (d *DecayingTDigest) Count() uint64 {
+	d.decayTo(d.now())
+	return d.digest.Count()
+}
+
+// Digest decays the samples to the current time, then returns the digest
+// holding them for the queries that DecayingTDigest does not wrap. It is
+// shared with d, not a copy, and must not be modified.
+func (d *DecayingTDigest) Digest() *TDigest {
+	d.decayTo(d.now())
+	return d.digest
+}
+
+// Marshal appends the half-life, the time the samples were last decayed to
+// and the encoding of the digest to buf. The samples are not decayed first,
+// so that the encoding does not depend on the time it is made at.
+func (d *DecayingTDigest) Marshal(buf []byte) []byte {
+	var header [17]byte
+	header[0] = decayingVersion
+	binary.BigEndian.PutUint64(header[1:], uint64(d.halfLife))
+	binary.BigEndian.PutUint64(header[9:], uint64(d.at.UnixNano()))
+	return d.digest.Marshal(append(buf, header[:]...))
+}
+
+// DecayingFromBytes decodes a DecayingTDigest encoded by Marshal, which
+// decays its samples from the time they were last decayed to before it was
+// encoded, according to now or time.Now if now is nil.
+func DecayingFromBytes(buf []byte, now func() time.Time) (*DecayingTDigest, error) {
+	if len(buf) < 17 {
+		return nil, errors.New("buffer too small for a decaying digest")
+	}
+	if buf[0] != decayingVersion {
+		return nil, fmt.Errorf("unsupported decaying digest version: %d", buf[0])
+	}
+	halfLife := time.Duration(binary.BigEndian.Uint64(buf[1:]))
+	if halfLife <= 0 {
+		return nil, fmt.Errorf("invalid half-life: %v", halfLife)
+	}
+	at := time.Unix(0, int64(binary.BigEndian.Uint64(buf[9:])))
+
+	t, err := FromBytes(buf[17:])
+	if err != nil {
+		return nil, err
+	}
+	d := NewDecayingTDigest(t, halfLife, now)
+	d.at = at
+	return d, nil
+}
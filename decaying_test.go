@@ -0,0 +1,133 @@
+package tdigest
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func assertNear(t *testing.T, name string, got, expected, tolerance float64) {
+	t.Helper()
+	if math.Abs(got-expected) > tolerance {
+		t.Errorf("%s = %v, expected %v", name, got, expected)
+	}
+}
+
+func TestDecayingTDigest(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	d := NewDecayingTDigest(New(100), time.Minute, clock.Now)
+	if d.HalfLife() != time.Minute {
+		t.Errorf("HalfLife() = %v, expected 1m", d.HalfLife())
+	}
+
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, d.Add(0))
+	}
+	clock.Advance(time.Minute)
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, d.Add(10))
+	}
+
+	// every half-life halves the weight of the samples added before it
+	assertNear(t, "Count() after a half-life", float64(d.Count()), 15000, 150)
+	assertNear(t, "CDF(5) after a half-life", d.CDF(5), 1.0/3, 0.01)
+
+	clock.Advance(time.Minute)
+	assertNear(t, "Count() after two half-lives", float64(d.Count()), 7500, 150)
+	assertNear(t, "CDF(5) after two half-lives", d.CDF(5), 1.0/3, 0.01)
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, d.AddWeighted(20, 1))
+	}
+	assertNear(t, "CDF(5) with a third batch", d.CDF(5), 2500.0/17500, 0.01)
+	assertNear(t, "CDF(15) with a third batch", d.CDF(15), 7500.0/17500, 0.01)
+	if q := d.Quantile(0.9); q != 20 {
+		t.Errorf("Quantile(0.9) = %v, expected 20", q)
+	}
+
+	// the time elapsed within a step carries over to the next ones
+	count := float64(d.Count())
+	for i := 0; i < 60; i++ {
+		clock.Advance(time.Second / 2)
+		d.Count()
+	}
+	assertNear(t, "Count() after half a half-life", float64(d.Count()), count/math.Sqrt2, 0.02*count)
+	if d.Digest().Count() != d.Count() {
+		t.Errorf("Digest().Count() = %d, expected %d", d.Digest().Count(), d.Count())
+	}
+
+	clock.Advance(1000 * time.Hour)
+	if d.Count() != 0 || !math.IsNaN(d.Quantile(0.5)) {
+		t.Errorf("Expected every sample to fade away, got %d", d.Count())
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic for a zero half-life")
+			}
+		}()
+		NewDecayingTDigest(New(100), 0, clock.Now)
+	}()
+}
+
+func TestDecayingTDigestMerge(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	older := NewDecayingTDigest(New(100), time.Minute, clock.Now)
+	for i := 0; i < 8000; i++ {
+		assertNoError(t, older.Add(0))
+	}
+
+	clock.Advance(2 * time.Minute)
+	newer := NewDecayingTDigest(New(100), time.Minute, clock.Now)
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, newer.Add(10))
+	}
+
+	// older has not been decayed since its samples were added, while the
+	// current time of newer is two half-lives later
+	stale := NewDecayingTDigest(New(100), time.Minute, func() time.Time { return time.Unix(1000, 0) })
+	assertNoError(t, stale.Merge(older))
+	assertNoError(t, stale.Merge(newer))
+	assertNear(t, "Count() of the merged digest", float64(stale.digest.Count()), 3000, 100)
+	assertNear(t, "CDF(5) of the merged digest", stale.digest.CDF(5), 2.0/3, 0.02)
+
+	assertNoError(t, older.Merge(newer))
+	assertNear(t, "Count() after Merge", float64(older.Count()), 3000, 100)
+	if newer.Count() != 1000 {
+		t.Errorf("Merge changed the merged digest, got %d samples", newer.Count())
+	}
+
+	if err := older.Merge(NewDecayingTDigest(New(100), time.Hour, clock.Now)); err == nil {
+		t.Errorf("Expected an error merging digests with different half-lives")
+	}
+}
+
+func TestDecayingTDigestSerialization(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	d := NewDecayingTDigest(New(100), time.Minute, clock.Now)
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, d.Add(float64(i)))
+	}
+	clock.Advance(time.Minute)
+
+	// the encoding holds the samples as last decayed, and the time they were
+	// decayed to
+	decoded, err := DecayingFromBytes(d.Marshal(nil), clock.Now)
+	assertNoError(t, err)
+	if decoded.HalfLife() != time.Minute || !decoded.at.Equal(d.at) ||
+		!bytes.Equal(decoded.digest.Marshal(nil), d.digest.Marshal(nil)) {
+		t.Errorf("Expected the same half-life, time and digest, got %v and %v", decoded.HalfLife(), decoded.at)
+	}
+	assertNear(t, "Count() of the decoded digest", float64(decoded.Count()), 5000, 100)
+	assertNear(t, "Count() of the encoded digest", float64(d.Count()), 5000, 100)
+	if got, expected := decoded.Quantile(0.5), d.Quantile(0.5); math.Abs(got-expected) > 100 {
+		t.Errorf("Quantile(0.5) of the decoded digest = %v, expected %v", got, expected)
+	}
+
+	for _, buf := range [][]byte{nil, {2}, append([]byte{decayingVersion}, make([]byte, 16)...)} {
+		if _, err := DecayingFromBytes(buf, clock.Now); err == nil {
+			t.Errorf("Expected an error decoding %x", buf)
+		}
+	}
+}
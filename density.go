@@ -0,0 +1,79 @@
+package tdigest
+
+import (
+	"math"
+	"sort"
+)
+
+// PDF estimates the density of the samples at x, as the derivative of CDF:
+// the weight CDF spreads between two consecutive centroids divided by the
+// distance between their means, as a fraction of Count. The density is
+// infinite at the samples CDF treats as points, single samples and the
+// extremes among them, which take the rest of the weight, so it integrates
+// to 1 when there are none. With WithCentroidVariance the density is that
+// of the interval of every centroid, and 0 between intervals that do not
+// meet.
+//
+// It returns NaN for an empty digest, or if x is NaN, and 0 outside of the
+// range of the samples.
+func (t *TDigest) PDF(x float64) float64 {
+	t.checkRead()
+
+	switch {
+	case t.summary.Len() == 0 || math.IsNaN(x):
+		return math.NaN()
+	case x < t.min || x > t.max:
+		return 0
+	case t.summary.m2 != nil:
+		return t.pdfSpread(x)
+	case x == t.min || x == t.max:
+		return math.Inf(1)
+	}
+
+	s := t.summary
+	n := s.Len()
+	w := cdfWalk{t: t}
+	i := sort.Search(n, func(i int) bool { return s.Mean(i) > x }) - 1
+
+	var weight, width float64
+	switch {
+	case i >= 0 && s.Mean(i) == x && w.isPoint(i):
+		return math.Inf(1)
+	case i < 0:
+		// between the minimum and the first mean, see cdfWalk.rank
+		weight, width = float64(s.Count(0))/2-1, s.Mean(0)-t.min
+	case i == n-1:
+		weight, width = float64(s.Count(i))/2-1, t.max-s.Mean(i)
+	default:
+		weight, width = (float64(s.Count(i))+float64(s.Count(i+1)))/2, s.Mean(i+1)-s.Mean(i)
+		if w.isPoint(i) {
+			weight -= float64(s.Count(i)) / 2
+		}
+		if w.isPoint(i + 1) {
+			weight -= float64(s.Count(i+1)) / 2
+		}
+	}
+	return weight / width / float64(t.count)
+}
+
+// pdfSpread is PDF for digests tracking variances, the derivative of
+// cdfSpread.
+func (t *TDigest) pdfSpread(x float64) float64 {
+	for i := 0; i < t.summary.Len(); i++ {
+		lo, hi := t.summary.spreadBounds(i)
+		half := float64(t.summary.Count(i)) / 2
+		mean := t.summary.Mean(i)
+		switch {
+		case x < lo:
+			return 0
+		case x < mean:
+			return half / (mean - lo) / float64(t.count)
+		case x < hi:
+			return half / (hi - mean) / float64(t.count)
+		case x == mean:
+			// a centroid without spread
+			return math.Inf(1)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPDF(t *testing.T) {
+	if !math.IsNaN(New(100).PDF(0)) {
+		t.Errorf("Expected NaN for an empty digest")
+	}
+
+	single := New(100)
+	assertNoError(t, single.AddWeighted(3, 5))
+	if !math.IsInf(single.PDF(3), 1) || single.PDF(2.9) != 0 || single.PDF(3.1) != 0 {
+		t.Errorf("Expected a spike at the single value, got %v, %v and %v", single.PDF(2.9), single.PDF(3), single.PDF(3.1))
+	}
+
+	for name, opts := range optionVariants(nil) {
+		tdigest := New(100, opts...)
+		for i := 0; i < 100000; i++ {
+			assertNoError(t, tdigest.Add(2+4*rand.Float64()))
+		}
+
+		// the density of the uniform distribution over [2, 6] is 1/4, with
+		// variances it is 0 in the gaps CDF leaves between centroids
+		if tdigest.summary.m2 == nil {
+			for _, x := range []float64{2.5, 3, 4, 5, 5.5} {
+				if got := tdigest.PDF(x); math.Abs(got-0.25) > 0.05 {
+					t.Errorf("%s: PDF(%v) = %v, expected about 0.25", name, x, got)
+				}
+			}
+		}
+		for _, x := range []float64{1.9, 6.1, math.Inf(-1), math.Inf(1)} {
+			if got := tdigest.PDF(x); got != 0 {
+				t.Errorf("%s: PDF(%v) = %v, expected 0 outside of the samples", name, x, got)
+			}
+		}
+
+		// the extremes are points, the rest integrates to about 1
+		const steps = 10000
+		lo, hi := tdigest.Min(), tdigest.Max()
+		var integral float64
+		for i := 0; i < steps; i++ {
+			x := lo + (float64(i)+0.5)*(hi-lo)/steps
+			integral += tdigest.PDF(x) * (hi - lo) / steps
+		}
+		if math.Abs(integral-1) > 0.01 {
+			t.Errorf("%s: PDF integrates to %v, expected about 1", name, integral)
+		}
+	}
+}
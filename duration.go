@@ -0,0 +1,106 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// maxExactDuration is the largest duration whose number of nanoseconds a
+// float64 holds exactly, about 104 days.
+const maxExactDuration = time.Duration(1 << 53)
+
+// DurationDigest wraps a digest of durations, such as latencies, stored as
+// their number of nanoseconds, so that call sites do not convert them to
+// float64 themselves and cannot mix up units. Estimates are rounded to the
+// nearest nanosecond.
+type DurationDigest struct {
+	digest *TDigest
+}
+
+// NewDurationDigest creates a digest of durations configured by compression
+// and opts like New.
+func NewDurationDigest(compression float64, opts ...Option) *DurationDigest {
+	return &DurationDigest{digest: New(compression, opts...)}
+}
+
+// checkDuration returns an error for durations a float64 does not hold
+// exactly.
+func checkDuration(d time.Duration) error {
+	if d > maxExactDuration || d < -maxExactDuration {
+		return fmt.Errorf("duration %v is too large to be held exactly", d)
+	}
+	return nil
+}
+
+// Add adds the duration x like TDigest.Add. It returns an error for
+// durations longer than 2^53 nanoseconds, about 104 days, which would lose
+// precision.
+func (d *DurationDigest) Add(x time.Duration) error {
+	return d.AddWeighted(x, 1)
+}
+
+// AddWeighted adds count samples of the duration x like
+// TDigest.AddWeighted, and returns an error for the durations rejected by
+// Add.
+func (d *DurationDigest) AddWeighted(x time.Duration, count uint32) error {
+	if err := checkDuration(x); err != nil {
+		return err
+	}
+	return d.digest.AddWeighted(float64(x), count)
+}
+
+// Quantile returns the estimate of TDigest.Quantile rounded to the nearest
+// nanosecond, and 0 for an empty digest.
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (d *DurationDigest) Quantile(q float64) time.Duration {
+	return roundDuration(d.digest.Quantile(q))
+}
+
+// CDF returns the estimate of TDigest.CDF for the duration x.
+func (d *DurationDigest) CDF(x time.Duration) float64 {
+	return d.digest.CDF(float64(x))
+}
+
+// Count returns the number of durations added to the digest.
+func (d *DurationDigest) Count() uint64 {
+	return d.digest.Count()
+}
+
+// Merge adds the durations of other, which is left unchanged, like
+// TDigest.Merge.
+func (d *DurationDigest) Merge(other *DurationDigest) error {
+	return d.digest.Merge(other.digest)
+}
+
+// Digest returns the digest of the numbers of nanoseconds of the durations,
+// for the queries that DurationDigest does not wrap. It is shared with d,
+// not a copy.
+func (d *DurationDigest) Digest() *TDigest {
+	return d.digest
+}
+
+// Marshal appends the encoding of the digest of nanoseconds to buf, which
+// FromBytes decodes as well.
+func (d *DurationDigest) Marshal(buf []byte) []byte {
+	return d.digest.Marshal(buf)
+}
+
+// DurationFromBytes decodes a DurationDigest encoded by Marshal.
+func DurationFromBytes(buf []byte) (*DurationDigest, error) {
+	t, err := FromBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &DurationDigest{digest: t}, nil
+}
+
+// roundDuration rounds x nanoseconds to the nearest duration, and returns 0
+// for NaN.
+func roundDuration(x float64) time.Duration {
+	if math.IsNaN(x) {
+		return 0
+	}
+	return time.Duration(math.Round(x))
+}
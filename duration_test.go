@@ -0,0 +1,82 @@
+package tdigest
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestDurationDigest(t *testing.T) {
+	d := NewDurationDigest(100)
+	if q := d.Quantile(0.5); q != 0 {
+		t.Errorf("Quantile(0.5) of an empty digest = %v, expected 0", q)
+	}
+
+	// uniform latencies between 0 and 1s
+	rng := rand.New(rand.NewSource(0xd0))
+	for i := 0; i < 100000; i++ {
+		assertNoError(t, d.Add(time.Duration(rng.Int63n(int64(time.Second)))))
+	}
+	for _, test := range []struct {
+		q         float64
+		tolerance time.Duration
+	}{
+		{0.001, time.Millisecond}, {0.01, 5 * time.Millisecond}, {0.1, 10 * time.Millisecond},
+		{0.5, 20 * time.Millisecond}, {0.9, 10 * time.Millisecond}, {0.99, 5 * time.Millisecond},
+		{0.999, time.Millisecond},
+	} {
+		expected := time.Duration(test.q * float64(time.Second))
+		if got := d.Quantile(test.q); got < expected-test.tolerance || got > expected+test.tolerance {
+			t.Errorf("Quantile(%v) = %v, expected %v", test.q, got, expected)
+		}
+	}
+	assertNear(t, "CDF(250ms)", d.CDF(250*time.Millisecond), 0.25, 0.01)
+	if d.Count() != 100000 || d.Digest().Count() != 100000 {
+		t.Errorf("got %d durations, expected 100000", d.Count())
+	}
+
+	// estimates are whole nanoseconds
+	exact := NewDurationDigest(100)
+	assertNoError(t, exact.Add(1))
+	assertNoError(t, exact.Add(2))
+	if q := exact.Quantile(0.5); q != 2 {
+		t.Errorf("Quantile(0.5) = %v, expected 2ns", q)
+	}
+
+	if err := d.Add(maxExactDuration); err != nil {
+		t.Errorf("got %v for the largest exact duration", err)
+	}
+	for _, x := range []time.Duration{maxExactDuration + 1, -maxExactDuration - 1} {
+		if err := d.AddWeighted(x, 1); err == nil {
+			t.Errorf("Expected an error for %v", x)
+		}
+	}
+}
+
+func TestDurationDigestMerge(t *testing.T) {
+	fast, slow := NewDurationDigest(100), NewDurationDigest(100)
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, fast.Add(time.Millisecond))
+		assertNoError(t, slow.AddWeighted(time.Second, 3))
+	}
+	assertNoError(t, fast.Merge(slow))
+	if fast.Count() != 4000 || slow.Count() != 3000 {
+		t.Errorf("got %d and %d durations after Merge, expected 4000 and 3000", fast.Count(), slow.Count())
+	}
+	if q := fast.Quantile(0.1); q != time.Millisecond {
+		t.Errorf("Quantile(0.1) = %v, expected 1ms", q)
+	}
+	if q := fast.Quantile(0.9); q != time.Second {
+		t.Errorf("Quantile(0.9) = %v, expected 1s", q)
+	}
+
+	decoded, err := DurationFromBytes(fast.Marshal(nil))
+	assertNoError(t, err)
+	if !bytes.Equal(decoded.Marshal(nil), fast.Marshal(nil)) {
+		t.Errorf("The decoded digest is different")
+	}
+	if _, err := DurationFromBytes([]byte{1}); err == nil {
+		t.Errorf("Expected an error decoding a truncated digest")
+	}
+}
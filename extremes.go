@@ -0,0 +1,54 @@
+package tdigest
+
+import "math"
+
+// Min returns the smallest sample added to the digest, or NaN if it is
+// empty. It is exact: it is tracked separately from the centroids and
+// survives Compress, Merge and serialization. With WithQuantization it is
+// snapped to the grid like the samples. Unless the digest has too few
+// samples for WithMinSamples, it is also Quantile(0).
+func (t *TDigest) Min() float64 {
+	t.checkRead()
+
+	if t.count == 0 {
+		return math.NaN()
+	}
+	return t.min
+}
+
+// Max returns the largest sample added to the digest, or NaN if it is
+// empty. See Min.
+func (t *TDigest) Max() float64 {
+	t.checkRead()
+
+	if t.count == 0 {
+		return math.NaN()
+	}
+	return t.max
+}
+
+// pin returns the estimate x of the quantile q, replaced by the extremes
+// for q equal to 0 or 1 and kept within them otherwise.
+func (t *TDigest) pin(q, x float64) float64 {
+	if !(t.min <= t.max) {
+		return x
+	}
+
+	switch {
+	case q == 0:
+		return t.min
+	case q == 1:
+		return t.max
+	}
+	return math.Max(t.min, math.Min(t.max, x))
+}
+
+// observe widens the extremes of the digest to include [lo, hi].
+func (t *TDigest) observe(lo, hi float64) {
+	if lo < t.min {
+		t.min = lo
+	}
+	if hi > t.max {
+		t.max = hi
+	}
+}
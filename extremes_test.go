@@ -0,0 +1,148 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func assertExtremes(t *testing.T, name string, d *TDigest, min, max float64) {
+	t.Helper()
+	if d.Min() != min || d.Max() != max {
+		t.Errorf("%s: got extremes %v and %v, expected %v and %v", name, d.Min(), d.Max(), min, max)
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	tdigest := New(100)
+	if !math.IsNaN(tdigest.Min()) || !math.IsNaN(tdigest.Max()) {
+		t.Errorf("Expected NaN extremes for an empty digest")
+	}
+
+	min, max := math.Inf(1), math.Inf(-1)
+	for i := 0; i < 100000; i++ {
+		x := rand.NormFloat64()
+		min, max = math.Min(min, x), math.Max(max, x)
+		assertNoError(t, tdigest.Add(x))
+	}
+	assertExtremes(t, "Add", tdigest, min, max)
+
+	assertNoError(t, tdigest.Compress())
+	assertExtremes(t, "Compress", tdigest, min, max)
+
+	decoded, err := FromBytes(tdigest.Marshal(nil))
+	assertNoError(t, err)
+	assertExtremes(t, "FromBytes", decoded, min, max)
+
+	other := New(100)
+	assertNoError(t, other.Add(min-1))
+	assertNoError(t, other.Add(max+1))
+	assertNoError(t, tdigest.Merge(other))
+	assertExtremes(t, "Merge", tdigest, min-1, max+1)
+
+	rolled, err := Rollup([]*TDigest{decoded, other}, 50)
+	assertNoError(t, err)
+	assertExtremes(t, "Rollup", rolled, min-1, max+1)
+
+	removed, err := tdigest.RemoveValue(min-1, 1)
+	assertNoError(t, err)
+	if removed != 1 || tdigest.Min() < min || tdigest.Min() > tdigest.Quantile(0.001) {
+		t.Errorf("Expected the removed minimum to be replaced by an estimate, got %v", tdigest.Min())
+	}
+}
+
+func TestMinMaxMergeDestructive(t *testing.T) {
+	for _, receiver := range []*TDigest{New(100), New(50)} {
+		other := New(100)
+		for _, x := range []float64{3, 1, 4, 1, 5, 9, 2, 6} {
+			assertNoError(t, other.Add(x))
+		}
+		assertNoError(t, receiver.MergeDestructive(other))
+
+		assertExtremes(t, "MergeDestructive", receiver, 1, 9)
+		if !math.IsNaN(other.Min()) || !math.IsNaN(other.Max()) {
+			t.Errorf("Expected the consumed digest to be empty")
+		}
+
+		assertNoError(t, other.Add(42))
+		assertExtremes(t, "reused", other, 42, 42)
+	}
+}
+
+func TestMinMaxSerialization(t *testing.T) {
+	// digests of a few samples use the first and last means
+	small := New(100)
+	assertNoError(t, small.Add(2))
+	assertNoError(t, small.Add(-3))
+	decoded, err := FromBytes(small.Marshal(nil))
+	assertNoError(t, err)
+	assertExtremes(t, "small", decoded, -3, 2)
+
+	// the extremes survive the compact encoding of a compressed digest
+	compressed := New(10)
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, compressed.Add(float64(i)))
+	}
+	for compressed.summary.Len() > tinyMaxCentroids {
+		compressed = compressed.rescaled(0.5)
+		compressed.cluster()
+	}
+	serialized := compressed.Marshal(nil)
+	if serialized[0]>>4 != tinyEncoding {
+		t.Fatalf("Expected the tiny encoding for %d centroids", compressed.summary.Len())
+	}
+	decoded, err = FromBytes(serialized)
+	assertNoError(t, err)
+	assertExtremes(t, "tiny", decoded, 0, 999)
+
+	quantized := New(100, WithQuantization(0.5))
+	assertNoError(t, quantized.Add(0.9))
+	assertNoError(t, quantized.Add(2.2))
+	assertExtremes(t, "quantized", quantized, 1, 2)
+}
+
+func TestMinMaxSerializationExact(t *testing.T) {
+	// none of the extremes is a dyadic rational, so none is exactly stored
+	// by a float32 or a scaled integer mean
+	for _, test := range []struct {
+		name     string
+		encoding int32
+		values   []float64
+	}{
+		{"tiny", tinyEncoding, []float64{0.1, 0.2, 1.0 / 3}},
+		{"small", smallEncoding, append(spread(0.1, 1.0/3, 1000), 0.1, 1.0/3)},
+		{"large", largeEncoding, append(spread(0.1, 1e300/3, 1000), 0.1, 1e300/3)},
+	} {
+		tdigest := New(10)
+		for _, x := range test.values {
+			assertNoError(t, tdigest.Add(x))
+		}
+
+		buf := tdigest.Marshal(nil)
+		encoding := int32(buf[0] >> 4)
+		if encoding != tinyEncoding {
+			encoding = int32(binary.BigEndian.Uint32(buf))
+		}
+		if encoding != test.encoding {
+			t.Fatalf("%s: got encoding %d, expected %d", test.name, encoding, test.encoding)
+		}
+
+		decoded, err := FromBytes(buf)
+		assertNoError(t, err)
+		if math.Float64bits(decoded.Min()) != math.Float64bits(tdigest.Min()) ||
+			math.Float64bits(decoded.Max()) != math.Float64bits(tdigest.Max()) {
+			t.Errorf("%s: got extremes %v and %v, expected %v and %v", test.name,
+				decoded.Min(), decoded.Max(), tdigest.Min(), tdigest.Max())
+		}
+	}
+}
+
+// spread returns n values spread evenly over [lo, hi].
+func spread(lo, hi float64, n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = lo + (hi-lo)*float64(i)/float64(n-1)
+	}
+	return values
+}
@@ -1,7 +1,9 @@
 package tdigest
 
+// fen is a Fenwick tree of the centroid counts. Its sums are 64 bits wide
+// so that digests can hold more than 2^32 samples.
 type fen struct {
-	buf []uint32
+	buf []uint64
 }
 
 func lsb(i int) int {
@@ -9,16 +11,16 @@ func lsb(i int) int {
 }
 
 func (f fen) Clone() fen {
-	return fen{buf: append([]uint32(nil), f.buf...)}
+	return fen{buf: append([]uint64(nil), f.buf...)}
 }
 
 func (f *fen) accomodate(i int) {
 	if len(f.buf) <= i {
-		f.buf = append(f.buf, make([]uint32, i-len(f.buf)+1)...)
+		f.buf = append(f.buf, make([]uint64, i-len(f.buf)+1)...)
 	}
 }
 
-func (f *fen) Add(i int, delta uint32) {
+func (f *fen) Add(i int, delta uint64) {
 	f.accomodate(i)
 	for i < len(f.buf) {
 		f.buf[i] += delta
@@ -26,7 +28,7 @@ func (f *fen) Add(i int, delta uint32) {
 	}
 }
 
-func (f *fen) Range(i, j int) (sum uint32) {
+func (f *fen) Range(i, j int) (sum uint64) {
 	f.accomodate(j - 1)
 	for j > i {
 		sum += f.buf[j-1]
@@ -39,16 +41,16 @@ func (f *fen) Range(i, j int) (sum uint32) {
 	return sum
 }
 
-func (f *fen) Get(i int) uint32 {
+func (f *fen) Get(i int) uint64 {
 	return f.Range(i, i+1)
 }
 
-func (f *fen) Set(i int, value uint32) {
+func (f *fen) Set(i int, value uint64) {
 	delta := value - f.Range(i, i+1)
 	f.Add(i, delta)
 }
 
-func (f *fen) Sum(i int) (sum uint32) {
+func (f *fen) Sum(i int) (sum uint64) {
 	f.accomodate(i - 1)
 	for i > 0 {
 		sum += f.buf[i-1]
@@ -56,3 +58,35 @@ func (f *fen) Sum(i int) (sum uint32) {
 	}
 	return sum
 }
+
+// Rebuild resets the tree to hold values starting at position offset, with
+// zeros before them, in linear time.
+func (f *fen) Rebuild(offset int, values []uint32) {
+	f.buf = append(f.buf[:0], make([]uint64, offset)...)
+	for _, v := range values {
+		f.buf = append(f.buf, uint64(v))
+	}
+	for i := range f.buf {
+		if j := i + lsb(i+1); j < len(f.buf) {
+			f.buf[j] += f.buf[i]
+		}
+	}
+}
+
+// Search returns the largest i such that Sum(i) <= sum, assuming no value
+// is negative, in O(log n).
+func (f *fen) Search(sum uint64) int {
+	step := 1
+	for step*2 <= len(f.buf) {
+		step *= 2
+	}
+
+	i := 0
+	for ; step > 0; step /= 2 {
+		if i+step <= len(f.buf) && f.buf[i+step-1] <= sum {
+			i += step
+			sum -= f.buf[i-1]
+		}
+	}
+	return i
+}
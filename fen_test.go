@@ -5,7 +5,7 @@ import "testing"
 func TestFenwickTree(t *testing.T) {
 	var f fen
 
-	assertSum := func(i int, v uint32) {
+	assertSum := func(i int, v uint64) {
 		t.Helper()
 		if got := f.Sum(i); got != v {
 			t.Logf("fen: %v", f)
@@ -13,7 +13,7 @@ func TestFenwickTree(t *testing.T) {
 		}
 	}
 
-	assertGet := func(i int, v uint32) {
+	assertGet := func(i int, v uint64) {
 		t.Helper()
 		if got := f.Get(i); got != v {
 			t.Logf("fen: %v", f)
@@ -53,3 +53,48 @@ func TestFenwickTree(t *testing.T) {
 	assertGet(4, 1)
 	assertSum(5, 9)
 }
+
+func TestFenwickTreeRebuild(t *testing.T) {
+	values := []uint32{3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5}
+
+	var f fen
+	f.Set(20, 7)
+	for _, offset := range []int{0, 1, 6} {
+		f.Rebuild(offset, values)
+
+		var sum uint64
+		for i := 0; i < offset; i++ {
+			if got := f.Get(i); got != 0 {
+				t.Errorf("offset %d get %d: got %v != exp 0", offset, i, got)
+			}
+		}
+		for i, v := range values {
+			if got := f.Sum(offset + i); got != sum {
+				t.Errorf("offset %d sum %d: got %v != exp %v", offset, i, got, sum)
+			}
+			if got := f.Get(offset + i); got != uint64(v) {
+				t.Errorf("offset %d get %d: got %v != exp %v", offset, i, got, v)
+			}
+			sum += uint64(v)
+		}
+	}
+}
+
+func TestFenwickTreeSearch(t *testing.T) {
+	values := []uint32{0, 0, 3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5}
+
+	var f fen
+	f.Rebuild(0, values)
+
+	for sum := uint64(0); sum <= 50; sum++ {
+		expected := 0
+		for i := 0; i <= len(values); i++ {
+			if f.Sum(i) <= sum {
+				expected = i
+			}
+		}
+		if got := f.Search(sum); got != expected {
+			t.Errorf("search %d: got %d != exp %d", sum, got, expected)
+		}
+	}
+}
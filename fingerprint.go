@@ -0,0 +1,64 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// Equals reports whether both digests have the same compression, count and
+// centroids.
+func (t *TDigest) Equals(other *TDigest) bool {
+	t.checkRead()
+	other.checkRead()
+
+	if t.compression != other.compression || t.delta != other.delta ||
+		t.count != other.count || t.summary.Len() != other.summary.Len() {
+		return false
+	}
+
+	for i := 0; i < t.summary.Len(); i++ {
+		if t.summary.Mean(i) != other.summary.Mean(i) ||
+			t.summary.Count(i) != other.summary.Count(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fingerprint returns a 64-bit FNV-1a hash of the compression, count and
+// centroids of the digest. It only changes when that content changes, is
+// the same for any two digests for which Equals is true, and does not depend
+// on the process or the serialization format.
+func (t *TDigest) Fingerprint() uint64 {
+	t.checkRead()
+
+	h := fnv.New64a()
+	var scratch [8]byte
+
+	write := func(v uint64) {
+		binary.BigEndian.PutUint64(scratch[:], v)
+		_, _ = h.Write(scratch[:])
+	}
+
+	write(canonicalBits(t.compression))
+	write(uint64(t.delta))
+	write(t.count)
+	write(uint64(t.summary.Len()))
+	t.summary.ForEach(func(mean float64, count uint32) bool {
+		write(canonicalBits(mean))
+		write(uint64(count))
+		return true
+	})
+
+	return h.Sum64()
+}
+
+// canonicalBits returns the bits of x, with -0 mapped to 0 so that values
+// comparing equal have the same bits.
+func canonicalBits(x float64) uint64 {
+	if x == 0 {
+		return 0
+	}
+	return math.Float64bits(x)
+}
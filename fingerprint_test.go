@@ -0,0 +1,48 @@
+package tdigest
+
+import "testing"
+
+func TestEqualsAndFingerprint(t *testing.T) {
+	t1 := New(100)
+	for i := 0; i < 50; i++ {
+		assertNoError(t, t1.Add(float64(i*10)))
+	}
+
+	t2 := New(100)
+	assertNoError(t, t2.Merge(t1))
+
+	t3, err := FromBytes(t1.Marshal(nil))
+	assertNoError(t, err)
+
+	for _, other := range []*TDigest{t2, t3} {
+		if !t1.Equals(other) {
+			t.Errorf("Expected digests to be equal")
+		}
+		if t1.Fingerprint() != other.Fingerprint() {
+			t.Errorf("Expected equal digests to have equal fingerprints")
+		}
+	}
+
+	assertNoError(t, t2.AddWeighted(0, 1))
+	if t1.Equals(t2) {
+		t.Errorf("Expected digests with different counts to differ")
+	}
+	if t1.Fingerprint() == t2.Fingerprint() {
+		t.Errorf("Expected a single count difference to change the fingerprint")
+	}
+
+	if New(100).Fingerprint() == New(50).Fingerprint() {
+		t.Errorf("Expected the compression to change the fingerprint")
+	}
+}
+
+func TestFingerprintStable(t *testing.T) {
+	tdigest := New(100)
+	for _, x := range []float64{1, 2, 3, -0.5, 1e10} {
+		assertNoError(t, tdigest.Add(x))
+	}
+
+	if got := tdigest.Fingerprint(); got != 0x4f04eefd11f015dc {
+		t.Errorf("Fingerprint changed: got %#x", got)
+	}
+}
@@ -0,0 +1,24 @@
+package tdigest
+
+import "math"
+
+// WithSmallFootprint makes the digest suitable for devices with little
+// memory, such as microcontrollers running TinyGo. The digest never holds
+// more than maxCentroids centroids: it compresses itself as soon as it
+// reaches them, down to at most maxCentroids/2 with the scale function of
+// WithMaxCentroidBound. Its buffers start empty and grow up to maxCentroids
+// entries of 20 bytes each, 28 with WithCentroidVariance, and inserting
+// and compressing allocate nothing once they are full.
+//
+// The compression passed to New is replaced by the one corresponding to
+// maxCentroids/2 centroids. It panics if maxCentroids is less than 2.
+func WithSmallFootprint(maxCentroids int) Option {
+	if maxCentroids < 2 {
+		panic("maxCentroids must be at least 2")
+	}
+	return func(t *TDigest) {
+		t.maxCentroids = maxCentroids
+		t.delta = maxCentroids / 2
+		t.compression = float64(t.delta) / math.Pi
+	}
+}
@@ -0,0 +1,103 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"unsafe"
+)
+
+func TestSmallFootprint(t *testing.T) {
+	const budget = 4096
+
+	rng := rand.New(rand.NewSource(0xf007))
+	tdigest := New(100, WithSmallFootprint(128))
+	data := make([]float64, 100000)
+
+	maxBytes, maxLen := 0, 0
+	for i := range data {
+		data[i] = rng.ExpFloat64()
+		assertNoError(t, tdigest.Add(data[i]))
+
+		if b := tdigest.SizeBytes(); b > maxBytes {
+			maxBytes = b
+		}
+		if n := tdigest.summary.Len(); n > maxLen {
+			maxLen = n
+		}
+	}
+
+	t.Logf("state: at most %d bytes and %d centroids", maxBytes, maxLen)
+	if maxBytes > budget || maxLen > 128 {
+		t.Errorf("Expected at most %d bytes and 128 centroids, got %d bytes and %d centroids", budget, maxBytes, maxLen)
+	}
+
+	allocs := testing.AllocsPerRun(10000, func() {
+		_ = tdigest.Add(rng.ExpFloat64())
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations once the buffers are full, got %v per Add", allocs)
+	}
+
+	sort.Float64s(data)
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99} {
+		if e := math.Abs(cdf(tdigest.Quantile(q), data) - q); e > 0.01 {
+			t.Errorf("Quantile(%v) is off by %.4f in rank", q, e)
+		}
+	}
+
+	shouldPanic(func() { WithSmallFootprint(1) }, t, "WithSmallFootprint(1) should panic")
+}
+
+func TestSmallFootprintStartsEmpty(t *testing.T) {
+	tdigest := New(100, WithSmallFootprint(128), WithCentroidVariance())
+	if b := tdigest.SizeBytes(); b > int(unsafe.Sizeof(*tdigest)+unsafe.Sizeof(*tdigest.summary)) {
+		t.Errorf("Expected no buffers before the first sample, got %d bytes", b)
+	}
+
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, tdigest.Add(float64(i%1000)))
+	}
+	if n := cap(tdigest.summary.means); n > 128 || cap(tdigest.summary.m2) != n {
+		t.Errorf("Expected the buffers to stay within 128 centroids, got %d and %d", n, cap(tdigest.summary.m2))
+	}
+}
+
+func TestSmallFootprintSerialization(t *testing.T) {
+	for name, opts := range optionVariants(nil) {
+		t1 := New(100, append(opts, WithSmallFootprint(64))...)
+		for i := 0; i < 10000; i++ {
+			assertNoError(t, t1.Add(rand.Float64()))
+		}
+
+		t2, err := FromBytes(t1.Marshal(nil))
+		assertNoError(t, err)
+		if t2.maxCentroids != 64 || t2.delta != t1.delta || t2.summary.limit != 64 {
+			t.Fatalf("%s: got maxCentroids %d, delta %d and limit %d, expected 64, %d and 64", name,
+				t2.maxCentroids, t2.delta, t2.summary.limit, t1.delta)
+		}
+
+		// the decoded digest keeps to the limit
+		for i := 0; i < 10000; i++ {
+			assertNoError(t, t2.Add(rand.Float64()))
+			if t2.summary.Len() > 64 || cap(t2.summary.means) > 64 {
+				t.Fatalf("%s: got %d centroids and a capacity of %d, expected at most 64", name,
+					t2.summary.Len(), cap(t2.summary.means))
+			}
+		}
+		if err := t2.Rescale(10); err == nil {
+			t.Errorf("%s: Expected the decoded digest to refuse rescaling", name)
+		}
+	}
+
+	// a footprint smaller than the centroids is rejected
+	t1 := New(100, WithSmallFootprint(64))
+	for i := 0; i < 10; i++ {
+		assertNoError(t, t1.Add(float64(i)))
+	}
+	t1.maxCentroids = 8
+	if _, err := FromBytes(t1.Marshal(nil)); err == nil {
+		t.Errorf("Expected an error decoding more centroids than the footprint allows")
+	}
+}
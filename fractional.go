@@ -0,0 +1,39 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+)
+
+// AddWeightedF is like AddWeighted for a fractional weight, such as the
+// inverse of the probability with which the sample was kept. The digest
+// counts whole samples, so the weight is rounded to one of the two closest
+// counts at random, up with a probability equal to its fractional part: a
+// weight of 3.7 counts 4 about 7 times out of 10 and 3 otherwise. Unlike rounding to
+// the closest count this does not bias the estimates, which converge to
+// those of the exact weights as samples accumulate, and the existing
+// encoding keeps working. A weight that rounds to 0 adds nothing.
+//
+// It returns ErrInvalidValue if value is rejected by AddWeighted, and an
+// error if weight is not positive or larger than the largest count of
+// AddWeighted.
+func (t *TDigest) AddWeightedF(value float64, weight float64) (err error) {
+	if err := t.checkValue(value); err != nil {
+		return err
+	}
+	if !(weight > 0) || weight > math.MaxUint32 {
+		return fmt.Errorf("invalid weight: %v", weight)
+	}
+
+	t.beginWrite()
+	count, frac := math.Modf(weight)
+	if float64(t.pcg.Uint32()) < frac*(1<<32) {
+		count++
+	}
+	if count > 0 {
+		err = t.addWeighted(value, uint32(count))
+	}
+	t.endWrite()
+	t.checkThresholds()
+	return err
+}
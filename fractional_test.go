@@ -0,0 +1,64 @@
+package tdigest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAddWeightedF(t *testing.T) {
+	// rounding the weights to the closest count would leave out the samples
+	// at 0 entirely, and give those at 2 a weight of 2 instead of 1.5
+	tdigest := New(100)
+	for i := 0; i < 20000; i++ {
+		assertNoError(t, tdigest.AddWeightedF(0, 0.3))
+		assertNoError(t, tdigest.AddWeightedF(1, 3.7))
+		assertNoError(t, tdigest.AddWeightedF(2, 1.5))
+	}
+
+	total := 20000 * (0.3 + 3.7 + 1.5)
+	if got := float64(tdigest.Count()); math.Abs(got-total) > 0.01*total {
+		t.Errorf("Count() = %v, expected about %v", got, total)
+	}
+	for _, c := range []struct{ x, expected float64 }{
+		{0.5, 0.3 / 5.5},
+		{1.5, 4.0 / 5.5},
+	} {
+		if got := tdigest.CDF(c.x); math.Abs(got-c.expected) > 0.01 {
+			t.Errorf("CDF(%v) = %v, expected about %v", c.x, got, c.expected)
+		}
+	}
+	if got, expected := tdigest.Mean(), (3.7+2*1.5)/5.5; math.Abs(got-expected) > 0.01 {
+		t.Errorf("Mean() = %v, expected about %v", got, expected)
+	}
+
+	// 3.7 rounds up with a probability of 0.7
+	rounded, up := New(100), 0
+	for i := 0; i < 10000; i++ {
+		before := rounded.Count()
+		assertNoError(t, rounded.AddWeightedF(1, 3.7))
+		if rounded.Count()-before == 4 {
+			up++
+		}
+	}
+	if rate := float64(up) / 10000; math.Abs(rate-0.7) > 0.02 {
+		t.Errorf("A weight of 3.7 counted 4 at a rate of %v, expected about 0.7", rate)
+	}
+
+	whole := New(100)
+	assertNoError(t, whole.AddWeightedF(5, 3))
+	if whole.Count() != 3 {
+		t.Errorf("A whole weight of 3 counted %d samples", whole.Count())
+	}
+
+	for _, weight := range []float64{0, -1, math.NaN(), math.Inf(1), 1 << 33} {
+		if err := whole.AddWeightedF(1, weight); err == nil {
+			t.Errorf("Expected an error for weight %v", weight)
+		}
+	}
+	if err := whole.AddWeightedF(math.NaN(), 1); err != ErrInvalidValue {
+		t.Errorf("Expected ErrInvalidValue for NaN, got %v", err)
+	}
+	if whole.Count() != 3 || whole.Min() != 5 || whole.Max() != 5 {
+		t.Errorf("Rejected samples changed the digest")
+	}
+}
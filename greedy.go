@@ -0,0 +1,14 @@
+package tdigest
+
+// WithGreedyCandidates makes the digest choose deterministically which
+// centroid a new sample is merged into when several of the closest ones
+// have room for it: the lightest one, and the one with the lowest mean among
+// equally light ones. By default one of them is picked at random.
+//
+// The choice is recorded by Marshal, so that a decoded digest keeps making
+// the same ones.
+func WithGreedyCandidates() Option {
+	return func(t *TDigest) {
+		t.greedy = true
+	}
+}
@@ -0,0 +1,80 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestGreedyCandidatesAccuracy(t *testing.T) {
+	qs := []float64{0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999}
+
+	for _, discrete := range []bool{false, true} {
+		for _, compression := range []float64{10, 100} {
+			// total rank error over several data sets, for digests filled
+			// directly and for digests merged from parts
+			var random, greedy float64
+			for seed := int64(0); seed < 5; seed++ {
+				rng := rand.New(rand.NewSource(seed))
+				data := make([]float64, 50000)
+				for i := range data {
+					data[i] = rng.Float64()
+					if discrete {
+						data[i] = math.Floor(1000*data[i]) / 1000
+					}
+				}
+
+				build := func(parts int, opts ...Option) *TDigest {
+					d := New(compression, opts...)
+					for p := 0; p < parts; p++ {
+						part := New(compression, opts...)
+						for i := p; i < len(data); i += parts {
+							assertNoError(t, part.Add(data[i]))
+						}
+						assertNoError(t, d.Merge(part))
+					}
+					return d
+				}
+
+				sorted := append([]float64(nil), data...)
+				sort.Float64s(sorted)
+				for _, parts := range []int{1, 20} {
+					r, g := build(parts), build(parts, WithGreedyCandidates())
+					for _, q := range qs {
+						random += math.Abs(cdf(r.Quantile(q), sorted) - q)
+						greedy += math.Abs(cdf(g.Quantile(q), sorted) - q)
+					}
+				}
+			}
+
+			t.Logf("discrete=%v compression=%v: total rank error %.5f random, %.5f greedy",
+				discrete, compression, random, greedy)
+			if greedy > 1.1*random {
+				t.Errorf("discrete=%v compression=%v: expected greedy candidates to match the accuracy of random ones, got %.5f and %.5f",
+					discrete, compression, greedy, random)
+			}
+		}
+	}
+}
+
+func TestGreedyCandidatesSerialization(t *testing.T) {
+	for _, n := range []int{5, 1000} {
+		t1 := New(100, WithGreedyCandidates())
+		for i := 0; i < n; i++ {
+			assertNoError(t, t1.Add(float64(i%10)))
+		}
+
+		t2, err := FromBytes(t1.Marshal(nil))
+		assertNoError(t, err)
+		if !t2.greedy {
+			t.Errorf("n=%d: expected the option to survive serialization", n)
+		}
+
+		plain, err := FromBytes(New(100).Marshal(nil))
+		assertNoError(t, err)
+		if plain.greedy {
+			t.Errorf("Expected the option to stay off")
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package tdigest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Bucket is a range of values [Lo, Hi] holding Count samples.
+type Bucket struct {
+	Lo, Hi float64
+	Count  uint64
+}
+
+// Spread controls where AddHistogram places the samples of a bucket.
+type Spread int
+
+const (
+	// SpreadUniform spreads the samples of a bucket evenly over its range.
+	SpreadUniform Spread = iota
+	// SpreadMidpoint places all the samples of a bucket at its midpoint.
+	SpreadMidpoint
+)
+
+// histogramPoints is the number of points SpreadUniform divides a bucket's
+// samples between.
+const histogramPoints = 16
+
+// AddHistogram adds the samples described by a histogram to the digest.
+//
+// With SpreadUniform each bucket is added as several weighted points evenly
+// spaced within it, so quantiles falling inside a wide bucket interpolate
+// across it instead of snapping to a single value. Buckets with an infinite
+// bound have all of their samples placed at their finite bound, regardless
+// of spread.
+//
+// Buckets may be given in any order, but it is an error for buckets to
+// overlap (other than sharing a bound), for a bucket to have Lo > Hi, a NaN
+// bound or two infinite bounds. Nothing is added if any bucket is invalid,
+// and ErrInvalidValue is returned, adding nothing either, if Add would reject
+// some of the points the samples are placed at, such as the midpoint of a
+// bucket too wide for its width to be finite.
+func (t *TDigest) AddHistogram(buckets []Bucket, spread Spread) error {
+	sorted := append([]Bucket(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Lo < sorted[j].Lo || sorted[i].Lo == sorted[j].Lo && sorted[i].Hi < sorted[j].Hi
+	})
+
+	for i, b := range sorted {
+		switch {
+		case math.IsNaN(b.Lo) || math.IsNaN(b.Hi):
+			return errors.New("histogram bucket bounds must not be NaN")
+		case b.Lo > b.Hi:
+			return fmt.Errorf("inverted histogram bucket [%v, %v]", b.Lo, b.Hi)
+		case math.IsInf(b.Lo, 0) && math.IsInf(b.Hi, 0):
+			return fmt.Errorf("histogram bucket [%v, %v] has no finite bound", b.Lo, b.Hi)
+		case i > 0 && sorted[i-1].Hi > b.Lo:
+			return fmt.Errorf("overlapping histogram buckets [%v, %v] and [%v, %v]",
+				sorted[i-1].Lo, sorted[i-1].Hi, b.Lo, b.Hi)
+		}
+	}
+
+	check := func(value float64, _ uint64) error {
+		return t.checkValue(value)
+	}
+	for _, b := range sorted {
+		if err := b.forEachPoint(spread, check); err != nil {
+			return err
+		}
+	}
+
+	defer t.checkThresholds()
+	t.beginWrite()
+	defer t.endWrite()
+
+	for _, b := range sorted {
+		if err := b.forEachPoint(spread, t.addCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachPoint calls fn with every point the samples of b are placed at and
+// the number of samples placed there, stopping at the first error.
+func (b Bucket) forEachPoint(spread Spread, fn func(value float64, count uint64) error) error {
+	switch {
+	case math.IsInf(b.Lo, -1):
+		return fn(b.Hi, b.Count)
+	case math.IsInf(b.Hi, 1):
+		return fn(b.Lo, b.Count)
+	case spread == SpreadMidpoint || b.Lo == b.Hi:
+		return fn(b.Lo+(b.Hi-b.Lo)/2, b.Count)
+	}
+
+	points := uint64(histogramPoints)
+	if b.Count < points {
+		points = b.Count
+	}
+
+	width := (b.Hi - b.Lo) / float64(points)
+	for i := uint64(0); i < points; i++ {
+		// distribute the remainder over the first points
+		count := b.Count / points
+		if i < b.Count%points {
+			count++
+		}
+		if err := fn(b.Lo+(float64(i)+0.5)*width, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addCount adds value with a weight that may not fit in a single centroid.
+func (t *TDigest) addCount(value float64, count uint64) error {
+	for count > 0 {
+		c := uint32(math.MaxUint32)
+		if count < math.MaxUint32 {
+			c = uint32(count)
+		}
+		if err := t.addWeighted(value, c); err != nil {
+			return err
+		}
+		count -= uint64(c)
+	}
+	return nil
+}
+
+// Histogram returns the number of samples in each of the buckets delimited
+// by bounds: the samples at most bounds[0], then those in (bounds[i-1],
+// bounds[i]] for every i, and finally the samples greater than the last
+// bound. The counts are estimated with CDF and rounded so that they add up
+// to Count.
+//
+// The bounds must be strictly increasing, will panic otherwise.
+func (t *TDigest) Histogram(bounds []float64) []uint64 {
+	for i, b := range bounds {
+		if math.IsNaN(b) || (i > 0 && !(bounds[i-1] < b)) {
+			panic("bounds must be strictly increasing")
+		}
+	}
+	t.checkRead()
+
+	counts := make([]uint64, len(bounds)+1)
+	if t.summary.Len() == 0 {
+		return counts
+	}
+
+	w := cdfWalk{t: t}
+	var previous uint64
+	for i, b := range bounds {
+		var rank float64
+		if t.summary.m2 != nil {
+			rank = t.cdfSpread(b) * float64(t.count)
+		} else {
+			rank = w.cdf(b) * float64(t.count)
+		}
+
+		cumulative := uint64(math.Round(rank))
+		if cumulative > t.count {
+			cumulative = t.count
+		}
+		if cumulative > previous {
+			counts[i] = cumulative - previous
+			previous = cumulative
+		}
+	}
+	counts[len(bounds)] = t.count - previous
+	return counts
+}
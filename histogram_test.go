@@ -0,0 +1,157 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestAddHistogram(t *testing.T) {
+	const width = 0.25
+
+	data := make([]float64, 100000)
+	counts := make(map[int]uint64)
+	for i := range data {
+		data[i] = rand.ExpFloat64()
+		counts[int(data[i]/width)]++
+	}
+	sort.Float64s(data)
+
+	buckets := make([]Bucket, 0, len(counts))
+	for i, c := range counts {
+		buckets = append(buckets, Bucket{Lo: float64(i) * width, Hi: float64(i+1) * width, Count: c})
+	}
+
+	for _, spread := range []Spread{SpreadUniform, SpreadMidpoint} {
+		tdigest := New(100)
+		assertNoError(t, tdigest.AddHistogram(buckets, spread))
+
+		if tdigest.Count() != uint64(len(data)) {
+			t.Errorf("spread=%d: expected count %d, got %d", spread, len(data), tdigest.Count())
+		}
+
+		for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+			assertDifferenceFromQuantile(data, tdigest, q, width, t)
+		}
+	}
+}
+
+func TestAddHistogramUniformInterpolates(t *testing.T) {
+	tdigest := New(100)
+	assertNoError(t, tdigest.AddHistogram([]Bucket{{Lo: 0, Hi: 100, Count: 1000}}, SpreadUniform))
+
+	for _, q := range []float64{0.1, 0.25, 0.5, 0.75, 0.9} {
+		if got := tdigest.Quantile(q); math.Abs(got-100*q) > 100.0/histogramPoints {
+			t.Errorf("Quantile(%.2f) = %.4f, expected about %.4f", q, got, 100*q)
+		}
+	}
+}
+
+func TestAddHistogramOpenEnded(t *testing.T) {
+	tdigest := New(100)
+	assertNoError(t, tdigest.AddHistogram([]Bucket{
+		{Lo: math.Inf(-1), Hi: 0, Count: 10},
+		{Lo: 0, Hi: 10, Count: 80},
+		{Lo: 10, Hi: math.Inf(1), Count: 10},
+	}, SpreadUniform))
+
+	if tdigest.Count() != 100 {
+		t.Errorf("Expected count 100, got %d", tdigest.Count())
+	}
+	if got := tdigest.summary.Mean(0); got != 0 {
+		t.Errorf("Expected the open lower bucket at 0, got %.4f", got)
+	}
+	if got := tdigest.summary.Mean(tdigest.summary.Len() - 1); got != 10 {
+		t.Errorf("Expected the open upper bucket at 10, got %.4f", got)
+	}
+}
+
+func TestAddHistogramPointBucket(t *testing.T) {
+	// a bucket holding a single point may share its bound with the next
+	// one, whichever order they are given in
+	point, wide := Bucket{Lo: 1, Hi: 1, Count: 1}, Bucket{Lo: 1, Hi: 2, Count: 2}
+	for _, buckets := range [][]Bucket{{point, wide}, {wide, point}} {
+		tdigest := New(100)
+		assertNoError(t, tdigest.AddHistogram(buckets, SpreadUniform))
+		if tdigest.Count() != 3 || tdigest.Min() != 1 {
+			t.Errorf("Expected 3 samples from 1 for %v, got %d from %v", buckets, tdigest.Count(), tdigest.Min())
+		}
+	}
+}
+
+func TestAddHistogramInvalid(t *testing.T) {
+	invalid := [][]Bucket{
+		{{Lo: 1, Hi: 0, Count: 1}},
+		{{Lo: 0, Hi: 2, Count: 1}, {Lo: 1, Hi: 3, Count: 1}},
+		{{Lo: 2, Hi: 3, Count: 1}, {Lo: 0, Hi: 2.5, Count: 1}},
+		{{Lo: math.NaN(), Hi: 1, Count: 1}},
+		{{Lo: math.Inf(-1), Hi: math.Inf(1), Count: 1}},
+		// the width of the second bucket overflows, making its points
+		// infinite, while the first one is valid
+		{{Lo: math.Inf(-1), Hi: -math.MaxFloat64, Count: 10}, {Lo: -math.MaxFloat64, Hi: math.MaxFloat64, Count: 1}},
+	}
+
+	for _, buckets := range invalid {
+		for _, spread := range []Spread{SpreadUniform, SpreadMidpoint} {
+			tdigest := New(100)
+			if err := tdigest.AddHistogram(buckets, spread); err == nil {
+				t.Errorf("Expected %v to be rejected", buckets)
+			}
+			if tdigest.Count() != 0 {
+				t.Errorf("Expected nothing to be added for %v", buckets)
+			}
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	bounds := []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	for name, opts := range optionVariants(nil) {
+		tdigest := New(100, opts...)
+		exact := make([]uint64, len(bounds)+1)
+		for i := 0; i < 100000; i++ {
+			x := math.Exp(rand.NormFloat64()) / 4
+			assertNoError(t, tdigest.Add(x))
+			exact[sort.SearchFloat64s(bounds, x)]++
+		}
+
+		counts := tdigest.Histogram(bounds)
+		if len(counts) != len(bounds)+1 {
+			t.Fatalf("%s: got %d buckets, expected %d", name, len(counts), len(bounds)+1)
+		}
+
+		// every bucket is within 0.5% of the samples of the exact one
+		var total uint64
+		for i, c := range counts {
+			total += c
+			if math.Abs(float64(c)-float64(exact[i])) > 0.005*float64(tdigest.Count()) {
+				t.Errorf("%s: bucket %d: got %d, expected about %d", name, i, c, exact[i])
+			}
+		}
+		if total != tdigest.Count() {
+			t.Errorf("%s: buckets add up to %d, expected %d", name, total, tdigest.Count())
+		}
+	}
+
+	if counts := New(100).Histogram(bounds); len(counts) != len(bounds)+1 || counts[0] != 0 {
+		t.Errorf("Expected empty buckets for an empty digest, got %v", counts)
+	}
+	tdigest := New(100)
+	assertNoError(t, tdigest.AddWeighted(1, 10))
+	if counts := tdigest.Histogram(nil); len(counts) != 1 || counts[0] != 10 {
+		t.Errorf("Expected a single bucket of every sample, got %v", counts)
+	}
+
+	for _, bounds := range [][]float64{{1, 1}, {2, 1}, {math.NaN()}, {0, math.NaN(), 1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for %v", bounds)
+				}
+			}()
+			tdigest.Histogram(bounds)
+		}()
+	}
+}
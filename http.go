@@ -0,0 +1,76 @@
+//go:build !tinygo
+
+package tdigest
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware returns HTTP middleware observing the duration in seconds of
+// every request into the digest of set labeled by routeKey(r).
+func Middleware(set *Set, routeKey func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeKey(r)
+			start := time.Now()
+			next.ServeHTTP(w, r)
+
+			// durations are never NaN, so this cannot fail
+			_ = set.Observe(route, time.Since(start).Seconds())
+		})
+	}
+}
+
+// QuantileHandler returns a handler serving the count and the quantiles qs
+// of every digest of set as a JSON object keyed by label, for example
+//
+//	{"/users": {"count": 120, "p50": 0.012, "p95": 0.051, "p99": 0.08}}
+//
+// The quantiles default to 0.5, 0.95 and 0.99. Empty digests report null
+// quantiles, as do digests with too few samples for a quantile when set was
+// created with WithMinSamples.
+//
+// The quantiles are fixed when the handler is created, not read from the
+// requests: it panics right away if some value of qs is not between 0 and 1
+// (inclusive).
+func QuantileHandler(set *Set, qs ...float64) http.Handler {
+	if len(qs) == 0 {
+		qs = []float64{0.5, 0.95, 0.99}
+	}
+
+	names := make([]string, len(qs))
+	for i, q := range qs {
+		if !(q >= 0 && q <= 1) {
+			panic("q must be between 0 and 1 (inclusive)")
+		}
+		// rounded so that 0.07 is p7 rather than p7.000000000000001
+		names[i] = "p" + strconv.FormatFloat(math.Round(q*1e6)/1e4, 'f', -1, 64)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := make(map[string]map[string]interface{})
+		for _, label := range set.Labels() {
+			c := set.Get(label)
+
+			c.mu.Lock()
+			stats := map[string]interface{}{"count": c.t.Count()}
+			for i, q := range qs {
+				if v := c.t.Quantile(q); !math.IsNaN(v) {
+					stats[names[i]] = v
+				} else {
+					stats[names[i]] = nil
+				}
+			}
+			c.mu.Unlock()
+
+			out[label] = stats
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	})
+}
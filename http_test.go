@@ -0,0 +1,130 @@
+//go:build !tinygo
+
+package tdigest
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	set := NewSet(100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Millisecond)
+	})
+	mux.Handle("/quantiles", QuantileHandler(set))
+
+	server := httptest.NewServer(Middleware(set, func(r *http.Request) string {
+		return r.URL.Path
+	})(mux))
+	defer server.Close()
+
+	const clients, perClient = 4, 75
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perClient; i++ {
+				for _, route := range []string{"/fast", "/slow"} {
+					resp, err := http.Get(server.URL + route)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					resp.Body.Close()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, route := range []string{"/fast", "/slow"} {
+		if got := set.Get(route).Count(); got != clients*perClient {
+			t.Errorf("%s: expected %d requests, got %d", route, clients*perClient, got)
+		}
+	}
+	if slow := set.Get("/slow").Quantile(0.5); slow < 0.002 {
+		t.Errorf("Expected a median of at least 2ms for /slow, got %v", slow)
+	}
+	if fast, slow := set.Get("/fast").Quantile(0.5), set.Get("/slow").Quantile(0.5); fast >= slow {
+		t.Errorf("Expected /fast to be faster than /slow, got %v and %v", fast, slow)
+	}
+
+	resp, err := http.Get(server.URL + "/quantiles")
+	assertNoError(t, err)
+	defer resp.Body.Close()
+
+	var stats map[string]map[string]float64
+	assertNoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	slow := stats["/slow"]
+	if slow["count"] != clients*perClient || slow["p50"] < 0.002 || slow["p99"] < slow["p50"] {
+		t.Errorf("Unexpected stats for /slow: %v", slow)
+	}
+}
+
+func TestSetObserveAllocations(t *testing.T) {
+	set := NewSet(100)
+	assertNoError(t, set.Observe("route", 1))
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		_ = set.Observe("route", 1)
+	})
+	if allocs > 0 {
+		t.Errorf("Expected Observe on an existing label not to allocate, got %v allocations", allocs)
+	}
+
+	if labels := set.Labels(); len(labels) != 1 || labels[0] != "route" {
+		t.Errorf("Unexpected labels %v", labels)
+	}
+}
+
+func TestWithMinSamplesHandler(t *testing.T) {
+	set := NewSet(100, WithMinSamples(10))
+	for i := 0; i < 100; i++ {
+		assertNoError(t, set.Observe("/users", float64(i)))
+	}
+
+	recorder := httptest.NewRecorder()
+	QuantileHandler(set, 0.5, 0.95).ServeHTTP(recorder, httptest.NewRequest("GET", "/quantiles", nil))
+
+	var out map[string]map[string]interface{}
+	assertNoError(t, json.Unmarshal(recorder.Body.Bytes(), &out))
+	if out["/users"]["p50"] == nil || out["/users"]["p95"] != nil {
+		t.Errorf("Expected p50 and no p95 with 5 samples above it, got %v", out["/users"])
+	}
+}
+
+func TestQuantileHandlerKeys(t *testing.T) {
+	set := NewSet(100)
+	assertNoError(t, set.Observe("/users", 1))
+
+	recorder := httptest.NewRecorder()
+	QuantileHandler(set, 0, 0.07, 0.5, 0.999, 1).ServeHTTP(recorder, httptest.NewRequest("GET", "/quantiles", nil))
+
+	var out map[string]map[string]interface{}
+	assertNoError(t, json.Unmarshal(recorder.Body.Bytes(), &out))
+	for _, key := range []string{"count", "p0", "p7", "p50", "p99.9", "p100"} {
+		if _, ok := out["/users"][key]; !ok {
+			t.Errorf("Expected a %s key, got %v", key, out["/users"])
+		}
+	}
+	if len(out["/users"]) != 6 {
+		t.Errorf("Unexpected keys in %v", out["/users"])
+	}
+}
+
+func TestQuantileHandlerInvalid(t *testing.T) {
+	set := NewSet(100)
+	for _, q := range []float64{-0.1, 1.1, math.NaN()} {
+		shouldPanic(func() { QuantileHandler(set, 0.5, q) }, t, "An invalid quantile should panic")
+	}
+}
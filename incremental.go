@@ -0,0 +1,67 @@
+package tdigest
+
+// WithIncrementalCompression spreads the cost of compression over many
+// insertions instead of paying it all at once in whichever Add crosses the
+// compression trigger.
+//
+// Once the digest reaches half of its compression trigger, every insertion
+// merges adjacent centroids among at most budget of them, sweeping the
+// summary from left to right until a whole pass has been made. A converged
+// digest is as accurate as one compressed eagerly. If insertions outpace
+// the sweep, which can only happen with very small budgets, the digest
+// falls back to compressing eagerly once it holds twice as many centroids
+// as the trigger.
+//
+// It panics if budget is less than 1.
+func WithIncrementalCompression(budget int) Option {
+	if budget < 1 {
+		panic("budget must be at least 1")
+	}
+	return func(t *TDigest) {
+		t.budget = budget
+	}
+}
+
+// compressStep greedily merges the centroids among the next budget ones from
+// the cursor, the same way cluster does for the whole summary.
+func (t *TDigest) compressStep() {
+	s := t.summary
+
+	if !t.sweeping {
+		if float64(s.Len()) <= t.compressionTrigger()/2 {
+			return
+		}
+		t.sweeping, t.cursor = true, 0
+	}
+
+	// a pass ends once it reaches the last centroid rather than chasing the
+	// ones inserted after it, which is all it would do with sorted input
+	start := t.cursor
+	end := start + t.budget + 1
+	if end >= s.Len() {
+		end = s.Len()
+		t.sweeping = false
+	}
+
+	n := start
+	lo := s.HeadSum(start)
+	for i := start + 1; i < end; i++ {
+		c, ci := float64(s.counts[n]), float64(s.counts[i])
+		if t.mergeable(lo, c+ci) {
+			s.absorb(n, i)
+		} else {
+			lo += c
+			n++
+			s.move(n, i)
+		}
+	}
+
+	// the last centroid written may still absorb the ones after end, so the
+	// next step starts from it
+	t.cursor = n
+	if n+1 == end {
+		return
+	}
+
+	s.cut(n+1, end)
+}
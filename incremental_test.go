@@ -0,0 +1,116 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestIncrementalCompressionInvariants(t *testing.T) {
+	const n = 50000
+
+	streams := map[string]func(i int) float64{
+		"uniform":    func(i int) float64 { return rand.Float64() },
+		"ascending":  func(i int) float64 { return float64(i) },
+		"descending": func(i int) float64 { return float64(n - i) },
+		"duplicates": func(i int) float64 { return float64(rand.Intn(5)) },
+	}
+
+	for name, next := range streams {
+		for _, budget := range []int{1, 8, 64} {
+			tdigest := New(50, WithIncrementalCompression(budget))
+
+			var total uint64
+			for i := 0; i < n; i++ {
+				count := uint32(rand.Intn(3) + 1)
+				assertNoError(t, tdigest.AddWeighted(next(i), count))
+				total += uint64(count)
+
+				if tdigest.Count() != total || uint64(tdigest.summary.HeadSum(tdigest.summary.Len())) != total {
+					t.Fatalf("%s: budget=%d lost track of the count after %d insertions", name, budget, i+1)
+				}
+				if float64(tdigest.summary.Len()) > 2*tdigest.compressionTrigger()+1 {
+					t.Fatalf("%s: budget=%d grew to %d centroids", name, budget, tdigest.summary.Len())
+				}
+			}
+
+			checkSorted(tdigest.summary, t)
+			assertHeadSums(tdigest.summary, t)
+		}
+	}
+}
+
+func TestIncrementalCompressionAccuracy(t *testing.T) {
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rand.NormFloat64()
+	}
+
+	eager := New(100)
+	incremental := New(100, WithIncrementalCompression(16))
+	for _, x := range data {
+		assertNoError(t, eager.Add(x))
+		assertNoError(t, incremental.Add(x))
+	}
+	sort.Float64s(data)
+
+	if float64(incremental.summary.Len()) > incremental.compressionTrigger() {
+		t.Errorf("Expected the sweep to keep up, got %d centroids", incremental.summary.Len())
+	}
+
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		exact := quantile(q, data)
+		eagerErr := math.Abs(eager.Quantile(q) - exact)
+		incrementalErr := math.Abs(incremental.Quantile(q) - exact)
+		if incrementalErr > 2*eagerErr+0.005 {
+			t.Errorf("q=%.3f: incremental error %.5f, eager error %.5f", q, incrementalErr, eagerErr)
+		}
+	}
+}
+
+func TestIncrementalCompressionInvalidBudget(t *testing.T) {
+	shouldPanic(func() { WithIncrementalCompression(0) }, t, "A budget of 0 should panic")
+}
+
+// benchmarkAddLatency reports the distribution of the time taken by
+// individual calls to Add, where eager compression shows up as the tail.
+// The values are ascending, which makes the digest compress often.
+func benchmarkAddLatency(b *testing.B, opts ...Option) {
+	t := New(100, opts...)
+
+	data := make([]float64, b.N)
+	for n := range data {
+		data[n] = float64(n)
+	}
+	latencies := make([]time.Duration, b.N)
+
+	b.ResetTimer()
+	for n, x := range data {
+		start := time.Now()
+		err := t.Add(x)
+		latencies[n] = time.Since(start)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+	b.StopTimer()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) float64 {
+		return float64(latencies[int(p*float64(len(latencies)-1))].Nanoseconds())
+	}
+	b.ReportMetric(percentile(0.5), "p50-ns")
+	b.ReportMetric(percentile(0.99), "p99-ns")
+	b.ReportMetric(percentile(0.9999), "p99.99-ns")
+	b.ReportMetric(percentile(1), "max-ns")
+}
+
+func BenchmarkAddLatencyEager(b *testing.B) {
+	benchmarkAddLatency(b)
+}
+
+func BenchmarkAddLatencyIncremental(b *testing.B) {
+	benchmarkAddLatency(b, WithIncrementalCompression(32))
+}
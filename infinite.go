@@ -0,0 +1,25 @@
+package tdigest
+
+import "math"
+
+// WithInfiniteValues makes the digest accept the samples -Inf and +Inf,
+// which are otherwise rejected with ErrInvalidValue. They are kept as the
+// extremes and as centroids of their own, but any quantile that interpolates
+// towards them is infinite and so are the mean and the other statistics.
+//
+// The choice is recorded by Marshal, so that a decoded digest keeps
+// accepting them.
+func WithInfiniteValues() Option {
+	return func(t *TDigest) {
+		t.infinite = true
+	}
+}
+
+// checkValue returns ErrInvalidValue if value may not be added to the
+// digest.
+func (t *TDigest) checkValue(value float64) error {
+	if math.IsNaN(value) || (math.IsInf(value, 0) && !t.infinite) {
+		return ErrInvalidValue
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package tdigest
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestRejectInvalidValues(t *testing.T) {
+	for name, opts := range optionVariants(map[string][]Option{
+		"quantized": {WithQuantization(0.5)},
+		"tails":     {WithExactTails(3)},
+		"infinite":  {WithInfiniteValues()},
+	}) {
+		for _, n := range []int{0, 1, 1000} {
+			tdigest := New(100, opts...)
+			for i := 0; i < n; i++ {
+				assertNoError(t, tdigest.Add(float64(i%37)))
+			}
+			before := tdigest.Marshal(nil)
+
+			invalid := []float64{math.NaN()}
+			if !tdigest.infinite {
+				invalid = append(invalid, math.Inf(1), math.Inf(-1))
+			}
+			for _, x := range invalid {
+				for method, add := range map[string]func() error{
+					"Add":          func() error { return tdigest.Add(x) },
+					"AddWeighted":  func() error { return tdigest.AddWeighted(x, 3) },
+					"AddWeightedF": func() error { return tdigest.AddWeightedF(x, 2.5) },
+					"AddBatch":     func() error { return tdigest.AddBatch([]float64{1, x, 2}) },
+					"AddSorted":    func() error { return tdigest.AddSorted([]float64{x}) },
+				} {
+					if err := add(); err != ErrInvalidValue {
+						t.Errorf("%s %d: %s(%v) returned %v, expected ErrInvalidValue", name, n, method, x, err)
+					}
+				}
+			}
+
+			if tdigest.Count() != uint64(n) {
+				t.Errorf("%s %d: rejected samples changed Count() to %d", name, n, tdigest.Count())
+			}
+			if after := tdigest.Marshal(nil); !bytes.Equal(before, after) {
+				t.Errorf("%s %d: rejected samples changed the digest", name, n)
+			}
+		}
+	}
+
+	tdigest := New(100, WithInfiniteValues())
+	assertNoError(t, tdigest.Add(math.Inf(-1)))
+	assertNoError(t, tdigest.Add(0))
+	assertNoError(t, tdigest.Add(math.Inf(1)))
+	if tdigest.Count() != 3 || !math.IsInf(tdigest.Min(), -1) || !math.IsInf(tdigest.Max(), 1) {
+		t.Errorf("Expected 3 samples from -Inf to +Inf, got %d from %v to %v", tdigest.Count(), tdigest.Min(), tdigest.Max())
+	}
+
+	decoded, err := FromBytes(tdigest.Marshal(nil))
+	assertNoError(t, err)
+	if !decoded.infinite {
+		t.Errorf("WithInfiniteValues was not recorded by Marshal")
+	}
+	assertNoError(t, decoded.Add(math.Inf(1)))
+	if decoded.Count() != 4 {
+		t.Errorf("Expected a decoded digest to keep accepting infinite samples")
+	}
+}
@@ -0,0 +1,49 @@
+package tdigest
+
+import "math"
+
+// Interpolation selects how Quantile estimates the value at a rank that
+// falls between two samples.
+type Interpolation int
+
+const (
+	// InterpolationLinear interpolates linearly between the samples around
+	// the rank q*(Count()-1), like the default method of numpy.quantile.
+	// It is the default.
+	InterpolationLinear Interpolation = iota
+	// InterpolationMidpoint averages the samples at the whole ranks just
+	// below and above q*(Count()-1), like the midpoint method of
+	// numpy.quantile.
+	InterpolationMidpoint
+)
+
+// WithInterpolation selects how Quantile, and the queries built on it,
+// interpolate between samples. The samples themselves are estimated from
+// the centroids in the same way whatever the mode, so both modes are
+// monotonic and stay within Min and Max.
+//
+// The mode is recorded by Marshal, so that a decoded digest keeps using it.
+// It panics if mode is not one of the Interpolation constants.
+func WithInterpolation(mode Interpolation) Option {
+	if !mode.valid() {
+		panic("unknown interpolation mode")
+	}
+	return func(t *TDigest) {
+		t.interpolation = mode
+	}
+}
+
+func (mode Interpolation) valid() bool {
+	return mode == InterpolationLinear || mode == InterpolationMidpoint
+}
+
+// quantileMidpoint averages the linear estimates at the whole ranks around
+// the rank of q.
+func (t *TDigest) quantileMidpoint(q float64) float64 {
+	n := float64(t.count - 1)
+	lo, hi := math.Floor(q*n), math.Ceil(q*n)
+	if lo == hi {
+		return t.quantileLinear(q)
+	}
+	return (t.quantileLinear(lo/n) + t.quantileLinear(hi/n)) / 2
+}
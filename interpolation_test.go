@@ -0,0 +1,106 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// midpointQuantile is the midpoint method of numpy.quantile.
+func midpointQuantile(q float64, data []float64) float64 {
+	index := q * float64(len(data)-1)
+	return (data[int(math.Floor(index))] + data[int(math.Ceil(index))]) / 2
+}
+
+func TestInterpolation(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1e4b))
+	modes := map[Interpolation]func(float64, []float64) float64{
+		InterpolationLinear:   quantile,
+		InterpolationMidpoint: midpointQuantile,
+	}
+
+	for mode, reference := range modes {
+		// few enough samples that every one is a centroid of its own
+		data := make([]float64, 50)
+		tdigest := New(100, WithInterpolation(mode))
+		for i := range data {
+			data[i] = rng.NormFloat64()
+			assertNoError(t, tdigest.Add(data[i]))
+		}
+		sort.Float64s(data)
+
+		qs := make([]float64, 0, 1001)
+		for q := 0.0; q <= 1; q += 0.001 {
+			qs = append(qs, q)
+		}
+		batch := tdigest.Quantiles(qs)
+		for i, q := range qs {
+			got, expected := tdigest.Quantile(q), reference(q, data)
+			if math.Abs(got-expected) > 1e-9 {
+				t.Errorf("mode %d: Quantile(%v) = %v, expected %v", mode, q, got, expected)
+			}
+			if batch[i] != got {
+				t.Errorf("mode %d: Quantiles gave %v at %v, Quantile %v", mode, batch[i], q, got)
+			}
+		}
+
+		// and many more, where both modes must stay accurate, monotonic
+		// and within the bounds
+		data = make([]float64, 100000)
+		tdigest = New(100, WithInterpolation(mode))
+		for i := range data {
+			data[i] = rng.ExpFloat64()
+			assertNoError(t, tdigest.Add(data[i]))
+		}
+		sort.Float64s(data)
+
+		previous := math.Inf(-1)
+		for _, q := range qs {
+			x := tdigest.Quantile(q)
+			if x < previous || x < tdigest.Min() || x > tdigest.Max() {
+				t.Fatalf("mode %d: Quantile(%v) = %v after %v, out of [%v, %v]", mode, q, x, previous, tdigest.Min(), tdigest.Max())
+			}
+			previous = x
+			if err := rankError(q, x, data); err > 0.01 {
+				t.Errorf("mode %d: Quantile(%v) is off by %v in rank", mode, q, err)
+			}
+		}
+	}
+
+	shouldPanic(func() { WithInterpolation(-1) }, t, "WithInterpolation(-1) should panic")
+	shouldPanic(func() { WithInterpolation(2) }, t, "WithInterpolation(2) should panic")
+}
+
+func TestInterpolationSerialization(t *testing.T) {
+	for _, n := range []int{0, 3, 10000} {
+		tdigest := New(100, WithInterpolation(InterpolationMidpoint))
+		for i := 0; i < n; i++ {
+			assertNoError(t, tdigest.Add(float64(i%100)))
+		}
+
+		buf := tdigest.Marshal(nil)
+		if len(buf) != tdigest.MarshaledSizeBytes() {
+			t.Errorf("got %d bytes, MarshaledSizeBytes returned %d", len(buf), tdigest.MarshaledSizeBytes())
+		}
+		decoded, err := FromBytes(buf)
+		assertNoError(t, err)
+		if decoded.interpolation != InterpolationMidpoint {
+			t.Errorf("%d samples: got interpolation %d after decoding", n, decoded.interpolation)
+		}
+		for _, q := range []float64{0.1, 0.25, 0.5, 0.99} {
+			if got, expected := decoded.Quantile(q), tdigest.Quantile(q); math.Abs(got-expected) > 0.1 {
+				t.Errorf("%d samples: Quantile(%v) = %v after decoding, expected %v", n, q, got, expected)
+			}
+		}
+	}
+
+	linear := New(100)
+	assertNoError(t, linear.Add(1))
+	for _, payload := range [][]byte{{2}, {}, {1, 0}} {
+		buf := appendSection(linear.Marshal(nil), sectionInterpolation, payload)
+		if _, err := FromBytes(buf); err == nil {
+			t.Errorf("Expected an error decoding interpolation %v", payload)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build go1.23
+
+package tdigest
+
+import "iter"
+
+// All returns an iterator over the means and counts of the centroids in
+// increasing order of mean, for use with range:
+//
+//	for mean, count := range digest.All() {
+//		...
+//	}
+//
+// Unlike Centroids it does not copy them. Like every query it must not be
+// used while the digest is modified, including by the body of the loop.
+func (t *TDigest) All() iter.Seq2[float64, uint32] {
+	return func(yield func(float64, uint32) bool) {
+		t.checkRead()
+		for i := 0; i < t.summary.Len(); i++ {
+			if !yield(t.summary.Mean(i), t.summary.Count(i)) {
+				return
+			}
+		}
+	}
+}
+
+// Descending is like All in decreasing order of mean.
+func (t *TDigest) Descending() iter.Seq2[float64, uint32] {
+	return func(yield func(float64, uint32) bool) {
+		t.checkRead()
+		for i := t.summary.Len() - 1; i >= 0; i-- {
+			if !yield(t.summary.Mean(i), t.summary.Count(i)) {
+				return
+			}
+		}
+	}
+}
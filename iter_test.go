@@ -0,0 +1,63 @@
+//go:build go1.23
+
+package tdigest
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	tdigest := New(10)
+	for range tdigest.All() {
+		t.Errorf("Expected no centroids for an empty digest")
+	}
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, tdigest.Add(rand.Float64()))
+	}
+
+	var ascending, descending []Centroid
+	for mean, count := range tdigest.All() {
+		ascending = append(ascending, Centroid{Mean: mean, Count: count})
+	}
+	for mean, count := range tdigest.Descending() {
+		descending = append([]Centroid{{Mean: mean, Count: count}}, descending...)
+	}
+	if expected := tdigest.Centroids(); !reflect.DeepEqual(ascending, expected) || !reflect.DeepEqual(descending, expected) {
+		t.Errorf("got centroids %v and %v in reverse, expected %v", ascending, descending, expected)
+	}
+
+	// breaking out of the loop stops the iteration
+	var n int
+	for mean := range tdigest.All() {
+		if n++; n == 3 {
+			if mean != ascending[2].Mean {
+				t.Errorf("got the mean %v third, expected %v", mean, ascending[2].Mean)
+			}
+			break
+		}
+	}
+	n = 0
+	for mean := range tdigest.Descending() {
+		if n++; n == 2 {
+			if last := ascending[len(ascending)-2]; mean != last.Mean {
+				t.Errorf("got the mean %v second from the end, expected %v", mean, last.Mean)
+			}
+			break
+		}
+	}
+
+	var total uint64
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, count := range tdigest.All() {
+			total += uint64(count)
+		}
+		for _, count := range tdigest.Descending() {
+			total += uint64(count)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations iterating the centroids, expected none", allocs)
+	}
+}
@@ -0,0 +1,38 @@
+package tdigest
+
+// KthSmallest returns the estimate of the k-th smallest sample, counting
+// from 1, with the interpolation of Quantile: it is Quantile(q) for the q
+// at which Quantile interpolates the k-th sample. KthSmallest(1) and
+// KthSmallest(Count()) are the estimates of the smallest and largest
+// samples.
+//
+// It returns ErrEmptyDigest for an empty digest and ErrInvalidRank if k is
+// 0 or larger than Count().
+func (t *TDigest) KthSmallest(k uint64) (float64, error) {
+	t.checkRead()
+
+	if t.count == 0 {
+		return 0, ErrEmptyDigest
+	}
+	if k == 0 || k > t.count {
+		return 0, ErrInvalidRank
+	}
+	if t.count == 1 {
+		return t.quantile(0), nil
+	}
+	return t.quantile(float64(k-1) / float64(t.count-1)), nil
+}
+
+// KthLargest returns the estimate of the k-th largest sample, counting from
+// 1, which is the Count()-k+1-th smallest one. See KthSmallest.
+func (t *TDigest) KthLargest(k uint64) (float64, error) {
+	t.checkRead()
+
+	if t.count == 0 {
+		return 0, ErrEmptyDigest
+	}
+	if k == 0 || k > t.count {
+		return 0, ErrInvalidRank
+	}
+	return t.KthSmallest(t.count - k + 1)
+}
@@ -0,0 +1,66 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestKthSmallest(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x4))
+
+	tdigest := New(100)
+	data := make([]float64, 1000000)
+	for i := range data {
+		data[i] = rng.ExpFloat64()
+		assertNoError(t, tdigest.Add(data[i]))
+	}
+	sort.Float64s(data)
+
+	n := uint64(len(data))
+	for _, k := range []uint64{1, 2, 10, 100, 1000, n / 4, n / 2, 3 * n / 4, n - 1000, n - 100, n - 10, n - 1, n} {
+		got, err := tdigest.KthSmallest(k)
+		assertNoError(t, err)
+
+		// compare ranks rather than values, which are far apart in the
+		// sparse upper tail
+		rank := cdf(got, data) * float64(n)
+		if e := math.Abs(rank - float64(k)); e > math.Max(5, 0.002*math.Min(float64(k), float64(n-k))) {
+			t.Errorf("KthSmallest(%d) = %v, which is the %.0f-th smallest", k, got, rank)
+		}
+
+		largest, err := tdigest.KthLargest(n - k + 1)
+		assertNoError(t, err)
+		if largest != got {
+			t.Errorf("KthLargest(%d) = %v, expected KthSmallest(%d) = %v", n-k+1, largest, k, got)
+		}
+	}
+
+	if got, _ := tdigest.KthSmallest(n / 2); got != tdigest.Quantile(float64(n/2-1)/float64(n-1)) {
+		t.Errorf("Expected KthSmallest to match Quantile")
+	}
+
+	for _, k := range []uint64{0, n + 1} {
+		if _, err := tdigest.KthSmallest(k); err != ErrInvalidRank {
+			t.Errorf("KthSmallest(%d): expected ErrInvalidRank, got %v", k, err)
+		}
+		if _, err := tdigest.KthLargest(k); err != ErrInvalidRank {
+			t.Errorf("KthLargest(%d): expected ErrInvalidRank, got %v", k, err)
+		}
+	}
+
+	empty := New(100)
+	if _, err := empty.KthSmallest(1); err != ErrEmptyDigest {
+		t.Errorf("Expected ErrEmptyDigest, got %v", err)
+	}
+	if _, err := empty.KthLargest(1); err != ErrEmptyDigest {
+		t.Errorf("Expected ErrEmptyDigest, got %v", err)
+	}
+
+	single := New(100)
+	assertNoError(t, single.Add(42))
+	if got, err := single.KthLargest(1); err != nil || got != 42 {
+		t.Errorf("KthLargest(1) = %v, %v, expected 42", got, err)
+	}
+}
@@ -0,0 +1,136 @@
+package tdigest
+
+import (
+	"math"
+	"sort"
+)
+
+// MADEstimator estimates the median absolute deviation of a stream by
+// keeping a second digest of the absolute deviations from a reference
+// median supplied by the caller.
+//
+// The estimate is only as good as the reference median: deviations are
+// recorded against whatever median was set when the value was added, so
+// if the true median drifts by d between calls to SetMedian the estimate
+// can be biased by up to d. Updating the reference periodically (from a
+// digest of the raw values, for example) keeps that bias small.
+type MADEstimator struct {
+	median     float64
+	deviations *TDigest
+}
+
+// NewMADEstimator creates an estimator measuring deviations from median.
+func NewMADEstimator(compression float64, median float64) *MADEstimator {
+	return &MADEstimator{
+		median:     median,
+		deviations: New(compression),
+	}
+}
+
+// Add registers the absolute deviation of value from the reference median.
+func (m *MADEstimator) Add(value float64) error {
+	return m.deviations.Add(math.Abs(value - m.median))
+}
+
+// SetMedian updates the reference median used by subsequent calls to Add.
+// Previously recorded deviations are kept as is.
+func (m *MADEstimator) SetMedian(median float64) {
+	m.median = median
+}
+
+// Median returns the current reference median.
+func (m *MADEstimator) Median() float64 {
+	return m.median
+}
+
+// MAD returns the estimated median absolute deviation, or NaN if no values
+// have been added.
+func (m *MADEstimator) MAD() float64 {
+	m.deviations.checkRead()
+	return m.deviations.quantile(0.5)
+}
+
+// EstimateMAD approximates the median absolute deviation of the values in d
+// by evaluating its inverse CDF at samples evenly spaced quantiles and taking
+// the median of their distances to d.Quantile(0.5).
+//
+// This needs no extra state but inherits the interpolation error of the
+// digest at both the median and the quartiles, plus a discretization error
+// of roughly 1/samples in rank. A few hundred samples are usually enough for
+// the latter to be negligible. It returns NaN for an empty digest and panics
+// if samples is less than 1.
+func EstimateMAD(d *TDigest, samples int) float64 {
+	if samples < 1 {
+		panic("samples must be at least 1")
+	}
+	d.checkRead()
+
+	median := d.quantile(0.5)
+	if math.IsNaN(median) {
+		return math.NaN()
+	}
+
+	deviations := make([]float64, samples)
+	for i := range deviations {
+		q := (float64(i) + 0.5) / float64(samples)
+		deviations[i] = math.Abs(d.quantile(q) - median)
+	}
+	sort.Float64s(deviations)
+
+	if samples%2 == 1 {
+		return deviations[samples/2]
+	}
+	return (deviations[samples/2-1] + deviations[samples/2]) / 2
+}
+
+// MAD estimates the median absolute deviation of the samples of the digest,
+// the smallest d such that half of the samples are within d of the median
+// m = Quantile(0.5), that is CDF(m+d) - CDF(m-d) >= 0.5. Unlike EstimateMAD
+// it has no discretization error: the distances from the median to the
+// centroids are searched for the interval containing d, which is then
+// narrowed down by bisection. It returns NaN for an empty digest and 0 for
+// a single sample.
+func (t *TDigest) MAD() float64 {
+	t.checkRead()
+
+	switch t.count {
+	case 0:
+		return math.NaN()
+	case 1:
+		return 0
+	}
+
+	median := t.quantile(0.5)
+	within := func(d float64) float64 {
+		return t.cdf(median+d) - t.cdf(median-d)
+	}
+
+	// the CDF changes slope or jumps at the means and extremes only
+	distances := make([]float64, 0, t.summary.Len()+2)
+	distances = append(distances, math.Abs(t.min-median), math.Abs(t.max-median))
+	for _, mean := range t.summary.means {
+		distances = append(distances, math.Abs(mean-median))
+	}
+	sort.Float64s(distances)
+
+	i := sort.Search(len(distances), func(i int) bool { return within(distances[i]) >= 0.5 })
+	if i == len(distances) {
+		return distances[len(distances)-1]
+	}
+	lo, hi := 0.0, distances[i]
+	if i > 0 {
+		lo = distances[i-1]
+	}
+	for j := 0; j < 64 && lo < hi; j++ {
+		mid := lo + (hi-lo)/2
+		if mid == lo || mid == hi {
+			break
+		}
+		if within(mid) >= 0.5 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi
+}
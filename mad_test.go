@@ -0,0 +1,108 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func exactMAD(data []float64) float64 {
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+	median := quantile(0.5, sorted)
+
+	deviations := make([]float64, len(sorted))
+	for i, x := range sorted {
+		deviations[i] = math.Abs(x - median)
+	}
+	sort.Float64s(deviations)
+	return quantile(0.5, deviations)
+}
+
+func TestMADEstimator(t *testing.T) {
+	m := NewMADEstimator(100, 0)
+
+	if !math.IsNaN(m.MAD()) {
+		t.Errorf("MAD() on an empty estimator should return NaN. Got: %.4f", m.MAD())
+	}
+
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rand.NormFloat64()
+		assertNoError(t, m.Add(data[i]))
+	}
+
+	exact := exactMAD(data)
+	if math.Abs(m.MAD()-exact) > 0.01 {
+		t.Errorf("MADEstimator.MAD() = %.4f, exact %.4f", m.MAD(), exact)
+	}
+	if math.Abs(m.MAD()-0.6745) > 0.02 {
+		t.Errorf("MADEstimator.MAD() = %.4f, expected about 0.6745 for normal data", m.MAD())
+	}
+
+	m.deviations.writing = true
+	shouldPanic(func() { m.MAD() }, t, "MAD() during an Add should panic")
+}
+
+func TestEstimateMAD(t *testing.T) {
+	if !math.IsNaN(EstimateMAD(New(100), 100)) {
+		t.Errorf("EstimateMAD() on an empty digest should return NaN")
+	}
+
+	tdigest := New(100)
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = 5 + 2*rand.NormFloat64()
+		assertNoError(t, tdigest.Add(data[i]))
+	}
+
+	exact := exactMAD(data)
+	for _, samples := range []int{101, 1000} {
+		got := EstimateMAD(tdigest, samples)
+		if math.Abs(got-exact) > 0.03 {
+			t.Errorf("EstimateMAD(%d) = %.4f, exact %.4f", samples, got, exact)
+		}
+	}
+
+	shouldPanic(func() {
+		EstimateMAD(tdigest, 0)
+	}, t, "EstimateMAD with no samples should panic!")
+}
+
+func TestMAD(t *testing.T) {
+	if !math.IsNaN(New(100).MAD()) {
+		t.Errorf("MAD() on an empty digest should return NaN")
+	}
+	single := New(100)
+	assertNoError(t, single.Add(3))
+	if single.MAD() != 0 {
+		t.Errorf("MAD() of a single sample = %v, expected 0", single.MAD())
+	}
+
+	rng := rand.New(rand.NewSource(0x3ad))
+	for name, gen := range map[string]func() float64{
+		"normal":      func() float64 { return 5 + 2*rng.NormFloat64() },
+		"exponential": rng.ExpFloat64,
+		"lognormal":   func() float64 { return math.Exp(rng.NormFloat64()) },
+	} {
+		data := make([]float64, 100000)
+		for i := range data {
+			data[i] = gen()
+		}
+		exact := exactMAD(data)
+
+		// the error in rank of the quantiles is about 1/compression at the
+		// quartiles
+		for _, compression := range []float64{20, 100, 500} {
+			tdigest := New(compression)
+			for _, x := range data {
+				assertNoError(t, tdigest.Add(x))
+			}
+			got := tdigest.MAD()
+			if math.Abs(got-exact) > 2/compression*exact {
+				t.Errorf("%s compression %v: MAD() = %.4f, exact %.4f", name, compression, got, exact)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package tdigest
+
+import "errors"
+
+// MergeTree merges digests into a new digest by merging them in pairs, then
+// merging the results in pairs, and so on, so that the samples of every
+// digest go through the same O(log N) merges whatever their position. When
+// merging digests one after the other into a single accumulator instead,
+// the first ones are part of every compression of the accumulator while the
+// last ones are only added at the end.
+//
+// The result has the compression and options of the first digest, which
+// are shared with the others, and a single digest is simply copied. The
+// digests are left untouched. It returns an error if there are no digests,
+// if some digest is nil or if their centroid bounds differ.
+func MergeTree(digests []*TDigest) (*TDigest, error) {
+	if len(digests) == 0 {
+		return nil, errors.New("no digests to merge")
+	}
+	for _, d := range digests {
+		if d == nil {
+			return nil, errors.New("cannot merge a nil digest")
+		}
+		if d.delta != digests[0].delta {
+			return nil, errors.New("cannot merge digests with different centroid bounds")
+		}
+		d.checkRead()
+	}
+
+	// the first level copies the left digest of every pair, and the levels
+	// above merge into the digests of the level below
+	level := make([]*TDigest, 0, (len(digests)+1)/2)
+	for i := 0; i < len(digests); i += 2 {
+		merged := digests[i].rescaled(1)
+		if i+1 < len(digests) {
+			if err := merged.merge(digests[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		level = append(level, merged)
+	}
+
+	for len(level) > 1 {
+		next := level[:0]
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				if err := level[i].merge(level[i+1]); err != nil {
+					return nil, err
+				}
+			}
+			next = append(next, level[i])
+		}
+		level = next
+	}
+
+	return level[0], nil
+}
@@ -0,0 +1,111 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestMergeTree(t *testing.T) {
+	if testing.Short() {
+		t.Skipf("Skipping merge tree test. Short flag is on")
+	}
+
+	rng := rand.New(rand.NewSource(0x7eee))
+	qs := []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 0.75, 0.9, 0.95, 0.99, 0.999}
+
+	for _, numShards := range []int{100, 1000} {
+		data := make([]float64, 200000)
+		shards := make([]*TDigest, numShards)
+		for i := range shards {
+			shards[i] = New(100)
+		}
+		for i := range data {
+			data[i] = rng.ExpFloat64()
+			assertNoError(t, shards[i%numShards].Add(data[i]))
+		}
+		sort.Float64s(data)
+
+		sequential := New(100)
+		for _, shard := range shards {
+			assertNoError(t, sequential.Merge(shard))
+		}
+
+		tree, err := MergeTree(shards)
+		assertNoError(t, err)
+		if tree.Count() != uint64(len(data)) {
+			t.Errorf("shards=%d: expected %d samples, got %d", numShards, len(data), tree.Count())
+		}
+
+		// errors in rank, as a fraction of the samples
+		var sequentialErr, treeErr float64
+		for _, q := range qs {
+			e := math.Abs(cdf(tree.Quantile(q), data) - q)
+			if e > 0.005 {
+				t.Errorf("shards=%d: Quantile(%v) is off by %.5f in rank", numShards, q, e)
+			}
+			treeErr += e
+			sequentialErr += math.Abs(cdf(sequential.Quantile(q), data) - q)
+		}
+		t.Logf("shards=%d: total rank error %.5f sequential, %.5f tree", numShards, sequentialErr, treeErr)
+	}
+}
+
+func TestMergeTreeDisjointShards(t *testing.T) {
+	if testing.Short() {
+		t.Skipf("Skipping merge tree test. Short flag is on")
+	}
+
+	rng := rand.New(rand.NewSource(0xd15))
+
+	// every shard holds its own range of values, so the CDF at the
+	// boundaries between them shows how well each one was preserved
+	const numShards = 1000
+	shards := make([]*TDigest, numShards)
+	for i := range shards {
+		shards[i] = New(100)
+		for j := 0; j < 200; j++ {
+			assertNoError(t, shards[i].Add(float64(i)+rng.Float64()))
+		}
+	}
+
+	tree, err := MergeTree(shards)
+	assertNoError(t, err)
+	for i := 1; i < numShards; i++ {
+		expected := float64(i) / numShards
+		if got := tree.CDF(float64(i)); math.Abs(got-expected) > 0.005 {
+			t.Errorf("CDF(%d) = %.5f, expected %.5f", i, got, expected)
+		}
+	}
+}
+
+func TestMergeTreeEdgeCases(t *testing.T) {
+	if _, err := MergeTree(nil); err == nil {
+		t.Errorf("Expected an error merging no digests")
+	}
+	if _, err := MergeTree([]*TDigest{New(100), nil}); err == nil {
+		t.Errorf("Expected an error merging a nil digest")
+	}
+	if _, err := MergeTree([]*TDigest{New(100), New(0, WithMaxCentroidBound(100))}); err == nil {
+		t.Errorf("Expected an error merging digests with different centroid bounds")
+	}
+
+	single := New(50)
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, single.Add(float64(i)))
+	}
+	copied, err := MergeTree([]*TDigest{single})
+	assertNoError(t, err)
+	if copied == single || copied.compression != 50 || copied.Count() != single.Count() || copied.Quantile(0.3) != single.Quantile(0.3) {
+		t.Errorf("Expected a copy of a single digest")
+	}
+
+	// an odd number of digests, some of them empty
+	digests := []*TDigest{single, New(50), single, New(50), single}
+	merged, err := MergeTree(digests)
+	assertNoError(t, err)
+	if merged.Count() != 3000 || single.Count() != 1000 {
+		t.Errorf("Expected 3000 samples and the inputs untouched, got %d and %d", merged.Count(), single.Count())
+	}
+}
@@ -0,0 +1,37 @@
+package tdigest
+
+import "fmt"
+
+// MaxMetadataSize is the largest amount of metadata a digest can carry.
+const MaxMetadataSize = 1024
+
+// SetMetadata attaches a copy of data to the digest, replacing any previous
+// metadata. The metadata is opaque to the digest: it is carried through
+// Marshal and FromBytes but plays no role in queries. Merging keeps the
+// receiver's metadata and ignores the other digest's. An empty data removes
+// the metadata, and data larger than MaxMetadataSize is rejected.
+func (t *TDigest) SetMetadata(data []byte) error {
+	if len(data) > MaxMetadataSize {
+		return fmt.Errorf("metadata of %d bytes exceeds the maximum of %d", len(data), MaxMetadataSize)
+	}
+
+	t.beginWrite()
+	t.metadata = append(t.metadata[:0:0], data...)
+	if len(t.metadata) == 0 {
+		t.metadata = nil
+	}
+	t.endWrite()
+
+	return nil
+}
+
+// Metadata returns a copy of the metadata attached to the digest, or nil if
+// there is none.
+func (t *TDigest) Metadata() []byte {
+	t.checkRead()
+
+	if t.metadata == nil {
+		return nil
+	}
+	return append([]byte(nil), t.metadata...)
+}
@@ -0,0 +1,85 @@
+package tdigest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	t1 := New(100)
+	for i := 0; i < 100; i++ {
+		assertNoError(t, t1.Add(float64(i)))
+	}
+
+	if t1.Metadata() != nil {
+		t.Errorf("Expected no metadata on a new digest")
+	}
+
+	meta := []byte("schema=3 rate=0.1")
+	assertNoError(t, t1.SetMetadata(meta))
+	meta[0] = 'X'
+
+	if !bytes.Equal(t1.Metadata(), []byte("schema=3 rate=0.1")) {
+		t.Errorf("Expected the metadata to be copied, got %q", t1.Metadata())
+	}
+
+	t2, err := FromBytes(t1.Marshal(nil))
+	assertNoError(t, err)
+	if !bytes.Equal(t2.Metadata(), t1.Metadata()) {
+		t.Errorf("Expected the metadata to round trip, got %q", t2.Metadata())
+	}
+	if t2.Count() != t1.Count() {
+		t.Errorf("Expected the count to round trip, got %d", t2.Count())
+	}
+
+	// the receiver's metadata wins on merge
+	other := New(100)
+	assertNoError(t, other.SetMetadata([]byte("other")))
+	assertNoError(t, other.Add(1))
+	assertNoError(t, t2.Merge(other))
+	if !bytes.Equal(t2.Metadata(), t1.Metadata()) {
+		t.Errorf("Expected the receiver's metadata to be kept, got %q", t2.Metadata())
+	}
+
+	assertNoError(t, t1.SetMetadata(nil))
+	if t1.Metadata() != nil {
+		t.Errorf("Expected the metadata to be removed")
+	}
+}
+
+func TestMetadataSizeCap(t *testing.T) {
+	tdigest := New(100)
+	assertNoError(t, tdigest.SetMetadata(make([]byte, MaxMetadataSize)))
+	if err := tdigest.SetMetadata(make([]byte, MaxMetadataSize+1)); err == nil {
+		t.Errorf("Expected metadata over the size cap to be rejected")
+	}
+	if len(tdigest.Metadata()) != MaxMetadataSize {
+		t.Errorf("Expected a rejected SetMetadata to leave the metadata unchanged")
+	}
+}
+
+func TestMetadataSections(t *testing.T) {
+	tdigest := New(100)
+	assertNoError(t, tdigest.Add(1))
+	plain := tdigest.Marshal(nil)
+
+	decoded, err := FromBytes(plain)
+	assertNoError(t, err)
+	if decoded.Metadata() != nil {
+		t.Errorf("Expected no metadata when decoding a payload without it")
+	}
+
+	// unknown sections are skipped
+	withUnknown := appendSection(append([]byte(nil), plain...), 1000, []byte("future"))
+	withUnknown = appendSection(withUnknown, sectionMetadata, []byte("meta"))
+	decoded, err = FromBytes(withUnknown)
+	assertNoError(t, err)
+	if string(decoded.Metadata()) != "meta" {
+		t.Errorf("Expected the metadata after an unknown section, got %q", decoded.Metadata())
+	}
+
+	// truncated sections are errors
+	if _, err := FromBytes(withUnknown[:len(withUnknown)-1]); err == nil {
+		t.Errorf("Expected a truncated section to be rejected")
+	}
+}
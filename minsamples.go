@@ -0,0 +1,41 @@
+package tdigest
+
+import "math"
+
+// WithMinSamples makes Quantile and CDF return NaN, as they do for an empty
+// digest, for estimates backed by fewer than n samples in their tail: a
+// quantile q needs at least n samples among the Count()*min(q, 1-q) below
+// or above it, so that a p99.9 is only reported with n samples beyond it.
+// CDF applies the same rule to the fraction it would return.
+//
+// This only affects queries: samples are added as usual and the estimates
+// appear as soon as there are enough of them. Note that this hides the
+// extreme quantiles 0 and 1 altogether.
+func WithMinSamples(n uint64) Option {
+	return func(t *TDigest) {
+		t.minSamples = n
+	}
+}
+
+// QuantileIfAtLeast is like Quantile with WithMinSamples(n), whatever the
+// options of the digest: it returns the estimate of the quantile q and true
+// if there are at least n samples in its tail, and NaN and false otherwise.
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) QuantileIfAtLeast(n uint64, q float64) (float64, bool) {
+	if q < 0 || q > 1 {
+		panic("q must be between 0 and 1 (inclusive)")
+	}
+	t.checkRead()
+
+	if t.count == 0 || !t.enoughSamples(q, n) {
+		return math.NaN(), false
+	}
+	return t.quantile(q), true
+}
+
+// enoughSamples reports whether the digest has at least n samples in the
+// tail of the quantile q, allowing for the rounding of 1-q.
+func (t *TDigest) enoughSamples(q float64, n uint64) bool {
+	return n == 0 || float64(t.count)*math.Min(q, 1-q) >= float64(n)*(1-1e-9)
+}
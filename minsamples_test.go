@@ -0,0 +1,62 @@
+package tdigest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWithMinSamples(t *testing.T) {
+	tdigest := New(100, WithMinSamples(100))
+	if !math.IsNaN(tdigest.Quantile(0.5)) || !math.IsNaN(tdigest.CDF(0)) {
+		t.Errorf("Expected NaN for an empty digest")
+	}
+
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, tdigest.Add(float64(i)))
+	}
+	if tdigest.Count() != 1000 {
+		t.Errorf("Expected every sample to be added, got %d", tdigest.Count())
+	}
+
+	// exactly 100 samples in the tail are enough
+	for _, tt := range []struct {
+		q      float64
+		enough bool
+	}{
+		{0, false},
+		{0.0999, false},
+		{0.1, true},
+		{0.5, true},
+		{0.9, true},
+		{0.9001, false},
+		{1, false},
+	} {
+		got := tdigest.Quantile(tt.q)
+		if math.IsNaN(got) == tt.enough {
+			t.Errorf("Quantile(%v) = %v, expected a number: %v", tt.q, got, tt.enough)
+		}
+
+		v, ok := tdigest.QuantileIfAtLeast(100, tt.q)
+		if ok != tt.enough || math.IsNaN(v) == tt.enough {
+			t.Errorf("QuantileIfAtLeast(100, %v) = %v, %v, expected %v", tt.q, v, ok, tt.enough)
+		}
+	}
+
+	if got := tdigest.CDF(500); math.Abs(got-0.5) > 0.01 {
+		t.Errorf("CDF(500) = %v, expected about 0.5", got)
+	}
+	for _, x := range []float64{-1, 50, 950, 1000} {
+		if got := tdigest.CDF(x); !math.IsNaN(got) {
+			t.Errorf("CDF(%v) = %v, expected NaN with too few samples on one side", x, got)
+		}
+	}
+
+	// the per call gate ignores the option
+	if v, ok := tdigest.QuantileIfAtLeast(0, 1); !ok || v != 999 {
+		t.Errorf("QuantileIfAtLeast(0, 1) = %v, %v, expected 999", v, ok)
+	}
+	if v, ok := New(100).QuantileIfAtLeast(0, 0.5); ok || !math.IsNaN(v) {
+		t.Errorf("QuantileIfAtLeast on an empty digest = %v, %v, expected NaN and false", v, ok)
+	}
+	shouldPanic(func() { tdigest.QuantileIfAtLeast(1, 2) }, t, "QuantileIfAtLeast(1, 2) should panic")
+}
@@ -0,0 +1,73 @@
+package tdigest
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// raceEnabled is set when the tests are built with the race detector.
+var raceEnabled bool
+
+func TestConcurrentMutationDetected(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the detector is intentionally racy")
+	}
+
+	tdigest := New(100)
+	deadline := time.Now().Add(10 * time.Second)
+
+	var mu sync.Mutex
+	var done bool
+	var recovered []interface{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					mu.Lock()
+					done = true
+					recovered = append(recovered, r)
+					mu.Unlock()
+				}
+			}()
+
+			for n := 0; ; n++ {
+				if n%1000 == 0 {
+					mu.Lock()
+					stop := done || time.Now().After(deadline)
+					mu.Unlock()
+					if stop {
+						return
+					}
+				}
+				_ = tdigest.Add(rand.Float64())
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range recovered {
+		if r == "concurrent tdigest mutation detected" {
+			return
+		}
+	}
+	t.Fatalf("concurrent Adds were not detected, recovered: %v", recovered)
+}
+
+func TestSequentialUseNotFlagged(t *testing.T) {
+	t1, t2 := New(10), New(10)
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, t1.Add(rand.Float64()))
+		assertNoError(t, t2.Add(rand.Float64()))
+	}
+	assertNoError(t, t1.Merge(t2))
+	assertNoError(t, t1.Compress())
+	_ = t1.Quantile(0.5)
+	_ = t1.CDF(0.5)
+	_ = t1.Marshal(nil)
+}
@@ -0,0 +1,47 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestQuantileMonotonic(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5eed))
+	distributions := map[string]func() float64{
+		"uniform":     rng.Float64,
+		"exponential": rng.ExpFloat64,
+		"lognormal":   func() float64 { return math.Exp(3 * rng.NormFloat64()) },
+		"repeated":    func() float64 { return float64(rng.Intn(5)) / 3 },
+		"outliers": func() float64 {
+			if rng.Intn(1000) == 0 {
+				return 1e6 * rng.Float64()
+			}
+			return rng.Float64()
+		},
+	}
+
+	for name, gen := range distributions {
+		for variant, opts := range optionVariants(map[string][]Option{
+			"bounded": {WithMaxCentroidBound(50)},
+		}) {
+			for _, n := range []int{2, 10, 1000, 100000} {
+				tdigest := New(20, opts...)
+				for i := 0; i < n; i++ {
+					assertNoError(t, tdigest.AddWeighted(gen(), uint32(1+rng.Intn(3))))
+				}
+
+				previous := math.Inf(-1)
+				for i := 0; i <= 1000; i++ {
+					q := float64(i) / 1000
+					x := tdigest.Quantile(q)
+					if x < previous {
+						t.Errorf("%s n=%d %s: Quantile(%v) = %v is below the previous %v", name, n, variant, q, x, previous)
+						break
+					}
+					previous = x
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package tdigest
+
+// WithCapacity makes the digest start with room for n centroids instead of
+// an estimate derived from its compression, which saves growing the buffers
+// of digests known to get large and memory for digests known to stay small.
+// Digests created with WithSmallFootprint never start with room for more
+// than their maxCentroids. It panics if n is negative.
+func WithCapacity(n int) Option {
+	if n < 0 {
+		panic("n must not be negative")
+	}
+	return func(t *TDigest) {
+		t.capacity = n
+	}
+}
+
+// WithAutoCompressThreshold makes the digest compress itself once it holds
+// more than threshold centroids, instead of 20 times its compression, or
+// twice the delta of WithMaxCentroidBound. A larger threshold compresses
+// less often, making insertions cheaper at the cost of memory, and it
+// should stay well above the number of centroids left by a compression,
+// which would otherwise be repeated by every insertion. It has no effect on
+// digests created with WithSmallFootprint, whose bound is never exceeded.
+//
+// The threshold is recorded by Marshal, so that a decoded digest keeps
+// compressing itself the same way. It panics if threshold is less than 1.
+func WithAutoCompressThreshold(threshold int) Option {
+	if threshold < 1 {
+		panic("threshold must be at least 1")
+	}
+	return func(t *TDigest) {
+		t.trigger = threshold
+	}
+}
+
+// WithSeed seeds the generator the digest uses to break ties between
+// candidate centroids and to shuffle them when compressing, which by
+// default starts from the same state for every digest. Digests created with
+// the same options and seed, and fed the same samples, hold the same
+// centroids.
+func WithSeed(seed uint64) Option {
+	return func(t *TDigest) {
+		t.seed = seed
+		t.pcg = newPCG(seed, 0)
+	}
+}
+
+// WithDeterministicCompress makes Compress, and the compressions the digest
+// triggers itself, merge every centroid into its left neighbor while the
+// result stays within the bound of the compression, in a single pass over
+// the centroids in order of mean, instead of adding them back in random
+// order. Compressing the same centroids then always gives the same result,
+// at the cost of a little accuracy. Along with WithGreedyCandidates it
+// makes a digest independent of its generator, so that digests fed the
+// same samples marshal to the same bytes whatever their seed.
+//
+// The option is recorded by Marshal, so that a decoded digest keeps
+// compressing the same way.
+func WithDeterministicCompress() Option {
+	return func(t *TDigest) {
+		t.deterministic = true
+	}
+}
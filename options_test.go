@@ -0,0 +1,149 @@
+package tdigest
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWithCapacity(t *testing.T) {
+	tdigest := New(100, WithCapacity(5000))
+	if c := cap(tdigest.summary.means); c != 5000 {
+		t.Errorf("got capacity %d, expected 5000", c)
+	}
+	if c := cap(New(100).summary.means); c != 1000 {
+		t.Errorf("got default capacity %d, expected 1000", c)
+	}
+	if c := cap(New(100, WithSmallFootprint(64), WithCapacity(5000)).summary.means); c != 64 {
+		t.Errorf("got capacity %d for a small footprint, expected 64", c)
+	}
+	if c := tdigest.Clone().capacity; c != 5000 {
+		t.Errorf("got capacity %d after Clone, expected 5000", c)
+	}
+	if c := cap(tdigest.emptyCopy().summary.means); c != 5000 {
+		t.Errorf("got capacity %d for an empty copy, expected 5000", c)
+	}
+}
+
+func TestWithAutoCompressThreshold(t *testing.T) {
+	fill := func(tdigest *TDigest) (most int) {
+		for i := 0; i < 1000; i++ {
+			assertNoError(t, tdigest.Add(float64(i)))
+			if n := tdigest.summary.Len(); n > most {
+				most = n
+			}
+		}
+		return most
+	}
+
+	if most := fill(New(100)); most != 1000 {
+		t.Errorf("got at most %d centroids by default, expected 1000", most)
+	}
+	tdigest := New(100, WithAutoCompressThreshold(600))
+	if most := fill(tdigest); most > 601 {
+		t.Errorf("got %d centroids, expected at most 601", most)
+	}
+
+	if trigger := tdigest.Clone().trigger; trigger != 600 {
+		t.Errorf("got threshold %d after Clone, expected 600", trigger)
+	}
+	decoded, err := FromBytes(tdigest.Marshal(nil))
+	assertNoError(t, err)
+	if decoded.trigger != 600 {
+		t.Errorf("got threshold %d after decoding, expected 600", decoded.trigger)
+	}
+	if size := tdigest.MarshaledSizeBytes(); size != len(tdigest.Marshal(nil)) {
+		t.Errorf("got marshaled size %d, expected %d", size, len(tdigest.Marshal(nil)))
+	}
+}
+
+func TestWithSeed(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5eed))
+	data := make([]float64, 20000)
+	for i := range data {
+		// equal values make ties between candidate centroids
+		data[i] = float64(rng.Intn(50))
+	}
+	build := func(opts ...Option) *TDigest {
+		tdigest := New(10, opts...)
+		for _, x := range data {
+			assertNoError(t, tdigest.Add(x))
+		}
+		return tdigest
+	}
+
+	a, b := build(WithSeed(42)), build(WithSeed(42))
+	if !reflect.DeepEqual(a.Centroids(), b.Centroids()) {
+		t.Errorf("Digests with the same seed have different centroids")
+	}
+	if reflect.DeepEqual(a.Centroids(), build(WithSeed(43)).Centroids()) {
+		t.Errorf("Digests with different seeds have the same centroids")
+	}
+	if !reflect.DeepEqual(build().Centroids(), build(WithSeed(0)).Centroids()) {
+		t.Errorf("The seed 0 is not the default")
+	}
+
+	clone := a.Clone()
+	if clone.seed != 42 {
+		t.Errorf("got seed %d after Clone, expected 42", clone.seed)
+	}
+	for _, x := range data[:1000] {
+		assertNoError(t, a.Add(x))
+		assertNoError(t, clone.Add(x))
+	}
+	if !reflect.DeepEqual(a.Centroids(), clone.Centroids()) {
+		t.Errorf("A clone made different random choices")
+	}
+}
+
+func TestWithDeterministicCompress(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xc0de))
+	data := make([]float64, 20000)
+	for i := range data {
+		data[i] = float64(rng.Intn(50)) + rng.Float64()/2
+	}
+	sorted := append([]float64{}, data...)
+	sort.Float64s(sorted)
+	build := func(opts ...Option) *TDigest {
+		tdigest := New(10, append(opts, WithDeterministicCompress())...)
+		for _, x := range data {
+			assertNoError(t, tdigest.Add(x))
+		}
+		assertNoError(t, tdigest.Compress())
+		return tdigest
+	}
+
+	a, b := build(), build()
+	if !bytes.Equal(a.Marshal(nil), b.Marshal(nil)) {
+		t.Errorf("Digests built from the same samples marshal differently after Compress")
+	}
+	greedy := []Option{WithGreedyCandidates()}
+	if !bytes.Equal(build(append(greedy, WithSeed(1))...).Marshal(nil), build(append(greedy, WithSeed(2))...).Marshal(nil)) {
+		t.Errorf("Greedy digests with different seeds marshal differently after Compress")
+	}
+
+	// compressing again, whatever the state of the generator, changes nothing
+	encoded := a.Marshal(nil)
+	a.pcg = newPCG(7, 0)
+	assertNoError(t, a.Compress())
+	if !bytes.Equal(a.Marshal(nil), encoded) {
+		t.Errorf("Compressing twice changed the digest")
+	}
+
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99} {
+		assertDifferenceFromQuantile(sorted, a, q, 0.5, t)
+	}
+
+	decoded, err := FromBytes(encoded)
+	assertNoError(t, err)
+	if !decoded.deterministic || !decoded.Clone().deterministic {
+		t.Errorf("Expected the option to survive serialization and Clone")
+	}
+	plain, err := FromBytes(New(100).Marshal(nil))
+	assertNoError(t, err)
+	if plain.deterministic {
+		t.Errorf("Expected the option to stay off")
+	}
+}
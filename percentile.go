@@ -0,0 +1,78 @@
+package tdigest
+
+import "math"
+
+// Percentile returns the estimate of the p-th percentile, that is
+// Quantile(p/100).
+//
+// Values of p must be between 0 and 100 (inclusive), will panic otherwise.
+func (t *TDigest) Percentile(p float64) float64 {
+	if p < 0 || p > 100 {
+		panic("p must be between 0 and 100 (inclusive)")
+	}
+	return t.Quantile(p / 100)
+}
+
+// P50 returns the median, or NaN if the digest is empty.
+func (t *TDigest) P50() float64 { return t.Quantile(0.5) }
+
+// P90 returns the 90th percentile, or NaN if the digest is empty.
+func (t *TDigest) P90() float64 { return t.Quantile(0.9) }
+
+// P95 returns the 95th percentile, or NaN if the digest is empty.
+func (t *TDigest) P95() float64 { return t.Quantile(0.95) }
+
+// P99 returns the 99th percentile, or NaN if the digest is empty.
+func (t *TDigest) P99() float64 { return t.Quantile(0.99) }
+
+// P999 returns the 99.9th percentile, or NaN if the digest is empty.
+func (t *TDigest) P999() float64 { return t.Quantile(0.999) }
+
+// Percentiles holds the percentiles commonly reported for latencies.
+type Percentiles struct {
+	P50, P90, P95, P99, P999 float64
+}
+
+var snapshotQuantiles = []float64{0.5, 0.9, 0.95, 0.99, 0.999}
+
+// PercentileSnapshot returns the values of P50, P90, P95, P99 and P999,
+// computed in a single pass with Quantiles. They are NaN if the digest is
+// empty.
+func (t *TDigest) PercentileSnapshot() Percentiles {
+	qs := t.Quantiles(snapshotQuantiles)
+	return Percentiles{P50: qs[0], P90: qs[1], P95: qs[2], P99: qs[3], P999: qs[4]}
+}
+
+// IQR returns the interquartile range, that is
+// InterQuantileRange(0.25, 0.75).
+func (t *TDigest) IQR() float64 { return t.InterQuantileRange(0.25, 0.75) }
+
+// InterQuantileRange returns the estimate of Quantile(hi) - Quantile(lo),
+// with both quantiles computed in a single pass with Quantiles. It is never
+// negative, and NaN if the digest is empty.
+//
+// Values of lo and hi must satisfy 0 <= lo < hi <= 1, will panic otherwise.
+func (t *TDigest) InterQuantileRange(lo, hi float64) float64 {
+	if !(0 <= lo && lo < hi && hi <= 1) {
+		panic("lo and hi must satisfy 0 <= lo < hi <= 1")
+	}
+	qs := t.Quantiles([]float64{lo, hi})
+	return math.Max(0, qs[1]-qs[0])
+}
+
+// Median returns the median of the samples. While every sample is still a
+// centroid of its own it is the textbook median, the middle sample or the
+// mean of the two middle ones, whatever the options of the digest. Once
+// samples have been merged it is Quantile(0.5).
+func (t *TDigest) Median() float64 {
+	t.checkRead()
+
+	s, n := t.summary, t.summary.Len()
+	switch {
+	case n == 0 || uint64(n) != t.count:
+		return t.Quantile(0.5)
+	case n%2 == 1:
+		return s.Mean(n / 2)
+	}
+	return s.Mean(n/2-1) + (s.Mean(n/2)-s.Mean(n/2-1))/2
+}
@@ -0,0 +1,141 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	empty := New(100)
+	snapshot := empty.PercentileSnapshot()
+	for _, v := range []float64{empty.P50(), empty.P90(), empty.P95(), empty.P99(), empty.P999(), snapshot.P50, snapshot.P999} {
+		if !math.IsNaN(v) {
+			t.Errorf("Expected NaN for an empty digest, got %v", v)
+		}
+	}
+
+	tdigest := New(100)
+	for i := 0; i < 100000; i++ {
+		assertNoError(t, tdigest.Add(rand.ExpFloat64()))
+	}
+
+	for _, p := range []float64{0, 1, 25, 50, 99.9, 100} {
+		if got, expected := tdigest.Percentile(p), tdigest.Quantile(p/100); got != expected {
+			t.Errorf("Percentile(%v): got %v, expected %v", p, got, expected)
+		}
+	}
+
+	expected := Percentiles{
+		P50:  tdigest.Quantile(0.5),
+		P90:  tdigest.Quantile(0.9),
+		P95:  tdigest.Quantile(0.95),
+		P99:  tdigest.Quantile(0.99),
+		P999: tdigest.Quantile(0.999),
+	}
+	if got := tdigest.PercentileSnapshot(); got != expected {
+		t.Errorf("got snapshot %+v, expected %+v", got, expected)
+	}
+	helpers := Percentiles{tdigest.P50(), tdigest.P90(), tdigest.P95(), tdigest.P99(), tdigest.P999()}
+	if helpers != expected {
+		t.Errorf("got helpers %+v, expected %+v", helpers, expected)
+	}
+
+	for _, p := range []float64{-1, 100.5} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for %v", p)
+				}
+			}()
+			tdigest.Percentile(p)
+		}()
+	}
+}
+
+func TestMedian(t *testing.T) {
+	if m := New(100).Median(); !math.IsNaN(m) {
+		t.Errorf("Expected NaN for an empty digest, got %v", m)
+	}
+
+	for _, test := range []struct {
+		samples []float64
+		median  float64
+	}{
+		{[]float64{3}, 3},
+		{[]float64{3, 1}, 2},
+		{[]float64{3, 1, 10}, 3},
+		{[]float64{3, 1, 10, 0.1}, 2},
+		{[]float64{3, 1, 10, 0.1, 7}, 3},
+		{[]float64{5, 5, 1, 5}, 5},
+	} {
+		for name, opts := range optionVariants(map[string][]Option{
+			"min samples": {WithMinSamples(10)},
+			"midpoint":    {WithInterpolation(InterpolationMidpoint)},
+		}) {
+			tdigest := New(100, opts...)
+			for _, x := range test.samples {
+				assertNoError(t, tdigest.Add(x))
+			}
+			if m := tdigest.Median(); m != test.median {
+				t.Errorf("%s: Median of %v = %v, expected %v", name, test.samples, m, test.median)
+			}
+		}
+	}
+
+	tdigest := New(100)
+	for i := 0; i < 100000; i++ {
+		assertNoError(t, tdigest.Add(rand.ExpFloat64()))
+	}
+	if m := tdigest.Median(); m != tdigest.Quantile(0.5) {
+		t.Errorf("Median = %v, expected Quantile(0.5) = %v", m, tdigest.Quantile(0.5))
+	}
+}
+
+func TestInterQuantileRange(t *testing.T) {
+	empty := New(100)
+	if !math.IsNaN(empty.IQR()) || !math.IsNaN(empty.InterQuantileRange(0.05, 0.95)) {
+		t.Errorf("Expected NaN for an empty digest")
+	}
+
+	single := New(100)
+	assertNoError(t, single.Add(7))
+	if got := single.IQR(); got != 0 {
+		t.Errorf("IQR() of a single sample = %v, expected 0", got)
+	}
+
+	rng := rand.New(rand.NewSource(0x1a4))
+	tdigest := New(100)
+	for i := 0; i < 100000; i++ {
+		assertNoError(t, tdigest.Add(10*rng.Float64()))
+	}
+
+	// the quantiles of uniform data on [0, 10) are 10q
+	for _, r := range [][2]float64{{0.25, 0.75}, {0.05, 0.95}, {0, 1}, {0.5, 0.5001}} {
+		got, expected := tdigest.InterQuantileRange(r[0], r[1]), 10*(r[1]-r[0])
+		if math.Abs(got-expected) > 0.05 {
+			t.Errorf("InterQuantileRange(%v, %v) = %v, expected %v", r[0], r[1], got, expected)
+		}
+		if got < 0 {
+			t.Errorf("InterQuantileRange(%v, %v) = %v is negative", r[0], r[1], got)
+		}
+		qs := tdigest.Quantiles(r[:])
+		if got != qs[1]-qs[0] {
+			t.Errorf("InterQuantileRange(%v, %v) = %v, expected the difference of Quantiles %v", r[0], r[1], got, qs[1]-qs[0])
+		}
+	}
+	if got := tdigest.IQR(); got != tdigest.InterQuantileRange(0.25, 0.75) {
+		t.Errorf("IQR() = %v, expected InterQuantileRange(0.25, 0.75)", got)
+	}
+
+	for _, r := range [][2]float64{{0.5, 0.5}, {0.75, 0.25}, {-0.1, 0.5}, {0.5, 1.1}, {math.NaN(), 1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("InterQuantileRange(%v, %v) should panic", r[0], r[1])
+				}
+			}()
+			tdigest.InterQuantileRange(r[0], r[1])
+		}()
+	}
+}
@@ -0,0 +1,50 @@
+package tdigest
+
+// WeightedPoint is a value standing for Weight samples.
+type WeightedPoint struct {
+	Value  float64
+	Weight uint64
+}
+
+// RepresentativePoints returns at most n points, sorted by value, whose
+// weights sum to Count. If the digest has at most n centroids these are the
+// centroids themselves. Otherwise they are the estimates at the quantiles
+// (i+0.5)/n, each weighted by the number of ranks in [i/n, (i+1)/n). The
+// result only depends on the digest, so repeated calls return the same
+// points. It returns nil for an empty digest and panics if n is less than 1.
+func (t *TDigest) RepresentativePoints(n int) []WeightedPoint {
+	if n < 1 {
+		panic("n must be at least 1")
+	}
+	t.checkRead()
+
+	if t.summary.Len() == 0 {
+		return nil
+	}
+
+	if t.summary.Len() <= n {
+		points := make([]WeightedPoint, 0, t.summary.Len())
+		t.summary.ForEach(func(mean float64, count uint32) bool {
+			points = append(points, WeightedPoint{Value: mean, Weight: uint64(count)})
+			return true
+		})
+		return points
+	}
+
+	points := make([]WeightedPoint, 0, n)
+	var prev uint64
+	for i := 0; i < n; i++ {
+		// the rank boundaries are computed exactly so that the weights add
+		// up to the count
+		next := t.count / uint64(n) * uint64(i+1)
+		next += t.count % uint64(n) * uint64(i+1) / uint64(n)
+		if next == prev {
+			continue
+		}
+
+		q := (float64(i) + 0.5) / float64(n)
+		points = append(points, WeightedPoint{Value: t.quantile(q), Weight: next - prev})
+		prev = next
+	}
+	return points
+}
@@ -0,0 +1,92 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// pointsQuantile returns the value of the point containing the rank
+// q*total of the points.
+func pointsQuantile(q float64, points []WeightedPoint) float64 {
+	var total uint64
+	for _, p := range points {
+		total += p.Weight
+	}
+
+	rank := q * float64(total)
+	var cum float64
+	for _, p := range points {
+		cum += float64(p.Weight)
+		if rank < cum {
+			return p.Value
+		}
+	}
+	return points[len(points)-1].Value
+}
+
+func TestRepresentativePoints(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 100003; i++ {
+		assertNoError(t, tdigest.Add(rand.NormFloat64()))
+	}
+
+	for _, n := range []int{1, 7, 100, 1000, 100000} {
+		points := tdigest.RepresentativePoints(n)
+		if len(points) > n {
+			t.Fatalf("n=%d: got %d points", n, len(points))
+		}
+
+		var total uint64
+		for i, p := range points {
+			total += p.Weight
+			if i > 0 && p.Value < points[i-1].Value {
+				t.Fatalf("n=%d: points are not sorted", n)
+			}
+		}
+		if total != tdigest.Count() {
+			t.Errorf("n=%d: weights add up to %d, expected %d", n, total, tdigest.Count())
+		}
+
+		if !reflect.DeepEqual(points, tdigest.RepresentativePoints(n)) {
+			t.Errorf("n=%d: points changed between calls", n)
+		}
+
+		if n < 100 || n >= tdigest.summary.Len() {
+			continue
+		}
+		// every point stands for 1/n of the ranks, so the quantiles of the
+		// points are off by at most the spread of that many ranks
+		for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+			spread := tdigest.Quantile(math.Min(1, q+1/float64(n))) - tdigest.Quantile(math.Max(0, q-1/float64(n)))
+			if d := math.Abs(pointsQuantile(q, points) - tdigest.Quantile(q)); d > spread {
+				t.Errorf("n=%d: quantile %.2f of the points differs by %.4f", n, q, d)
+			}
+		}
+	}
+
+	if points := tdigest.RepresentativePoints(100000); len(points) != tdigest.summary.Len() {
+		t.Errorf("Expected the centroids when n is large enough, got %d points", len(points))
+	}
+	if New(100).RepresentativePoints(10) != nil {
+		t.Errorf("Expected no points for an empty digest")
+	}
+	shouldPanic(func() { tdigest.RepresentativePoints(0) }, t, "n=0 should panic")
+}
+
+func TestRepresentativePointsSmallCount(t *testing.T) {
+	tdigest := New(0.01)
+	assertNoError(t, tdigest.AddWeighted(1, 2))
+	assertNoError(t, tdigest.AddWeighted(2, 1))
+	assertNoError(t, tdigest.Compress())
+
+	points := tdigest.RepresentativePoints(10)
+	var total uint64
+	for _, p := range points {
+		total += p.Weight
+	}
+	if total != 3 {
+		t.Errorf("Expected weights adding up to 3, got %+v", points)
+	}
+}
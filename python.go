@@ -0,0 +1,100 @@
+package tdigest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// pythonTDigest is the dictionary returned by TDigest.to_dict in the Python
+// tdigest package (github.com/CamDavidsonPilon/tdigest).
+type pythonTDigest struct {
+	N         float64          `json:"n"`
+	Delta     float64          `json:"delta"`
+	K         float64          `json:"K"`
+	Centroids []pythonCentroid `json:"centroids"`
+}
+
+type pythonCentroid struct {
+	Mean  float64 `json:"m"`
+	Count float64 `json:"c"`
+}
+
+// pythonK is the K written by ToPythonTDigestJSON. The Python digest
+// compresses itself once it holds more than K/delta centroids, and with
+// delta = 1/compression a K of 20 matches when this package compresses.
+const pythonK = 20
+
+// FromPythonTDigestJSON decodes a digest from the JSON encoding of the
+// dictionary returned by TDigest.to_dict in the Python tdigest package.
+//
+// The Python digest bounds the weight of a centroid at quantile q by
+// 4·n·delta·q·(1-q), which is this package's bound with a compression of
+// 1/delta, so the centroids are kept as they are and the digest answers
+// queries with the same accuracy. K only controls how often the Python
+// digest compresses itself and is ignored.
+//
+// Python counts are floats: they are rounded to the nearest integer and
+// centroids whose count rounds to zero are dropped, so Count is the sum of
+// the rounded counts rather than n.
+func FromPythonTDigestJSON(buf []byte) (*TDigest, error) {
+	var p pythonTDigest
+	if err := json.Unmarshal(buf, &p); err != nil {
+		return nil, fmt.Errorf("invalid Python tdigest: %v", err)
+	}
+
+	if !(p.Delta > 0) || math.IsInf(p.Delta, 0) {
+		return nil, fmt.Errorf("invalid Python tdigest: delta %v", p.Delta)
+	}
+	if len(p.Centroids) > DefaultMaxCentroids {
+		return nil, errors.New("invalid Python tdigest: too many centroids")
+	}
+
+	t := New(1 / p.Delta)
+	for _, c := range p.Centroids {
+		if !(c.Count >= 0) || c.Count > math.MaxUint32 {
+			return nil, fmt.Errorf("invalid Python tdigest: centroid count %v", c.Count)
+		}
+
+		count := uint32(math.Round(c.Count))
+		if count == 0 {
+			continue
+		}
+
+		t.summary.means = append(t.summary.means, c.Mean)
+		t.summary.counts = append(t.summary.counts, count)
+		t.accumulate(uint64(count), c.Mean*float64(count), 0)
+		t.observe(c.Mean, c.Mean)
+	}
+
+	sort.Stable(t.summary)
+	t.summary.rebuildTree(0)
+
+	return t, nil
+}
+
+// ToPythonTDigestJSON encodes the digest as the JSON encoding of the
+// dictionary expected by TDigest.from_dict in the Python tdigest package,
+// with a delta of 1/compression.
+//
+// from_dict re-inserts the centroids one at a time, which may merge some of
+// them: the Python digest ends up with the same samples but possibly fewer,
+// heavier centroids.
+func (t *TDigest) ToPythonTDigestJSON() ([]byte, error) {
+	t.checkRead()
+
+	p := pythonTDigest{
+		N:         float64(t.count),
+		Delta:     1 / t.compression,
+		K:         pythonK,
+		Centroids: make([]pythonCentroid, 0, t.summary.Len()),
+	}
+	t.summary.ForEach(func(mean float64, count uint32) bool {
+		p.Centroids = append(p.Centroids, pythonCentroid{Mean: mean, Count: float64(count)})
+		return true
+	})
+
+	return json.Marshal(p)
+}
@@ -0,0 +1,71 @@
+package tdigest
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// testdata/python_uniform_1_10000.json is the JSON encoding of to_dict for
+// a Python tdigest (delta 0.01, K 25) fed the integers 1 to 10000 in random
+// order and then compressed. It was generated with a transcription of the
+// library's update and compress methods.
+func TestFromPythonTDigestJSON(t *testing.T) {
+	buf, err := os.ReadFile("testdata/python_uniform_1_10000.json")
+	assertNoError(t, err)
+
+	tdigest, err := FromPythonTDigestJSON(buf)
+	assertNoError(t, err)
+
+	if tdigest.Count() != 10000 {
+		t.Fatalf("Expected 10000 samples, got %d", tdigest.Count())
+	}
+	if tdigest.compression != 100 {
+		t.Errorf("Expected a compression of 100, got %v", tdigest.compression)
+	}
+	checkSorted(tdigest.summary, t)
+
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999} {
+		exp := 1 + q*9999
+		if got := tdigest.Quantile(q); math.Abs(got-exp) > 10 {
+			t.Errorf("Quantile(%.3f) = %.4f, expected about %.4f", q, got, exp)
+		}
+	}
+}
+
+func TestPythonTDigestJSONRoundTrip(t *testing.T) {
+	t1 := New(50)
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, t1.Add(rand.ExpFloat64()))
+	}
+
+	buf, err := t1.ToPythonTDigestJSON()
+	assertNoError(t, err)
+
+	var p map[string]interface{}
+	assertNoError(t, json.Unmarshal(buf, &p))
+	if p["delta"] != 0.02 || p["n"] != 10000.0 || p["K"] != 20.0 {
+		t.Errorf("Unexpected parameters: delta %v, n %v, K %v", p["delta"], p["n"], p["K"])
+	}
+
+	t2, err := FromPythonTDigestJSON(buf)
+	assertNoError(t, err)
+	if !t1.Equals(t2) {
+		t.Errorf("Round trip changed the digest")
+	}
+}
+
+func TestFromPythonTDigestJSONInvalid(t *testing.T) {
+	for _, input := range []string{
+		`not json`,
+		`{"n": 1, "delta": 0, "K": 25, "centroids": [{"m": 1, "c": 1}]}`,
+		`{"n": 1, "delta": 0.01, "K": 25, "centroids": [{"m": 1, "c": -1}]}`,
+		`{"n": 1, "delta": 0.01, "K": 25, "centroids": [{"m": NaN, "c": 1}]}`,
+	} {
+		if _, err := FromPythonTDigestJSON([]byte(input)); err == nil {
+			t.Errorf("Expected an error decoding %s", input)
+		}
+	}
+}
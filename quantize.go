@@ -0,0 +1,41 @@
+package tdigest
+
+import "math"
+
+// WithQuantization snaps every sample to the nearest multiple of step
+// before adding it, so that samples differing by less than the resolution
+// that matters fall on the same value and end up in the same centroids.
+// The centroids themselves are not snapped: their means are averages of
+// snapped samples.
+//
+// This helps most when samples are spread over many values, like noisy
+// measurements. When all samples fall on a few grid points, quantiles
+// between them are interpolated across the whole gap, as for any digest of
+// exactly repeated values. It panics if step is not positive and finite.
+func WithQuantization(step float64) Option {
+	if !(step > 0) || math.IsInf(step, 0) {
+		panic("step must be positive and finite")
+	}
+	return func(t *TDigest) {
+		t.step = step
+	}
+}
+
+// quantize snaps value to the grid of the digest, if it has one. Values too
+// large to be distinguished at the resolution of the grid are kept as is.
+func (t TDigest) quantize(value float64) float64 {
+	if t.step == 0 {
+		return value
+	}
+	if q := math.Round(value/t.step) * t.step; !math.IsInf(q, 0) && !math.IsNaN(q) {
+		return q
+	}
+	return value
+}
+
+// QuantileOnGrid is like Quantile but snaps the result to the grid of a
+// digest created with WithQuantization. It is the same as Quantile for
+// digests without one.
+func (t *TDigest) QuantileOnGrid(q float64) float64 {
+	return t.quantize(t.Quantile(q))
+}
@@ -0,0 +1,71 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestQuantizationShrinksDigest(t *testing.T) {
+	plain := New(100)
+	quantized := New(100, WithQuantization(0.1))
+	for i := 0; i < 20000; i++ {
+		// latencies on a grid of 1ms, with noise well below the step
+		x := float64(rand.Intn(50)) + 0.01*rand.NormFloat64()
+		assertNoError(t, plain.Add(x))
+		assertNoError(t, quantized.Add(x))
+	}
+
+	if 4*quantized.summary.Len() > 3*plain.summary.Len() {
+		t.Errorf("Expected fewer centroids with quantization, got %d and %d without",
+			quantized.summary.Len(), plain.summary.Len())
+	}
+}
+
+func TestQuantizationAccuracy(t *testing.T) {
+	const step = 0.1
+
+	rng := rand.New(rand.NewSource(0x5eed))
+	plain := New(100)
+	quantized := New(100, WithQuantization(step))
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = 10 * rng.ExpFloat64()
+		assertNoError(t, plain.Add(data[i]))
+		assertNoError(t, quantized.Add(data[i]))
+	}
+	sort.Float64s(data)
+
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999} {
+		exact := quantile(q, data)
+		plainErr := math.Abs(plain.Quantile(q) - exact)
+		quantizedErr := math.Abs(quantized.Quantile(q) - exact)
+		if quantizedErr > plainErr+step/2 {
+			t.Errorf("Quantile(%.3f) error %.4f, %.4f without quantization", q, quantizedErr, plainErr)
+		}
+
+		onGrid := quantized.QuantileOnGrid(q)
+		if r := math.Abs(onGrid/step - math.Round(onGrid/step)); r > 1e-9 {
+			t.Errorf("QuantileOnGrid(%.3f) = %v is not a multiple of the step", q, onGrid)
+		}
+	}
+
+	decoded, err := FromBytes(quantized.Marshal(nil))
+	assertNoError(t, err)
+	if decoded.step != step {
+		t.Errorf("Expected the step to survive serialization, got %v", decoded.step)
+	}
+}
+
+func TestQuantizationExtremes(t *testing.T) {
+	tdigest := New(100, WithQuantization(1e-300))
+	assertNoError(t, tdigest.Add(1e300))
+	if tdigest.summary.Mean(0) != 1e300 {
+		t.Errorf("Expected values beyond the grid to be kept, got %v", tdigest.summary.Mean(0))
+	}
+
+	for _, step := range []float64{0, -1, math.Inf(1), math.NaN()} {
+		shouldPanic(func() { WithQuantization(step) }, t, "An invalid step should panic")
+	}
+}
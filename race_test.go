@@ -0,0 +1,5 @@
+//go:build race
+
+package tdigest
+
+func init() { raceEnabled = true }
@@ -0,0 +1,96 @@
+package tdigest
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// readerChunk is the number of samples AddFromReader decodes at a time.
+const readerChunk = 4096
+
+// AddFromReader adds every float64 sample encoded in r with the given byte
+// order, reading it in fixed size chunks so the whole stream never has to be
+// held in memory. Every chunk is merged into the digest in a single sorted
+// pass, as by AddBatch. It returns the number of samples added.
+//
+// A stream whose length is not a multiple of 8 bytes results in an error
+// after all the complete samples before the trailing partial one are added.
+func (t *TDigest) AddFromReader(r io.Reader, order binary.ByteOrder) (n uint64, err error) {
+	return t.AddFromReaderContext(context.Background(), r, order)
+}
+
+// AddFromReaderContext is like AddFromReader, but stops with the context's
+// error between chunks once ctx is done.
+func (t *TDigest) AddFromReaderContext(ctx context.Context, r io.Reader, order binary.ByteOrder) (n uint64, err error) {
+	buf := make([]byte, 8*readerChunk)
+	values := make([]float64, 0, readerChunk)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		read, rerr := io.ReadFull(r, buf)
+
+		values = values[:0]
+		for i := 0; i+8 <= read; i += 8 {
+			values = append(values, math.Float64frombits(order.Uint64(buf[i:])))
+		}
+
+		t.beginWrite()
+		added, err := t.addValues(values)
+		t.endWrite()
+		t.checkThresholds()
+		n += uint64(added)
+		if err != nil {
+			return n, err
+		}
+
+		switch rerr {
+		case nil:
+		case io.EOF:
+			return n, nil
+		case io.ErrUnexpectedEOF:
+			if read%8 != 0 {
+				return n, fmt.Errorf("trailing partial sample of %d bytes after %d samples", read%8, n)
+			}
+			return n, nil
+		default:
+			return n, rerr
+		}
+	}
+}
+
+// addValues adds the values before the first one rejected by checkValue as
+// AddBatch does, returning how many were added along with the error.
+func (t *TDigest) addValues(values []float64) (n int, err error) {
+	if t.addsEach() {
+		return t.addEach(values)
+	}
+
+	n = len(values)
+	for i, value := range values {
+		if err = t.checkValue(value); err != nil {
+			n = i
+			break
+		}
+	}
+	if err := t.addSorted(values[:n], 0); err != nil {
+		return 0, err
+	}
+	return n, err
+}
+
+// addEach adds values in order, returning how many were added before the
+// first error.
+func (t *TDigest) addEach(values []float64) (int, error) {
+	for i, value := range values {
+		if err := t.addWeighted(value, 1); err != nil {
+			return i, err
+		}
+	}
+	return len(values), nil
+}
@@ -0,0 +1,116 @@
+package tdigest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func encodeFloats(order binary.ByteOrder, data []float64) []byte {
+	buf := make([]byte, 8*len(data))
+	for i, x := range data {
+		order.PutUint64(buf[8*i:], math.Float64bits(x))
+	}
+	return buf
+}
+
+func TestAddFromReader(t *testing.T) {
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rand.ExpFloat64()
+	}
+
+	path := filepath.Join(t.TempDir(), "samples.bin")
+	assertNoError(t, os.WriteFile(path, encodeFloats(binary.LittleEndian, data), 0o644))
+
+	fh, err := os.Open(path)
+	assertNoError(t, err)
+	defer fh.Close()
+
+	streamed := New(100)
+	n, err := streamed.AddFromReader(fh, binary.LittleEndian)
+	assertNoError(t, err)
+
+	inMemory := New(100)
+	for _, x := range data {
+		assertNoError(t, inMemory.Add(x))
+	}
+
+	if n != uint64(len(data)) || streamed.Count() != inMemory.Count() {
+		t.Fatalf("Expected %d samples, read %d and counted %d", len(data), n, streamed.Count())
+	}
+
+	sort.Float64s(data)
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		assertDifferenceFromQuantile(data, streamed, q, 0.01*quantile(q, data)+0.001, t)
+		assertDifferenceFromQuantile(data, inMemory, q, 0.01*quantile(q, data)+0.001, t)
+	}
+}
+
+func TestAddFromReaderPartialRecord(t *testing.T) {
+	buf := encodeFloats(binary.BigEndian, []float64{1, 2, 3})
+	buf = append(buf, 0, 1, 2)
+
+	tdigest := New(100)
+	n, err := tdigest.AddFromReader(bytes.NewReader(buf), binary.BigEndian)
+	if err == nil {
+		t.Errorf("Expected an error for a trailing partial sample")
+	}
+	if n != 3 || tdigest.Count() != 3 {
+		t.Errorf("Expected the 3 complete samples to be added, got %d", n)
+	}
+	if tdigest.Quantile(1) != 3 {
+		t.Errorf("Expected the samples to be decoded big endian, got max %.4f", tdigest.Quantile(1))
+	}
+}
+
+func TestAddFromReaderInvalidValue(t *testing.T) {
+	for name, opts := range optionVariants(map[string][]Option{
+		"footprint": {WithSmallFootprint(64)},
+	}) {
+		tdigest := New(100, opts...)
+		buf := encodeFloats(binary.LittleEndian, []float64{3, 1, 2, math.NaN(), 4})
+		n, err := tdigest.AddFromReader(bytes.NewReader(buf), binary.LittleEndian)
+		if err != ErrInvalidValue {
+			t.Errorf("%s: Expected ErrInvalidValue, got %v", name, err)
+		}
+		if n != 3 || tdigest.Count() != 3 || tdigest.Min() != 1 || tdigest.Max() != 3 {
+			t.Errorf("%s: Expected the 3 samples before the NaN to be added, got %d", name, n)
+		}
+	}
+}
+
+func TestAddFromReaderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tdigest := New(100)
+	buf := encodeFloats(binary.LittleEndian, []float64{1, 2, 3})
+	n, err := tdigest.AddFromReaderContext(ctx, bytes.NewReader(buf), binary.LittleEndian)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if n != 0 || tdigest.Count() != 0 {
+		t.Errorf("Expected no samples to be added, got %d", n)
+	}
+}
+
+func BenchmarkAddFromReader(b *testing.B) {
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rand.ExpFloat64()
+	}
+	buf := encodeFloats(binary.LittleEndian, data)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := New(100).AddFromReader(bytes.NewReader(buf), binary.LittleEndian); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package tdigest
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// RemoveValue removes up to maxCount samples equal to x from the digest,
+// returning how many were removed. It is meant to excise a point mass that
+// should never have been added, such as a sentinel value.
+//
+// Only centroids whose mean is exactly x are considered, see
+// RemoveValueWithin for a tolerance.
+func (t *TDigest) RemoveValue(x float64, maxCount uint64) (removed uint64, err error) {
+	return t.RemoveValueWithin(x, 0, maxCount)
+}
+
+// RemoveValueWithin is like RemoveValue but takes the samples from the
+// centroids whose mean is within epsilon of x, closest first. Centroids
+// left without samples are deleted, the means of the others are kept as
+// they are.
+//
+// Samples that were merged into a centroid with a different mean cannot be
+// told apart from the samples they were merged with: they are only found if
+// the tolerance covers that centroid, and removing them does not undo the
+// shift they caused to its mean. Removing a value shortly after it was added,
+// or a value added often enough to hold centroids of its own, works best.
+//
+// It returns an error if x is NaN or epsilon is negative or NaN.
+func (t *TDigest) RemoveValueWithin(x, epsilon float64, maxCount uint64) (removed uint64, err error) {
+	if math.IsNaN(x) {
+		return 0, errors.New("value must not be NaN")
+	}
+	if !(epsilon >= 0) {
+		return 0, errors.New("epsilon must be non-negative")
+	}
+
+	t.beginWrite()
+	removed = t.removeValue(t.quantize(x), epsilon, maxCount)
+	t.endWrite()
+	t.checkThresholds()
+	return removed, nil
+}
+
+func (t *TDigest) removeValue(x, epsilon float64, maxCount uint64) (removed uint64) {
+	s := t.summary
+	lo := s.Floor(x-epsilon) + 1
+	hi := lo
+	for hi < s.Len() && s.Mean(hi) <= x+epsilon {
+		hi++
+	}
+	if lo == hi || maxCount == 0 {
+		return 0
+	}
+
+	candidates := make([]int, 0, hi-lo)
+	for i := lo; i < hi; i++ {
+		candidates = append(candidates, i)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return math.Abs(s.Mean(candidates[i])-x) < math.Abs(s.Mean(candidates[j])-x)
+	})
+
+	for _, i := range candidates {
+		take := uint64(s.counts[i])
+		if take > maxCount-removed {
+			take = maxCount - removed
+		}
+		s.counts[i] -= uint32(take)
+		t.discard(take, s.Mean(i)*float64(take))
+		removed += take
+		if removed == maxCount {
+			break
+		}
+	}
+
+	// drop the emptied centroids and update the tree for the new counts
+	w := lo
+	for i := lo; i < hi; i++ {
+		if s.counts[i] > 0 {
+			s.move(w, i)
+			w++
+		}
+	}
+	if w < hi {
+		s.cut(w, hi)
+		t.sweeping = false
+	} else {
+		s.rebuildTree(s.base)
+	}
+
+	// the extremes may have been removed, in which case the means of the
+	// centroids left are the best estimates of the new ones
+	if s.Len() == 0 {
+		t.setMoments(moments{})
+		t.min, t.max = math.Inf(1), math.Inf(-1)
+	} else {
+		if t.min >= x-epsilon {
+			t.min = math.Max(t.min, s.Mean(0))
+		}
+		if t.max <= x+epsilon {
+			t.max = math.Min(t.max, s.Mean(s.Len()-1))
+		}
+	}
+
+	return removed
+}
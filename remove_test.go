@@ -0,0 +1,86 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRemoveValue(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xdead))
+
+	clean := New(100)
+	contaminated := New(100)
+	for i := 0; i < 100000; i++ {
+		x := rng.Float64()
+		assertNoError(t, clean.Add(x))
+		assertNoError(t, contaminated.Add(x))
+		if i%5 == 0 {
+			assertNoError(t, contaminated.Add(-1))
+		}
+	}
+
+	if contaminated.Quantile(0.1) != -1 {
+		t.Fatalf("Expected the sentinel to dominate the low quantiles. Got %v", contaminated.Quantile(0.1))
+	}
+
+	removed, err := contaminated.RemoveValue(-1, math.MaxUint64)
+	assertNoError(t, err)
+	if removed != 20000 {
+		t.Errorf("Expected to remove every sentinel. Removed %d", removed)
+	}
+	if contaminated.Count() != clean.Count() {
+		t.Errorf("Expected the count to go back to %d. Got %d", clean.Count(), contaminated.Count())
+	}
+
+	for _, q := range []float64{0, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 1} {
+		got, expected := contaminated.Quantile(q), clean.Quantile(q)
+		if math.Abs(got-expected) > 0.01 {
+			t.Errorf("Quantile(%v) = %v after removal, expected about %v", q, got, expected)
+		}
+	}
+
+	removed, err = contaminated.RemoveValue(-1, 1)
+	assertNoError(t, err)
+	if removed != 0 {
+		t.Errorf("Expected nothing left to remove. Removed %d", removed)
+	}
+}
+
+func TestRemoveValueLimits(t *testing.T) {
+	tdigest := New(100)
+	assertNoError(t, tdigest.AddWeighted(1, 10))
+	assertNoError(t, tdigest.AddWeighted(2, 10))
+	assertNoError(t, tdigest.AddWeighted(3, 10))
+
+	removed, err := tdigest.RemoveValue(2, 4)
+	assertNoError(t, err)
+	if removed != 4 || tdigest.Count() != 26 || tdigest.summary.Len() != 3 {
+		t.Errorf("Expected 4 samples removed from the middle centroid. Removed %d, count %d, %d centroids",
+			removed, tdigest.Count(), tdigest.summary.Len())
+	}
+
+	removed, err = tdigest.RemoveValue(2.5, 100)
+	assertNoError(t, err)
+	if removed != 0 {
+		t.Errorf("Expected no exact match for 2.5. Removed %d", removed)
+	}
+
+	// the closest centroid is emptied first
+	removed, err = tdigest.RemoveValueWithin(2.9, 1, 15)
+	assertNoError(t, err)
+	if removed != 15 || tdigest.summary.Len() != 2 || tdigest.summary.Mean(1) != 2 || tdigest.summary.Count(1) != 1 {
+		t.Errorf("Expected the centroid at 3 to be deleted and 5 samples taken from 2. Removed %d, centroids %v %v",
+			removed, tdigest.summary.means, tdigest.summary.counts)
+	}
+	if tdigest.Count() != 11 || tdigest.summary.HeadSum(2) != 11 {
+		t.Errorf("Expected 11 samples left. Got %d with a tree sum of %v", tdigest.Count(), tdigest.summary.HeadSum(2))
+	}
+
+	if _, err := tdigest.RemoveValue(math.NaN(), 1); err == nil {
+		t.Errorf("Expected an error removing NaN")
+	}
+	if _, err := tdigest.RemoveValueWithin(1, -1, 1); err == nil {
+		t.Errorf("Expected an error with a negative epsilon")
+	}
+}
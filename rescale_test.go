@@ -0,0 +1,82 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRescale(t *testing.T) {
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rand.NormFloat64()
+	}
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+
+	for name, opts := range optionVariants(map[string][]Option{
+		"bounded": {WithMaxCentroidBound(314)},
+	}) {
+		tdigest := New(100, opts...)
+		for _, x := range data {
+			assertNoError(t, tdigest.Add(x))
+		}
+		assertNoError(t, tdigest.Compress())
+		if math.Abs(tdigest.Compression()-100) > 0.1 {
+			t.Errorf("%s: got compression %v, expected about 100", name, tdigest.Compression())
+		}
+
+		count, min, max, mean := tdigest.Count(), tdigest.Min(), tdigest.Max(), tdigest.Mean()
+		size, centroids := len(tdigest.Marshal(nil)), tdigest.summary.Len()
+
+		assertNoError(t, tdigest.Rescale(20))
+		if math.Abs(tdigest.Compression()-20) > 0.2 {
+			t.Errorf("%s: got compression %v after rescaling, expected about 20", name, tdigest.Compression())
+		}
+		if tdigest.Count() != count || tdigest.Min() != min || tdigest.Max() != max || tdigest.Mean() != mean {
+			t.Errorf("%s: Rescale changed the statistics of the digest", name)
+		}
+		if tdigest.summary.Len() >= centroids/2 || cap(tdigest.summary.means) >= centroids/2 {
+			t.Errorf("%s: got %d centroids with a capacity of %d, expected fewer than %d", name, tdigest.summary.Len(), cap(tdigest.summary.means), centroids/2)
+		}
+		if len(tdigest.Marshal(nil)) >= size/2 {
+			t.Errorf("%s: got %d serialized bytes, expected fewer than %d", name, len(tdigest.Marshal(nil)), size/2)
+		}
+
+		for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99} {
+			if err := rankError(q, tdigest.Quantile(q), sorted); err > 0.005 {
+				t.Errorf("%s: q=%v: got a rank error of %v after rescaling", name, q, err)
+			}
+		}
+
+		decoded, err := FromBytes(tdigest.Marshal(nil))
+		assertNoError(t, err)
+		if decoded.Compression() != tdigest.Compression() || decoded.Count() != count {
+			t.Errorf("%s: got compression %v and count %d after decoding, expected %v and %d", name,
+				decoded.Compression(), decoded.Count(), tdigest.Compression(), count)
+		}
+
+		// raising the compression keeps the centroids
+		centroids = tdigest.summary.Len()
+		assertNoError(t, tdigest.Rescale(200))
+		if tdigest.summary.Len() != centroids || tdigest.Count() != count {
+			t.Errorf("%s: got %d centroids after raising the compression, expected %d", name, tdigest.summary.Len(), centroids)
+		}
+	}
+
+	tdigest := New(100)
+	for _, c := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		if err := tdigest.Rescale(c); err == nil {
+			t.Errorf("Expected an error for compression %v", c)
+		}
+	}
+	if err := New(0, WithSmallFootprint(32)).Rescale(10); err == nil {
+		t.Errorf("Expected an error for a digest with a small footprint")
+	}
+}
+
+// rankError returns how far the rank of x in sorted is from q.
+func rankError(q, x float64, sorted []float64) float64 {
+	return math.Abs(float64(sort.SearchFloat64s(sorted, x))/float64(len(sorted)) - q)
+}
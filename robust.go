@@ -0,0 +1,56 @@
+package tdigest
+
+import "math"
+
+// normalIQR is the interquartile range of the standard normal distribution,
+// which turns an interquartile range into an estimate of the standard
+// deviation.
+const normalIQR = 1.3489795003921634
+
+// RobustZ returns the robust z-score of x against the digest: its distance
+// from the median in units of the interquartile range scaled to match the
+// standard deviation of normal data. Unlike the classical z-score it is
+// hardly affected by a minority of outliers in the digest.
+//
+// Every call estimates three quantiles of the digest, so scoring many
+// samples against a baseline digest that no longer changes is cheaper with
+// the median and spread computed once from Quantiles. It returns NaN for an
+// empty digest or a NaN x. When all the samples are equal, it returns 0 for
+// x equal to them and ±Inf otherwise.
+func (t *TDigest) RobustZ(x float64) float64 {
+	t.checkRead()
+
+	median, spread := t.robustStats()
+	d := x - median
+	if d == 0 {
+		return 0
+	}
+	return d / spread
+}
+
+// PercentileRankScore returns 2*CDF(x)-1, the signed distance of the
+// percentile rank of x from the median scaled to [-1, 1]. It is -1 below the
+// smallest sample and 1 above the largest one, including for ±Inf, and NaN
+// for an empty digest or a NaN x.
+func (t *TDigest) PercentileRankScore(x float64) float64 {
+	t.checkRead()
+
+	if t.count == 0 || math.IsNaN(x) {
+		return math.NaN()
+	}
+	if median, spread := t.robustStats(); spread == 0 && x == median {
+		// every sample is x, which is as central as it gets
+		return 0
+	}
+	return 2*t.cdf(x) - 1
+}
+
+// robustStats returns the median of the digest and its interquartile range
+// divided by normalIQR, or NaN for both if the digest is empty. It only
+// reads the digest, so that concurrent readers do not race.
+func (t *TDigest) robustStats() (median, spread float64) {
+	if t.count == 0 {
+		return math.NaN(), math.NaN()
+	}
+	return t.quantile(0.5), (t.quantile(0.75) - t.quantile(0.25)) / normalIQR
+}
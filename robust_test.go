@@ -0,0 +1,122 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestRobustZ(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x2))
+
+	tdigest := New(100)
+	if !math.IsNaN(tdigest.RobustZ(0)) || !math.IsNaN(tdigest.PercentileRankScore(0)) {
+		t.Errorf("Expected NaN scores for an empty digest")
+	}
+
+	var data []float64
+	for i := 0; i < 100000; i++ {
+		x := 5 + 2*rng.NormFloat64()
+		data = append(data, x)
+		assertNoError(t, tdigest.Add(x))
+	}
+
+	mean, stddev := meanStddev(data)
+	for _, x := range []float64{-1, 1, 3, 5, 7, 9, 11} {
+		classical := (x - mean) / stddev
+		if got := tdigest.RobustZ(x); math.Abs(got-classical) > 0.05 {
+			t.Errorf("RobustZ(%v) = %.4f, classical z-score %.4f", x, got, classical)
+		}
+	}
+
+	for _, tt := range []struct{ x, expected float64 }{
+		{5, 0},
+		{5 + 2*1.959964, 0.95},
+		{5 - 2*1.959964, -0.95},
+		{math.Inf(1), 1},
+		{math.Inf(-1), -1},
+	} {
+		if got := tdigest.PercentileRankScore(tt.x); math.Abs(got-tt.expected) > 0.01 {
+			t.Errorf("PercentileRankScore(%v) = %.4f, expected %.4f", tt.x, got, tt.expected)
+		}
+	}
+	if !math.IsInf(tdigest.RobustZ(math.Inf(1)), 1) || !math.IsInf(tdigest.RobustZ(math.Inf(-1)), -1) {
+		t.Errorf("Expected infinite scores for infinite values")
+	}
+	if !math.IsNaN(tdigest.RobustZ(math.NaN())) || !math.IsNaN(tdigest.PercentileRankScore(math.NaN())) {
+		t.Errorf("Expected NaN scores for NaN")
+	}
+
+	// a tenth of outliers ruins the classical z-score but barely moves the
+	// robust one
+	for i := 0; i < 10000; i++ {
+		x := 1000 + rng.Float64()
+		data = append(data, x)
+		assertNoError(t, tdigest.Add(x))
+	}
+
+	mean, stddev = meanStddev(data)
+	classical := (11 - mean) / stddev
+	if got := tdigest.RobustZ(11); math.Abs(got-3) > 0.6 || math.Abs(classical-3) < 3 {
+		t.Errorf("RobustZ(11) = %.4f after contamination, classical z-score %.4f", got, classical)
+	}
+}
+
+func TestRobustZPointMass(t *testing.T) {
+	tdigest := New(100)
+	assertNoError(t, tdigest.AddWeighted(3, 100))
+
+	if got := tdigest.RobustZ(3); got != 0 {
+		t.Errorf("RobustZ(3) = %v, expected 0", got)
+	}
+	if got := tdigest.PercentileRankScore(3); got != 0 {
+		t.Errorf("PercentileRankScore(3) = %v, expected 0", got)
+	}
+	if !math.IsInf(tdigest.RobustZ(4), 1) || !math.IsInf(tdigest.RobustZ(2), -1) {
+		t.Errorf("Expected infinite scores away from a point mass, got %v and %v", tdigest.RobustZ(4), tdigest.RobustZ(2))
+	}
+
+	// the cached median follows the digest
+	assertNoError(t, tdigest.AddWeighted(5, 300))
+	if got := tdigest.RobustZ(5); math.Abs(got) > 1 {
+		t.Errorf("RobustZ(5) = %v after adding more samples, expected it near the median", got)
+	}
+}
+
+func meanStddev(data []float64) (mean, stddev float64) {
+	for _, x := range data {
+		mean += x
+	}
+	mean /= float64(len(data))
+	for _, x := range data {
+		stddev += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(data)))
+}
+
+func TestRobustZConcurrentReads(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, tdigest.Add(rand.NormFloat64()))
+	}
+	expected := tdigest.RobustZ(1)
+
+	// readers share the digest without synchronization, which the race
+	// detector would flag if scoring wrote to it
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if got := tdigest.RobustZ(1); got != expected {
+					t.Errorf("RobustZ(1) = %v, expected %v", got, expected)
+					return
+				}
+				_ = tdigest.PercentileRankScore(1)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,98 @@
+package tdigest
+
+import (
+	"errors"
+	"math"
+)
+
+// Rollup merges children into a new digest with the given compression, as
+// done when aggregating fine grained digests into coarser ones (minutes into
+// hours, hours into days, ...).
+//
+// All the centroids of the children are combined and re-clustered in a
+// single pass, so every child contributes equally regardless of its
+// position. The children must all use the same centroid sizing,
+// quantization, interpolation, footprint and exact tails, and the result
+// keeps the options of the first one. If they were created with
+// WithMaxCentroidBound the result is bounded by the delta corresponding to
+// targetCompression. The result tracks centroid variances if all the
+// children do, and its tails are exact if all of theirs are.
+func Rollup(children []*TDigest, targetCompression float64) (*TDigest, error) {
+	if len(children) == 0 {
+		return nil, errors.New("no digests to roll up")
+	}
+
+	summaries := make([]*summary, 0, len(children))
+	for _, child := range children {
+		if child == nil {
+			return nil, errors.New("cannot roll up a nil digest")
+		}
+		if child.delta != children[0].delta {
+			return nil, errors.New("cannot roll up digests with different centroid bounds")
+		}
+		if !child.sameOptions(children[0]) {
+			return nil, errors.New("cannot roll up digests with different options")
+		}
+		child.checkRead()
+
+		summaries = append(summaries, child.summary)
+	}
+
+	t := &TDigest{min: math.Inf(1), max: math.Inf(-1)}
+	children[0].copyOptions(t)
+	if t.delta > 0 {
+		WithMaxCentroidBound(int(math.Max(1, math.Round(targetCompression*math.Pi))))(t)
+		if t.maxCentroids > 0 {
+			t.maxCentroids = 2 * t.delta
+		}
+	} else {
+		t.compression = math.Max(1, targetCompression)
+	}
+	if children[0].tails != nil {
+		t.tails = &exactTails{k: children[0].tails.k}
+	}
+
+	t.summary = combineSummaries(summaries)
+	t.summary.limit = t.maxCentroids
+	t.variance = t.summary.m2 != nil
+	for _, child := range children {
+		t.combine(child.moments())
+		t.observe(child.min, child.max)
+		if t.tails != nil {
+			t.tails.merge(child.tails, child.count)
+		}
+	}
+	t.cluster()
+
+	return t, nil
+}
+
+// MergeAll merges digests into a new digest with the given compression like
+// Rollup, combining all their centroids and clustering them in a single
+// pass, which is faster and more accurate than merging them one at a time.
+// Nil and empty digests are skipped, and the result is empty if every digest
+// is. It returns an error if the other digests do not all use the same
+// options, as listed by Rollup.
+func MergeAll(compression float64, digests ...*TDigest) (*TDigest, error) {
+	nonEmpty := make([]*TDigest, 0, len(digests))
+	for _, d := range digests {
+		if d != nil && d.Count() > 0 {
+			nonEmpty = append(nonEmpty, d)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return New(compression), nil
+	}
+	return Rollup(nonEmpty, compression)
+}
+
+// sameOptions reports whether the digests quantize, interpolate, limit
+// their footprint and keep exact tails the same way, so that they can be
+// rolled up into a digest with the options of either.
+func (t *TDigest) sameOptions(other *TDigest) bool {
+	return t.step == other.step &&
+		t.interpolation == other.interpolation &&
+		t.maxCentroids == other.maxCentroids &&
+		(t.tails == nil) == (other.tails == nil) &&
+		(t.tails == nil || t.tails.k == other.tails.k)
+}
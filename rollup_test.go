@@ -0,0 +1,204 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRollup(t *testing.T) {
+	var data []float64
+
+	// 6 hours of 60 one minute digests each
+	hours := make([]*TDigest, 6)
+	for h := range hours {
+		minutes := make([]*TDigest, 60)
+		for m := range minutes {
+			minutes[m] = New(100)
+			for i := 0; i < 1000; i++ {
+				x := rand.ExpFloat64()
+				data = append(data, x)
+				assertNoError(t, minutes[m].Add(x))
+			}
+		}
+
+		var err error
+		hours[h], err = Rollup(minutes, 100)
+		assertNoError(t, err)
+		if hours[h].Count() != 60000 {
+			t.Fatalf("Expected an hour to hold 60000 samples, got %d", hours[h].Count())
+		}
+	}
+
+	day, err := Rollup(hours, 50)
+	assertNoError(t, err)
+
+	if day.Count() != uint64(len(data)) {
+		t.Errorf("Expected %d samples, got %d", len(data), day.Count())
+	}
+	if day.summary.Len() > hours[0].summary.Len() {
+		t.Errorf("Expected the lower compression to use fewer centroids, %d > %d",
+			day.summary.Len(), hours[0].summary.Len())
+	}
+
+	sort.Float64s(data)
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		assertDifferenceFromQuantile(data, day, q, 0.02*quantile(q, data)+0.002, t)
+	}
+}
+
+func TestRollupInvalid(t *testing.T) {
+	if _, err := Rollup(nil, 100); err == nil {
+		t.Errorf("Expected an error rolling up nothing")
+	}
+	if _, err := Rollup([]*TDigest{New(100), nil}, 100); err == nil {
+		t.Errorf("Expected an error rolling up a nil digest")
+	}
+	if _, err := Rollup([]*TDigest{New(100), New(0, WithMaxCentroidBound(100))}, 100); err == nil {
+		t.Errorf("Expected an error rolling up incompatible digests")
+	}
+
+	for name, opts := range map[string][]Option{
+		"quantization":  {WithQuantization(0.5)},
+		"interpolation": {WithInterpolation(InterpolationMidpoint)},
+		"footprint":     {WithSmallFootprint(64)},
+		"tails":         {WithExactTails(10)},
+	} {
+		if _, err := Rollup([]*TDigest{New(100), New(100, opts...)}, 100); err == nil {
+			t.Errorf("%s: Expected an error rolling up digests with different options", name)
+		}
+	}
+	if _, err := Rollup([]*TDigest{New(100, WithExactTails(5)), New(100, WithExactTails(10))}, 100); err == nil {
+		t.Errorf("Expected an error rolling up digests with different exact tails")
+	}
+
+	bounded, err := Rollup([]*TDigest{New(0, WithMaxCentroidBound(100))}, 10)
+	assertNoError(t, err)
+	if bounded.delta != 31 {
+		t.Errorf("Expected the rollup to be bounded by 31 centroids, got %d", bounded.delta)
+	}
+}
+
+func TestRollupOptions(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7a1))
+	var data []float64
+	children := make([]*TDigest, 10)
+	for c := range children {
+		children[c] = New(100, WithQuantization(0.01), WithInterpolation(InterpolationMidpoint), WithExactTails(5))
+		for i := 0; i < 1000; i++ {
+			x := rng.ExpFloat64()
+			data = append(data, x)
+			assertNoError(t, children[c].Add(x))
+		}
+	}
+	sort.Float64s(data)
+
+	rolled, err := Rollup(children, 50)
+	assertNoError(t, err)
+	if rolled.compression != 50 || rolled.step != 0.01 || rolled.interpolation != InterpolationMidpoint {
+		t.Errorf("Expected the rollup to keep the options, got compression %v, step %v and interpolation %v",
+			rolled.compression, rolled.step, rolled.interpolation)
+	}
+	if rolled.tails == nil || rolled.tails.seen != rolled.Count() {
+		t.Fatalf("Expected the rollup to keep exact tails")
+	}
+	low, high := rolled.tails.low, rolled.tails.high
+	for i := 0; i < 5; i++ {
+		if low[i] != children[0].quantize(data[i]) || high[i] != children[0].quantize(data[len(data)-5+i]) {
+			t.Errorf("Expected the tails %v and %v to hold the extreme samples", low, high)
+			break
+		}
+	}
+
+	footprint, err := Rollup([]*TDigest{New(0, WithSmallFootprint(64))}, 5)
+	assertNoError(t, err)
+	if footprint.delta != 16 || footprint.maxCentroids != 32 {
+		t.Errorf("Expected a footprint of 32 centroids, got %d and a bound of %d",
+			footprint.maxCentroids, footprint.delta)
+	}
+}
+
+func TestMergeAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa11))
+	var data []float64
+	shards := []*TDigest{nil, New(100)}
+	for s := 0; s < 100; s++ {
+		shard := New(100)
+		for i := 0; i < 1000; i++ {
+			// every shard holds a different part of the distribution
+			x := rng.NormFloat64() + float64(s%10)
+			data = append(data, x)
+			assertNoError(t, shard.Add(x))
+		}
+		shards = append(shards, shard)
+	}
+	sorted := append([]float64{}, data...)
+	sort.Float64s(sorted)
+
+	merged, err := MergeAll(100, shards...)
+	assertNoError(t, err)
+	sequential := New(100)
+	for _, shard := range shards {
+		assertNoError(t, sequential.Merge(shard))
+	}
+
+	if merged.Count() != uint64(len(data)) || merged.Min() != sorted[0] || merged.Max() != sorted[len(sorted)-1] {
+		t.Errorf("got %d samples in [%v, %v], expected %d in [%v, %v]",
+			merged.Count(), merged.Min(), merged.Max(), len(data), sorted[0], sorted[len(sorted)-1])
+	}
+	if math.Abs(merged.Mean()-sequential.Mean()) > 1e-9 {
+		t.Errorf("got mean %v, expected %v", merged.Mean(), sequential.Mean())
+	}
+	if got, loop := maxRankError(merged, sorted), maxRankError(sequential, sorted); got > loop+0.001 {
+		t.Errorf("got a rank error of %v, expected at most that of Merge, %v", got, loop)
+	}
+
+	empty, err := MergeAll(100, nil, New(100))
+	assertNoError(t, err)
+	if empty.Count() != 0 || empty.compression != 100 {
+		t.Errorf("Expected an empty digest, got %d samples", empty.Count())
+	}
+	if _, err := MergeAll(100, shards[2], New(0, WithMaxCentroidBound(100))); err != nil {
+		t.Errorf("Expected an empty digest of a different sizing to be skipped, got %v", err)
+	}
+	bounded := New(0, WithMaxCentroidBound(100))
+	assertNoError(t, bounded.Add(1))
+	if _, err := MergeAll(100, shards[2], bounded); err == nil {
+		t.Errorf("Expected an error merging incompatible digests")
+	}
+}
+
+func benchmarkShards(n int) []*TDigest {
+	shards := make([]*TDigest, n)
+	for i := range shards {
+		shards[i] = New(100)
+		for j := 0; j < 1000; j++ {
+			_ = shards[i].Add(rand.Float64())
+		}
+	}
+	return shards
+}
+
+func BenchmarkMergeSequential50(b *testing.B) {
+	shards := benchmarkShards(50)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := New(100)
+		for _, shard := range shards {
+			if err := t.Merge(shard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMergeAll50(b *testing.B) {
+	shards := benchmarkShards(50)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := MergeAll(100, shards...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package tdigest
+
+import "math/rand"
+
+// Sample draws n values distributed like the samples of the digest, by
+// inverse transform sampling: every value is the quantile of a uniform
+// draw from rng. The quantiles are interpolated linearly whatever the mode
+// of WithInterpolation, so that values drawn from within a centroid are
+// spread around its mean rather than all equal to it.
+//
+// The values only depend on the digest and on the state of rng. It returns
+// nil for an empty digest or if n is not positive.
+func (t *TDigest) Sample(n int, rng *rand.Rand) []float64 {
+	t.checkRead()
+
+	if n <= 0 || t.summary.Len() == 0 {
+		return nil
+	}
+
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = t.quantileLinear(rng.Float64())
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// ksDistance returns the Kolmogorov-Smirnov statistic of two sorted
+// samples, the largest distance between their empirical CDFs.
+func ksDistance(a, b []float64) float64 {
+	var d float64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if a[i] <= b[j] {
+			i++
+		} else {
+			j++
+		}
+		d = math.Max(d, math.Abs(float64(i)/float64(len(a))-float64(j)/float64(len(b))))
+	}
+	return d
+}
+
+func TestSample(t *testing.T) {
+	if s := New(100).Sample(10, rand.New(rand.NewSource(1))); s != nil {
+		t.Errorf("Expected no samples from an empty digest, got %v", s)
+	}
+
+	rng := rand.New(rand.NewSource(0x5a3))
+	for name, gen := range map[string]func() float64{
+		"uniform":     rng.Float64,
+		"normal":      rng.NormFloat64,
+		"exponential": rng.ExpFloat64,
+	} {
+		data := make([]float64, 100000)
+		tdigest := New(100)
+		for i := range data {
+			data[i] = gen()
+			assertNoError(t, tdigest.Add(data[i]))
+		}
+		sort.Float64s(data)
+
+		sample := tdigest.Sample(10000, rand.New(rand.NewSource(42)))
+		if !reflect.DeepEqual(sample, tdigest.Sample(10000, rand.New(rand.NewSource(42)))) {
+			t.Errorf("%s: expected the same samples from the same source", name)
+		}
+		if s := tdigest.Sample(0, rng); s != nil {
+			t.Errorf("%s: expected no samples for n=0, got %v", name, s)
+		}
+
+		distinct := make(map[float64]bool)
+		for _, x := range sample {
+			distinct[x] = true
+		}
+		if len(distinct) < len(sample)/2 {
+			t.Errorf("%s: got %d distinct values out of %d", name, len(distinct), len(sample))
+		}
+
+		// the critical value of the two sample test at the 0.001 level
+		sort.Float64s(sample)
+		n, m := float64(len(sample)), float64(len(data))
+		if d, critical := ksDistance(sample, data), 1.95*math.Sqrt((n+m)/(n*m)); d > critical {
+			t.Errorf("%s: got a KS statistic of %v, above %v", name, d, critical)
+		}
+	}
+}
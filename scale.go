@@ -0,0 +1,129 @@
+package tdigest
+
+import "math"
+
+// threshold returns the largest weight a centroid at quantile q may reach.
+func (t TDigest) threshold(q float64) float64 {
+	return t.thresholdAt(float64(t.count), q)
+}
+
+// thresholdAt is threshold for a digest of n samples.
+func (t TDigest) thresholdAt(n, q float64) float64 {
+	if t.delta > 0 {
+		// the weight for which the centroid spans one unit of k1 around q
+		return 2 * math.Pi * n * math.Sqrt(q*(1-q)) / float64(t.delta)
+	}
+	return 4 * n * q * (1 - q) / t.compression
+}
+
+// k1 is the arcsine scale function, mapping quantiles onto [-delta/4, delta/4].
+func k1(delta int, q float64) float64 {
+	return float64(delta) / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+// mergeable reports whether a single centroid may hold the ranks
+// [lo, lo+weight) of the digest.
+func (t TDigest) mergeable(lo, weight float64) bool {
+	if weight > math.MaxUint32 {
+		return false
+	}
+
+	n := float64(t.count)
+	if t.delta > 0 {
+		return k1(t.delta, (lo+weight)/n)-k1(t.delta, lo/n) <= 1
+	}
+
+	q := 0.5
+	if n > 1 {
+		q = (lo + (weight-1)/2) / (n - 1)
+	}
+	return weight <= t.threshold(q)
+}
+
+// cluster compresses the summary in place by greedily merging each centroid
+// into its left neighbor while the result stays mergeable.
+//
+// With the k1 scale function every pair of adjacent centroids left by this
+// spans more than one unit of k1, and since the whole range spans delta/2
+// units, at most delta centroids remain.
+func (t *TDigest) cluster() {
+	s := t.summary
+	if s.Len() <= 1 {
+		return
+	}
+
+	n := 0
+	var lo float64
+	for i := 1; i < s.Len(); i++ {
+		c, ci := float64(s.counts[n]), float64(s.counts[i])
+		if t.mergeable(lo, c+ci) {
+			s.absorb(n, i)
+		} else {
+			lo += c
+			n++
+			s.move(n, i)
+		}
+	}
+
+	s.cut(n+1, s.Len())
+}
+
+// backgroundLimit is the multiple of the compression trigger at which a
+// digest compacted in the background still compresses itself on insertion,
+// so that its size stays bounded when the compaction falls behind.
+const backgroundLimit = 10
+
+// autoCompress compresses the digest as needed after an insertion.
+func (t *TDigest) autoCompress() error {
+	if t.compressing {
+		return nil
+	}
+	n, trigger := float64(t.summary.Len()), t.compressionTrigger()
+	switch {
+	case t.maxCentroids > 0:
+		if n >= float64(t.maxCentroids) {
+			return t.compress()
+		}
+	case t.background:
+		if n > backgroundLimit*trigger {
+			return t.compress()
+		}
+	case t.budget > 0 && n <= 2*trigger:
+		t.compressStep()
+	case n > trigger:
+		return t.compress()
+	}
+	return nil
+}
+
+// compressionTrigger returns the number of centroids above which the digest
+// compresses itself.
+func (t TDigest) compressionTrigger() float64 {
+	if t.maxCentroids > 0 {
+		return float64(t.maxCentroids - 1)
+	}
+	if t.trigger > 0 {
+		return float64(t.trigger)
+	}
+	if t.delta > 0 {
+		return 2 * float64(t.delta)
+	}
+	return 20 * t.compression
+}
+
+func (t TDigest) estimateCapacity() uint {
+	if t.capacity > 0 {
+		if t.maxCentroids > 0 && t.capacity > t.maxCentroids {
+			return uint(t.maxCentroids)
+		}
+		return uint(t.capacity)
+	}
+	if t.maxCentroids > 0 {
+		// the buffers grow as needed, see summary.grow
+		return 0
+	}
+	if t.delta > 0 {
+		return uint(2*t.delta + 1)
+	}
+	return uint(t.compression) * 10
+}
@@ -0,0 +1,77 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestMaxCentroidBound(t *testing.T) {
+	const n = 50000
+
+	streams := map[string]func(i int) float64{
+		"uniform":     func(i int) float64 { return rand.Float64() },
+		"ascending":   func(i int) float64 { return float64(i) },
+		"descending":  func(i int) float64 { return float64(n - i) },
+		"alternating": func(i int) float64 { return float64(i%2) * float64(i) },
+		"exponential": func(i int) float64 { return rand.ExpFloat64() },
+		"duplicates":  func(i int) float64 { return float64(rand.Intn(5)) },
+	}
+
+	for name, next := range streams {
+		for _, delta := range []int{1, 10, 100} {
+			tdigest := New(0, WithMaxCentroidBound(delta))
+
+			for i := 0; i < n; i++ {
+				assertNoError(t, tdigest.Add(next(i)))
+
+				if tdigest.summary.Len() > 2*delta+1 {
+					t.Fatalf("%s: delta=%d grew to %d centroids", name, delta, tdigest.summary.Len())
+				}
+				if i%1000 == 0 {
+					assertNoError(t, tdigest.Compress())
+					if tdigest.summary.Len() > delta {
+						t.Fatalf("%s: delta=%d compressed to %d centroids", name, delta, tdigest.summary.Len())
+					}
+				}
+			}
+
+			assertNoError(t, tdigest.Compress())
+			if tdigest.summary.Len() > delta {
+				t.Errorf("%s: delta=%d compressed to %d centroids", name, delta, tdigest.summary.Len())
+			}
+			if tdigest.Count() != n {
+				t.Errorf("%s: delta=%d lost samples, count %d", name, delta, tdigest.Count())
+			}
+		}
+	}
+}
+
+func TestMaxCentroidBoundAccuracy(t *testing.T) {
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rand.Float64()
+	}
+
+	legacy := New(100)
+	bounded := New(0, WithMaxCentroidBound(314))
+	for _, x := range data {
+		assertNoError(t, legacy.Add(x))
+		assertNoError(t, bounded.Add(x))
+	}
+	sort.Float64s(data)
+
+	if math.Abs(bounded.compression-100) > 0.1 {
+		t.Errorf("Expected the equivalent legacy compression to be 100, got %.4f", bounded.compression)
+	}
+
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		exact := quantile(q, data)
+		legacyErr := math.Abs(legacy.Quantile(q) - exact)
+		boundedErr := math.Abs(bounded.Quantile(q) - exact)
+		if boundedErr > 2*legacyErr+0.002 {
+			t.Errorf("q=%.3f: bounded error %.5f, legacy error %.5f", q, boundedErr, legacyErr)
+		}
+	}
+}
@@ -7,15 +7,46 @@ import (
 	"math"
 )
 
-const smallEncoding int32 = 2
+const (
+	// largeEncoding stores every mean as a float64.
+	largeEncoding int32 = 1
+	// smallEncoding stores the deltas between consecutive means as float32.
+	smallEncoding int32 = 2
+)
 
 // Marshal serializes the digest into a byte array so it can be
 // saved to disk or sent over the wire. buf is used as a backing array, but
 // the returned array may be different if it does not fit.
+//
+// The means are stored as float32 deltas when possible, and as full float64
+// values when some delta does not fit in a float32.
+//
+// Digests of at most 15 centroids use a more compact encoding with a two
+// byte header when it stores every mean with the relative precision of a
+// float32, which is the case unless their magnitudes are very different.
 func (t TDigest) Marshal(buf []byte) []byte {
+	t.checkRead()
+
+	start := len(buf)
+	buf = t.appendStandard(buf)
+	if t.summary.Len() <= tinyMaxCentroids {
+		if tiny, ok := t.appendTiny(nil); ok && len(tiny) < len(buf)-start {
+			buf = append(buf[:start], tiny...)
+		}
+	}
+	return buf
+}
+
+// appendStandard appends the small or large encoding of the digest to buf.
+func (t TDigest) appendStandard(buf []byte) []byte {
 	var scratch [8]byte
 
-	binary.BigEndian.PutUint32(scratch[:], uint32(smallEncoding))
+	encoding := smallEncoding
+	if !t.fitsSmallEncoding() {
+		encoding = largeEncoding
+	}
+
+	binary.BigEndian.PutUint32(scratch[:], uint32(encoding))
 	buf = append(buf, scratch[:4]...)
 
 	binary.BigEndian.PutUint64(scratch[:], math.Float64bits(t.compression))
@@ -26,10 +57,15 @@ func (t TDigest) Marshal(buf []byte) []byte {
 
 	var x float64
 	t.summary.ForEach(func(mean float64, count uint32) bool {
+		if encoding == largeEncoding {
+			binary.BigEndian.PutUint64(scratch[:], math.Float64bits(mean))
+			buf = append(buf, scratch[:8]...)
+			return true
+		}
+
 		delta := mean - x
 		x = mean
 
-		var scratch [4]byte
 		binary.BigEndian.PutUint32(scratch[:], math.Float32bits(float32(delta)))
 		buf = append(buf, scratch[:4]...)
 
@@ -41,16 +77,50 @@ func (t TDigest) Marshal(buf []byte) []byte {
 		return true
 	})
 
-	return buf
+	return t.appendSections(buf, false, false)
 }
 
-// and deserializes it. It will panic if the byte slice is not large enough to
-// decode.
+// fitsSmallEncoding reports whether every delta between consecutive means
+// can be represented as a finite float32.
+func (t TDigest) fitsSmallEncoding() bool {
+	var x float64
+	fits := true
+	t.summary.ForEach(func(mean float64, count uint32) bool {
+		delta := float32(mean - x)
+		x = mean
+
+		fits = !math.IsInf(float64(delta), 0) && !math.IsNaN(float64(delta))
+		return fits
+	})
+	return fits
+}
+
+// DefaultMaxCentroids is the largest number of centroids FromBytes accepts.
+// It is comfortably above what a digest with a compression in the low
+// thousands can hold, use FromBytesWithLimit to decode larger digests.
+const DefaultMaxCentroids = 1 << 16
+
+// FromBytes takes a byte slice generated by Marshal and deserializes it,
+// rejecting payloads with more than DefaultMaxCentroids centroids.
 func FromBytes(buf []byte) (t *TDigest, err error) {
+	return FromBytesWithLimit(buf, DefaultMaxCentroids)
+}
+
+// FromBytesWithLimit is like FromBytes but rejects payloads with more than
+// maxCentroids centroids. The claimed number of centroids is checked against
+// the length of the payload before anything is allocated for them.
+func FromBytesWithLimit(buf []byte, maxCentroids int) (t *TDigest, err error) {
+	if len(buf) > 0 && buf[0]>>4 == tinyEncoding {
+		return fromTiny(buf, maxCentroids)
+	}
+	if len(buf) < 16 {
+		return nil, errors.New("serialization too short for header")
+	}
+
 	encoding := int32(binary.BigEndian.Uint32(buf))
 	buf = buf[4:]
 
-	if encoding != smallEncoding {
+	if encoding != smallEncoding && encoding != largeEncoding {
 		return nil, fmt.Errorf("Unsupported encoding version: %d", encoding)
 	}
 
@@ -62,36 +132,380 @@ func FromBytes(buf []byte) (t *TDigest, err error) {
 	numCentroids := int32(binary.BigEndian.Uint32(buf))
 	buf = buf[4:]
 
-	if numCentroids < 0 || numCentroids > 1<<22 {
-		return nil, errors.New("bad number of centroids in serialization")
+	if numCentroids < 0 || int64(numCentroids) > int64(maxCentroids) {
+		return nil, fmt.Errorf("bad number of centroids in serialization: %d", numCentroids)
+	}
+
+	meanSize := 4
+	if encoding == largeEncoding {
+		meanSize = 8
+	}
+
+	// every centroid takes meanSize bytes for its mean and at least 1 for
+	// its count
+	if int64(len(buf)) < int64(meanSize+1)*int64(numCentroids) {
+		return nil, fmt.Errorf("serialization too short for %d centroids", numCentroids)
 	}
 
 	means := make([]float64, numCentroids)
 	var x float64
 	for i := 0; i < int(numCentroids); i++ {
+		if encoding == largeEncoding {
+			means[i] = math.Float64frombits(binary.BigEndian.Uint64(buf))
+			buf = buf[8:]
+			if math.IsNaN(means[i]) {
+				return nil, errors.New("NaN mean in serialization")
+			}
+			continue
+		}
+
 		delta := float64(math.Float32frombits(binary.BigEndian.Uint32(buf)))
 		buf = buf[4:]
+		if math.IsInf(delta, 0) || math.IsNaN(delta) {
+			return nil, fmt.Errorf("invalid mean delta in serialization: %v", delta)
+		}
 
 		x += delta
 		means[i] = x
 	}
 
-	for i := 0; i < int(numCentroids); i++ {
-		var decUint uint32
-		decUint, buf, err = decodeUint32(buf)
+	counts := make([]uint32, numCentroids)
+	for i := range counts {
+		counts[i], buf, err = decodeUint32(buf)
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	return restore(t, means, counts, buf)
+}
+
+// restore adds the decoded centroids to the empty digest t, after applying
+// the sections in buf.
+func restore(t *TDigest, means []float64, counts []uint32, buf []byte) (*TDigest, error) {
+	decoded, err := t.readSections(buf, len(counts))
+	if err != nil {
+		return nil, err
+	}
+	m2 := decoded.m2
+	lo, hi := t.min, t.max
+	if m2 != nil {
+		t.variance = true
+	}
+	if m2 != nil || t.maxCentroids > 0 {
+		t.summary = t.newSummary(t.estimateCapacity())
+	}
 
-		err = t.AddWeighted(means[i], decUint)
+	for i := range counts {
+		var v float64
+		if m2 != nil {
+			v = m2[i]
+		}
+
+		err = t.addCentroidAt(means[i], counts[i], v)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// the decoded means may lie slightly outside of the exact extremes
+	if lo <= hi {
+		t.min, t.max = lo, hi
+	}
+	if decoded.hasSum {
+		t.sum = decoded.sum
+	}
+	if decoded.hasDeviation {
+		t.m2 = decoded.deviation
+	}
+	if decoded.hasHigherMoments {
+		t.m3, t.m4 = decoded.m3, decoded.m4
+	}
+
 	return t, nil
 }
 
+// Optional data follows the centroids as a sequence of sections, each made
+// of a varint tag, a varint length and that many bytes of payload. Decoders
+// skip sections with tags they do not know, and decoders predating sections
+// ignore them entirely.
+const (
+	sectionMetadata = 1
+	// sectionVariance holds the sum of squared deviations of every
+	// centroid as a big endian float64, in the order of the centroids.
+	sectionVariance = 2
+	// sectionQuantization holds the step of WithQuantization as a big
+	// endian float64.
+	sectionQuantization = 3
+	// sectionGreedyCandidates has no payload and marks digests created with
+	// WithGreedyCandidates.
+	sectionGreedyCandidates = 4
+	// sectionExtremes holds the smallest and largest samples as big endian
+	// float64 values. The tiny encoding leaves it out when they are exactly
+	// the first and last means it decodes, as is the case for digests of a
+	// few samples with integer values.
+	sectionExtremes = 5
+	// sectionSum holds the sum of the samples as a big endian float64. The
+	// tiny encoding leaves it out, along with sectionDeviation, when the
+	// centroids it stores exactly add up to them.
+	sectionSum = 6
+	// sectionDeviation holds the sum of the squared deviations of the
+	// samples from their mean as a big endian float64.
+	sectionDeviation = 7
+	// sectionInterpolation holds the Interpolation of WithInterpolation as
+	// a varint, and is left out for the default InterpolationLinear.
+	sectionInterpolation = 8
+	// sectionExactTails holds the tails of WithExactTails: k and the number
+	// of samples they were offered as varints, then the smallest and the
+	// largest samples, each as a varint length followed by big endian
+	// float64 values in increasing order.
+	sectionExactTails = 9
+	// sectionHigherMoments holds the sums of the cubed and fourth power
+	// deviations of the samples from their mean as big endian float64
+	// values. Like sectionSum, the tiny encoding can leave it out.
+	sectionHigherMoments = 10
+	// sectionInfiniteValues has no payload and marks digests created with
+	// WithInfiniteValues.
+	sectionInfiniteValues = 11
+	// sectionAutoCompress holds the threshold of WithAutoCompressThreshold
+	// as a varint.
+	sectionAutoCompress = 12
+	// sectionDeterministicCompress has no payload and marks digests created
+	// with WithDeterministicCompress.
+	sectionDeterministicCompress = 13
+	// sectionCentroidBound holds the delta of WithMaxCentroidBound as a
+	// varint.
+	sectionCentroidBound = 14
+	// sectionSmallFootprint holds the maxCentroids of WithSmallFootprint as
+	// a varint.
+	sectionSmallFootprint = 15
+)
+
+// sections holds what readSections decoded that is applied to the digest
+// only once its centroids are added.
+type sections struct {
+	// m2 holds the centroid variances, if any.
+	m2 []float64
+
+	sum, deviation       float64
+	hasSum, hasDeviation bool
+
+	m3, m4           float64
+	hasHigherMoments bool
+}
+
+// appendSections appends the optional sections of the digest to buf. The
+// extremes are left out when the encoding restores them from the first and
+// last means, which it reports with ends, and the moments of the samples
+// when the decoded centroids add up to them, which it reports with moments.
+func (t TDigest) appendSections(buf []byte, ends, moments bool) []byte {
+	if len(t.metadata) > 0 {
+		buf = appendSection(buf, sectionMetadata, t.metadata)
+	}
+	if t.summary.m2 != nil {
+		payload := make([]byte, 8*len(t.summary.m2))
+		for i, m2 := range t.summary.m2 {
+			binary.BigEndian.PutUint64(payload[8*i:], math.Float64bits(m2))
+		}
+		buf = appendSection(buf, sectionVariance, payload)
+	}
+	if t.step != 0 {
+		var payload [8]byte
+		binary.BigEndian.PutUint64(payload[:], math.Float64bits(t.step))
+		buf = appendSection(buf, sectionQuantization, payload[:])
+	}
+	if t.greedy {
+		buf = appendSection(buf, sectionGreedyCandidates, nil)
+	}
+	if t.deterministic {
+		buf = appendSection(buf, sectionDeterministicCompress, nil)
+	}
+	if t.infinite {
+		buf = appendSection(buf, sectionInfiniteValues, nil)
+	}
+	if t.trigger > 0 {
+		var payload [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(payload[:], uint64(t.trigger))
+		buf = appendSection(buf, sectionAutoCompress, payload[:n])
+	}
+	if t.delta > 0 {
+		var payload [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(payload[:], uint64(t.delta))
+		buf = appendSection(buf, sectionCentroidBound, payload[:n])
+	}
+	if t.maxCentroids > 0 {
+		var payload [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(payload[:], uint64(t.maxCentroids))
+		buf = appendSection(buf, sectionSmallFootprint, payload[:n])
+	}
+	if t.interpolation != InterpolationLinear {
+		var payload [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(payload[:], uint64(t.interpolation))
+		buf = appendSection(buf, sectionInterpolation, payload[:n])
+	}
+	if t.tails != nil {
+		buf = appendSection(buf, sectionExactTails, t.tails.appendTo(nil))
+	}
+	if t.summary.Len() > 0 && t.min <= t.max && !ends {
+		var payload [16]byte
+		binary.BigEndian.PutUint64(payload[:], math.Float64bits(t.min))
+		binary.BigEndian.PutUint64(payload[8:], math.Float64bits(t.max))
+		buf = appendSection(buf, sectionExtremes, payload[:])
+	}
+	if t.summary.Len() > 0 && !moments {
+		var payload [8]byte
+		binary.BigEndian.PutUint64(payload[:], math.Float64bits(t.sum))
+		buf = appendSection(buf, sectionSum, payload[:])
+		binary.BigEndian.PutUint64(payload[:], math.Float64bits(t.m2))
+		buf = appendSection(buf, sectionDeviation, payload[:])
+
+		// moments that overflowed or saw infinite samples are left for the
+		// decoder to rebuild from the centroids
+		if validHigherMoments(t.m3, t.m4) {
+			var higher [16]byte
+			binary.BigEndian.PutUint64(higher[:], math.Float64bits(t.m3))
+			binary.BigEndian.PutUint64(higher[8:], math.Float64bits(t.m4))
+			buf = appendSection(buf, sectionHigherMoments, higher[:])
+		}
+	}
+	return buf
+}
+
+// validHigherMoments reports whether m3 is finite and m4 is not negative.
+func validHigherMoments(m3, m4 float64) bool {
+	return !math.IsNaN(m3) && !math.IsInf(m3, 0) && m4 >= 0
+}
+
+func appendSection(buf []byte, tag uint64, payload []byte) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	buf = append(buf, scratch[:binary.PutUvarint(scratch[:], tag)]...)
+	buf = append(buf, scratch[:binary.PutUvarint(scratch[:], uint64(len(payload)))]...)
+	return append(buf, payload...)
+}
+
+// readSections applies the sections in buf to t, returning those that must
+// wait for the numCentroids centroids to be added.
+func (t *TDigest) readSections(buf []byte, numCentroids int) (s sections, err error) {
+	for len(buf) > 0 {
+		tag, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return s, errors.New("invalid section tag")
+		}
+		buf = buf[n:]
+
+		size, n := binary.Uvarint(buf)
+		if n <= 0 || size > uint64(len(buf)-n) {
+			return s, errors.New("invalid section length")
+		}
+		payload := buf[n : n+int(size)]
+		buf = buf[n+int(size):]
+
+		switch tag {
+		case sectionMetadata:
+			if err := t.SetMetadata(payload); err != nil {
+				return s, err
+			}
+		case sectionVariance:
+			if len(payload) != 8*numCentroids {
+				return s, errors.New("invalid centroid variances")
+			}
+			s.m2 = make([]float64, numCentroids)
+			for i, m2 := range s.m2 {
+				m2 = math.Float64frombits(binary.BigEndian.Uint64(payload[8*i:]))
+				if !(m2 >= 0) || math.IsInf(m2, 0) {
+					return s, fmt.Errorf("invalid centroid variance: %v", m2)
+				}
+				s.m2[i] = m2
+			}
+		case sectionQuantization:
+			if len(payload) != 8 {
+				return s, errors.New("invalid quantization step")
+			}
+			step := math.Float64frombits(binary.BigEndian.Uint64(payload))
+			if !(step > 0) || math.IsInf(step, 0) {
+				return s, fmt.Errorf("invalid quantization step: %v", step)
+			}
+			t.step = step
+		case sectionGreedyCandidates:
+			t.greedy = true
+		case sectionDeterministicCompress:
+			t.deterministic = true
+		case sectionInfiniteValues:
+			t.infinite = true
+		case sectionAutoCompress:
+			threshold, n := binary.Uvarint(payload)
+			if n <= 0 || n != len(payload) || threshold < 1 || threshold > math.MaxInt32 {
+				return s, errors.New("invalid auto-compress threshold")
+			}
+			t.trigger = int(threshold)
+		case sectionCentroidBound:
+			delta, n := binary.Uvarint(payload)
+			if n <= 0 || n != len(payload) || delta < 1 || delta > math.MaxInt32 {
+				return s, errors.New("invalid centroid bound")
+			}
+			t.delta = int(delta)
+			t.compression = float64(t.delta) / math.Pi
+		case sectionSmallFootprint:
+			limit, n := binary.Uvarint(payload)
+			if n <= 0 || n != len(payload) || limit < 2 || limit > math.MaxInt32 || int(limit) < numCentroids {
+				return s, errors.New("invalid small footprint")
+			}
+			t.maxCentroids = int(limit)
+		case sectionInterpolation:
+			mode, n := binary.Uvarint(payload)
+			if n <= 0 || n != len(payload) || mode > math.MaxInt32 || !Interpolation(mode).valid() {
+				return s, errors.New("invalid interpolation")
+			}
+			t.interpolation = Interpolation(mode)
+		case sectionExactTails:
+			t.tails, err = decodeExactTails(payload)
+			if err != nil {
+				return s, err
+			}
+		case sectionExtremes:
+			if len(payload) != 16 {
+				return s, errors.New("invalid extremes")
+			}
+			lo := math.Float64frombits(binary.BigEndian.Uint64(payload))
+			hi := math.Float64frombits(binary.BigEndian.Uint64(payload[8:]))
+			if !(lo <= hi) {
+				return s, fmt.Errorf("invalid extremes: %v and %v", lo, hi)
+			}
+			t.observe(lo, hi)
+		case sectionSum:
+			if len(payload) != 8 {
+				return s, errors.New("invalid sum")
+			}
+			s.sum = math.Float64frombits(binary.BigEndian.Uint64(payload))
+			// samples at both -Inf and +Inf add up to NaN
+			if math.IsNaN(s.sum) && !t.infinite {
+				return s, errors.New("NaN sum in serialization")
+			}
+			s.hasSum = true
+		case sectionDeviation:
+			if len(payload) != 8 {
+				return s, errors.New("invalid deviation")
+			}
+			s.deviation = math.Float64frombits(binary.BigEndian.Uint64(payload))
+			if !(s.deviation >= 0) && !(math.IsNaN(s.deviation) && t.infinite) {
+				return s, fmt.Errorf("invalid deviation: %v", s.deviation)
+			}
+			s.hasDeviation = true
+		case sectionHigherMoments:
+			if len(payload) != 16 {
+				return s, errors.New("invalid higher moments")
+			}
+			s.m3 = math.Float64frombits(binary.BigEndian.Uint64(payload))
+			s.m4 = math.Float64frombits(binary.BigEndian.Uint64(payload[8:]))
+			if !validHigherMoments(s.m3, s.m4) {
+				return s, fmt.Errorf("invalid higher moments: %v and %v", s.m3, s.m4)
+			}
+			s.hasHigherMoments = true
+		}
+	}
+	return s, nil
+}
+
 func encodeUint32(buf []byte, n uint32) []byte {
 	var b [binary.MaxVarintLen32]byte
 	l := binary.PutUvarint(b[:], uint64(n))
@@ -100,6 +514,9 @@ func encodeUint32(buf []byte, n uint32) []byte {
 
 func decodeUint32(buf []byte) (uint32, []byte, error) {
 	v, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return 0, nil, errors.New("invalid varint")
+	}
 	if v > 0xffffffff {
 		return 0, nil, fmt.Errorf("value too large: %d", v)
 	}
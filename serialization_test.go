@@ -1,6 +1,8 @@
 package tdigest
 
 import (
+	"encoding/binary"
+	"math"
 	"math/rand"
 	"testing"
 )
@@ -53,6 +55,114 @@ func TestSerialization(t *testing.T) {
 	}
 }
 
+func TestSerializationCentroidBound(t *testing.T) {
+	t1 := New(0, WithMaxCentroidBound(50))
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, t1.Add(rand.Float64()))
+	}
+
+	t2, err := FromBytes(t1.Marshal(nil))
+	assertNoError(t, err)
+	if t2.delta != 50 || t2.compression != t1.compression {
+		t.Fatalf("got delta %d and compression %v, expected 50 and %v", t2.delta, t2.compression, t1.compression)
+	}
+
+	// the decoded digest keeps compressing to the bound
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, t2.Add(rand.Float64()))
+		if t2.summary.Len() > 100 {
+			t.Fatalf("got %d centroids, expected at most 100 between compressions", t2.summary.Len())
+		}
+	}
+	assertNoError(t, t2.Compress())
+	if t2.summary.Len() > 50 {
+		t.Errorf("got %d centroids after Compress, expected at most 50", t2.summary.Len())
+	}
+}
+
+func TestSerializationExtremeMeans(t *testing.T) {
+	t1 := New(100)
+	values := []float64{-math.MaxFloat64 / 2, -1e39, 1, 1e39, 1e300, math.MaxFloat64}
+	for _, v := range values {
+		assertNoError(t, t1.Add(v))
+	}
+
+	serialized := t1.Marshal(nil)
+
+	t2, err := FromBytes(serialized)
+	assertNoError(t, err)
+
+	if t1.Count() != t2.Count() || t1.summary.Len() != t2.summary.Len() {
+		t.Fatal("Deserialized to something different.")
+	}
+	for i := 0; i < t1.summary.Len(); i++ {
+		if t1.summary.Mean(i) != t2.summary.Mean(i) {
+			t.Errorf("mean %d: got %v, expected %v", i, t2.summary.Mean(i), t1.summary.Mean(i))
+		}
+	}
+
+	// digests that fit keep using the compact encoding
+	small := New(100)
+	for i := 1; i <= 10; i++ {
+		assertNoError(t, small.Add(1e30*float64(i)))
+		assertNoError(t, small.Add(-1e30*float64(i)))
+	}
+	if got := int32(binary.BigEndian.Uint32(small.Marshal(nil))); got != smallEncoding {
+		t.Errorf("Expected the small encoding, got %d", got)
+	}
+}
+
+func TestDeserializationRejectsInvalidDeltas(t *testing.T) {
+	t1 := New(100)
+	for i := 1; i <= 20; i++ {
+		assertNoError(t, t1.Add(float64(i)))
+	}
+	serialized := t1.Marshal(nil)
+
+	for _, bad := range []float32{float32(math.Inf(1)), float32(math.Inf(-1)), float32(math.NaN())} {
+		corrupted := append([]byte(nil), serialized...)
+		binary.BigEndian.PutUint32(corrupted[20:], math.Float32bits(bad))
+		if _, err := FromBytes(corrupted); err == nil {
+			t.Errorf("Expected a %v delta to be rejected", bad)
+		}
+	}
+}
+
+func TestDeserializationLimits(t *testing.T) {
+	t1 := New(100)
+	for i := 0; i < 70000; i++ {
+		assertNoError(t, t1.summary.Add(float64(i), 1))
+		t1.count++
+	}
+	serialized := t1.Marshal(nil)
+
+	if _, err := FromBytes(serialized); err == nil {
+		t.Errorf("Expected FromBytes to reject %d centroids", t1.summary.Len())
+	}
+
+	if _, err := FromBytesWithLimit(serialized, 1000); err == nil {
+		t.Errorf("Expected FromBytesWithLimit to enforce the limit")
+	}
+
+	t2, err := FromBytesWithLimit(serialized, 1<<17)
+	assertNoError(t, err)
+	if t2.Count() != t1.Count() {
+		t.Errorf("Deserialized count %d, expected %d", t2.Count(), t1.Count())
+	}
+
+	// a payload claiming more centroids than it has room for is rejected
+	// before decoding any of them
+	if _, err := FromBytesWithLimit(serialized[:len(serialized)/2], 1<<17); err == nil {
+		t.Errorf("Expected a truncated payload to be rejected")
+	}
+
+	for _, n := range []int{0, 3, 15} {
+		if _, err := FromBytes(serialized[:n]); err == nil {
+			t.Errorf("Expected a %d byte payload to be rejected", n)
+		}
+	}
+}
+
 func BenchmarkSerialization(b *testing.B) {
 	t := New(10)
 	for i := 0; i < 10000; i++ {
@@ -0,0 +1,63 @@
+package tdigest
+
+import (
+	"sort"
+	"sync"
+)
+
+// Set is a collection of digests keyed by label, such as one digest of
+// request latencies per route. It is safe for concurrent use.
+type Set struct {
+	compression float64
+	opts        []Option
+
+	mu      sync.RWMutex
+	digests map[string]*Concurrent
+}
+
+// NewSet creates an empty set whose digests are created on first use with
+// New(compression, opts...).
+func NewSet(compression float64, opts ...Option) *Set {
+	return &Set{
+		compression: compression,
+		opts:        opts,
+		digests:     make(map[string]*Concurrent),
+	}
+}
+
+// Get returns the digest for label, creating it if needed.
+func (s *Set) Get(label string) *Concurrent {
+	s.mu.RLock()
+	c, ok := s.digests[label]
+	s.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.digests[label]; ok {
+		return c
+	}
+	c = NewConcurrent(New(s.compression, s.opts...))
+	s.digests[label] = c
+	return c
+}
+
+// Observe adds value to the digest for label.
+func (s *Set) Observe(label string, value float64) error {
+	return s.Get(label).Add(value)
+}
+
+// Labels returns the labels of the digests in the set, sorted.
+func (s *Set) Labels() []string {
+	s.mu.RLock()
+	labels := make([]string, 0, len(s.digests))
+	for label := range s.digests {
+		labels = append(labels, label)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(labels)
+	return labels
+}
@@ -0,0 +1,109 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// SizeBytes returns the approximate number of bytes of memory held by the
+// digest: its buffers of centroids, the tree of their counts and the struct
+// overhead. Memory shared with other values, such as the callbacks given to
+// OnThreshold, is not included.
+func (t *TDigest) SizeBytes() int {
+	t.checkRead()
+
+	s := t.summary
+	size := int(unsafe.Sizeof(*t)) + int(unsafe.Sizeof(*s)) +
+		8*cap(s.means) + 4*cap(s.counts) + 8*cap(s.bitree.buf) + 8*cap(s.m2)
+	size += cap(t.metadata)
+	size += int(unsafe.Sizeof(t.thresholds[0])) * cap(t.thresholds)
+	size += int(unsafe.Sizeof(thresholdCallback{})) * len(t.thresholds)
+	return size
+}
+
+// MarshaledSizeBytes returns the number of bytes Marshal appends for the
+// current state of the digest, without encoding it.
+func (t *TDigest) MarshaledSizeBytes() int {
+	t.checkRead()
+
+	n := t.summary.Len()
+	size := 16
+	if t.fitsSmallEncoding() {
+		size += 4 * n
+	} else {
+		size += 8 * n
+	}
+	for _, count := range t.summary.counts {
+		size += uvarintSize(uint64(count))
+	}
+	size += t.sectionsSize(false, false)
+
+	// the tiny encoding holds few centroids, so building it is cheap
+	if n <= tinyMaxCentroids {
+		if tiny, ok := t.appendTiny(nil); ok && len(tiny) < size {
+			size = len(tiny)
+		}
+	}
+	return size
+}
+
+// sectionsSize returns the number of bytes appendSections appends, and
+// must be kept in step with it.
+func (t TDigest) sectionsSize(ends, moments bool) int {
+	var size int
+	if len(t.metadata) > 0 {
+		size += sectionSize(sectionMetadata, len(t.metadata))
+	}
+	if t.summary.m2 != nil {
+		size += sectionSize(sectionVariance, 8*len(t.summary.m2))
+	}
+	if t.step != 0 {
+		size += sectionSize(sectionQuantization, 8)
+	}
+	if t.greedy {
+		size += sectionSize(sectionGreedyCandidates, 0)
+	}
+	if t.deterministic {
+		size += sectionSize(sectionDeterministicCompress, 0)
+	}
+	if t.infinite {
+		size += sectionSize(sectionInfiniteValues, 0)
+	}
+	if t.trigger > 0 {
+		size += sectionSize(sectionAutoCompress, uvarintSize(uint64(t.trigger)))
+	}
+	if t.delta > 0 {
+		size += sectionSize(sectionCentroidBound, uvarintSize(uint64(t.delta)))
+	}
+	if t.maxCentroids > 0 {
+		size += sectionSize(sectionSmallFootprint, uvarintSize(uint64(t.maxCentroids)))
+	}
+	if t.interpolation != InterpolationLinear {
+		size += sectionSize(sectionInterpolation, uvarintSize(uint64(t.interpolation)))
+	}
+	if e := t.tails; e != nil {
+		size += sectionSize(sectionExactTails, uvarintSize(uint64(e.k))+uvarintSize(e.seen)+
+			uvarintSize(uint64(len(e.low)))+8*len(e.low)+uvarintSize(uint64(len(e.high)))+8*len(e.high))
+	}
+	if t.summary.Len() > 0 && t.min <= t.max && !ends {
+		size += sectionSize(sectionExtremes, 16)
+	}
+	if t.summary.Len() > 0 && !moments {
+		size += sectionSize(sectionSum, 8) + sectionSize(sectionDeviation, 8)
+		if validHigherMoments(t.m3, t.m4) {
+			size += sectionSize(sectionHigherMoments, 16)
+		}
+	}
+	return size
+}
+
+// sectionSize returns the number of bytes appendSection appends for a
+// payload of the given length.
+func sectionSize(tag uint64, length int) int {
+	return uvarintSize(tag) + uvarintSize(uint64(length)) + length
+}
+
+func uvarintSize(x uint64) int {
+	var scratch [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(scratch[:], x)
+}
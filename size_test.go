@@ -0,0 +1,62 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMarshaledSizeBytes(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5123))
+	options := [][]Option{
+		nil,
+		{WithCentroidVariance()},
+		{WithQuantization(0.25)},
+		{WithGreedyCandidates()},
+		{WithMaxCentroidBound(50)},
+		{WithSmallFootprint(64)},
+	}
+
+	for _, opts := range options {
+		for _, n := range []int{0, 1, 2, 10, 100, 10000} {
+			for _, gen := range []func() float64{
+				rng.Float64,
+				func() float64 { return float64(rng.Intn(10)) },
+				func() float64 { return rng.ExpFloat64() * math.Pow(10, float64(rng.Intn(600)-300)) },
+			} {
+				tdigest := New(100, opts...)
+				for i := 0; i < n; i++ {
+					assertNoError(t, tdigest.AddWeighted(gen(), uint32(1+rng.Intn(1000))))
+				}
+				if n%2 == 0 {
+					assertNoError(t, tdigest.SetMetadata([]byte("metadata")))
+				}
+
+				if got, expected := tdigest.MarshaledSizeBytes(), len(tdigest.Marshal(nil)); got != expected {
+					t.Errorf("%d samples with options %d: got %d bytes, Marshal returned %d", n, len(opts), got, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestSizeBytes(t *testing.T) {
+	tdigest := New(100)
+	empty := tdigest.SizeBytes()
+	if empty <= 0 {
+		t.Errorf("Expected a positive size for an empty digest, got %d", empty)
+	}
+
+	for i := 0; i < 100000; i++ {
+		assertNoError(t, tdigest.Add(rand.Float64()))
+	}
+	size := tdigest.SizeBytes()
+	if min := 20 * tdigest.summary.Len(); size < min {
+		t.Errorf("Expected at least %d bytes for %d centroids, got %d", min, tdigest.summary.Len(), size)
+	}
+
+	assertNoError(t, tdigest.Rescale(10))
+	if rescaled := tdigest.SizeBytes(); rescaled > size/4 {
+		t.Errorf("Expected rescaling to release most of the %d bytes, got %d", size, rescaled)
+	}
+}
@@ -0,0 +1,73 @@
+package tdigest
+
+import "math"
+
+// SplitAt splits the digest at its quantile q into a digest of the samples
+// below it and one of the samples above it, which approximate the
+// conditional distributions on either side of Quantile(q). The centroid
+// straddling the rank q*Count() is shared between both in proportion of
+// its weight on either side, rounded to whole samples, so that the counts
+// of lower and upper add up to Count(). Both keep the options of the
+// digest, and lower is empty when q is 0, upper when q is 1.
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) SplitAt(q float64) (lower, upper *TDigest) {
+	if !(q >= 0 && q <= 1) {
+		panic("q must be between 0 and 1 (inclusive)")
+	}
+	t.checkRead()
+
+	lower, upper = t.emptyCopy(), t.emptyCopy()
+	if t.summary.Len() == 0 {
+		return lower, upper
+	}
+
+	// centroids with their mean on the wrong side of the cut, as the shared
+	// one can be, are moved to it
+	cut := t.quantile(q)
+	rank := math.Round(q * float64(t.count))
+
+	var before float64
+	for i := 0; i < t.summary.Len(); i++ {
+		mean, count, m2 := t.summary.Mean(i), float64(t.summary.Count(i)), t.summary.M2(i)
+		below := math.Max(0, math.Min(count, rank-before))
+		before += count
+
+		if below > 0 {
+			lower.insertCentroid(math.Min(mean, cut), uint32(below), m2*below/count)
+		}
+		if below < count {
+			upper.insertCentroid(math.Max(mean, cut), uint32(count-below), m2*(count-below)/count)
+		}
+	}
+
+	if lower.count > 0 {
+		lower.observe(t.min, cut)
+	}
+	if upper.count > 0 {
+		upper.observe(cut, t.max)
+	}
+	return lower, upper
+}
+
+// emptyCopy returns an empty digest with the options of t.
+func (t *TDigest) emptyCopy() *TDigest {
+	c := &TDigest{
+		min: math.Inf(1),
+		max: math.Inf(-1),
+	}
+	t.copyOptions(c)
+	if t.tails != nil {
+		c.tails = &exactTails{k: t.tails.k}
+	}
+	c.summary = c.newSummary(c.estimateCapacity())
+	return c
+}
+
+// insertCentroid inserts a centroid without merging it into the others.
+func (t *TDigest) insertCentroid(mean float64, count uint32, m2 float64) {
+	// the mean is not NaN and the count not 0, which insert would reject
+	_ = t.summary.insert(mean, count, m2)
+	t.accumulate(uint64(count), mean*float64(count), m2)
+	t.observe(mean, mean)
+}
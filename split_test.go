@@ -0,0 +1,75 @@
+package tdigest
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSplitAt(t *testing.T) {
+	lower, upper := New(100).SplitAt(0.5)
+	if lower.Count() != 0 || upper.Count() != 0 {
+		t.Errorf("Expected empty digests from an empty one")
+	}
+
+	rng := rand.New(rand.NewSource(0x5b1))
+	for name, opts := range optionVariants(map[string][]Option{
+		"bounded": {WithMaxCentroidBound(50)},
+	}) {
+		data := make([]float64, 100000)
+		tdigest := New(100, opts...)
+		for i := range data {
+			data[i] = rng.ExpFloat64()
+			assertNoError(t, tdigest.Add(data[i]))
+		}
+		sort.Float64s(data)
+
+		for _, q := range []float64{0, 0.05, 0.5, 0.95, 1} {
+			lower, upper := tdigest.SplitAt(q)
+			if lower.Count()+upper.Count() != tdigest.Count() {
+				t.Fatalf("%s: q=%v: got %d and %d samples, expected %d in total", name, q, lower.Count(), upper.Count(), tdigest.Count())
+			}
+			if lower.variance != tdigest.variance || lower.delta != tdigest.delta || upper.compression != tdigest.compression {
+				t.Errorf("%s: q=%v: expected the options of the digest", name, q)
+			}
+			for _, d := range []*TDigest{lower, upper} {
+				d.ForEachCentroid(func(mean float64, count uint32) bool {
+					if count == 0 || mean < d.Min() || mean > d.Max() {
+						t.Errorf("%s: q=%v: got a centroid of %d samples at %v, out of [%v, %v]", name, q, count, mean, d.Min(), d.Max())
+					}
+					return true
+				})
+			}
+
+			cut := int(lower.Count())
+			if q == 0 && cut != 0 || q == 1 && cut != len(data) {
+				t.Errorf("%s: q=%v: got %d samples below the cut", name, q, cut)
+			}
+			for _, part := range []struct {
+				digest *TDigest
+				data   []float64
+			}{
+				{lower, data[:cut]},
+				{upper, data[cut:]},
+			} {
+				if len(part.data) == 0 {
+					continue
+				}
+				if part.digest.Min() != part.data[0] && part.digest.Max() != part.data[len(part.data)-1] {
+					t.Errorf("%s: q=%v: expected one of the extremes to be exact", name, q)
+				}
+				// the error in rank within the part, as a fraction of the
+				// samples of the whole digest
+				for _, p := range []float64{0.1, 0.5, 0.9} {
+					err := rankError(p, part.digest.Quantile(p), part.data) * float64(len(part.data)) / float64(len(data))
+					if err > 0.005 {
+						t.Errorf("%s: q=%v: Quantile(%v) of a part is off by %v in rank", name, q, p, err)
+					}
+				}
+			}
+		}
+	}
+
+	shouldPanic(func() { New(100).SplitAt(-0.1) }, t, "SplitAt(-0.1) should panic")
+	shouldPanic(func() { New(100).SplitAt(1.1) }, t, "SplitAt(1.1) should panic")
+}
@@ -0,0 +1,209 @@
+package tdigest
+
+import "math"
+
+// Mean returns the mean of the samples added to the digest, or NaN if it
+// is empty. The sum of the samples is maintained as they are added, so this
+// takes constant time and is not affected by Compress or Merge.
+func (t *TDigest) Mean() float64 {
+	t.checkRead()
+
+	if t.count == 0 {
+		return math.NaN()
+	}
+	return t.sum / float64(t.count)
+}
+
+// Sum returns the sum of the samples added to the digest, weighted by their
+// counts. Like Mean, it is exact up to floating point rounding rather than
+// estimated from the centroids, and survives serialization.
+func (t *TDigest) Sum() float64 {
+	t.checkRead()
+	return t.sum
+}
+
+// Variance returns the population variance of the samples added to the
+// digest, or NaN if it is empty. Like Mean, it is maintained as samples are
+// added and merged, using the updates of Welford and Chan et al., rather
+// than estimated from the centroids.
+//
+// Samples removed with RemoveValue are assumed to sit at the mean of the
+// centroid they are taken from.
+func (t *TDigest) Variance() float64 {
+	t.checkRead()
+
+	if t.count == 0 {
+		return math.NaN()
+	}
+	return t.m2 / float64(t.count)
+}
+
+// StdDev returns the square root of Variance.
+func (t *TDigest) StdDev() float64 {
+	return math.Sqrt(t.Variance())
+}
+
+// Skewness returns the population skewness of the samples added to the
+// digest, their third central moment divided by the cube of StdDev, or NaN
+// if it has fewer than 3 samples or they are all equal. Like Variance, it
+// is maintained as samples are added and merged rather than estimated from
+// the centroids. Samples merged as centroids, such as by Rollup, count as
+// sitting at the mean of their centroid.
+func (t *TDigest) Skewness() float64 {
+	t.checkRead()
+
+	if t.count < 3 {
+		return math.NaN()
+	}
+	n := float64(t.count)
+	return math.Sqrt(n) * t.m3 / math.Pow(t.m2, 1.5)
+}
+
+// Kurtosis returns the excess kurtosis of the samples added to the digest,
+// their fourth central moment divided by the square of Variance minus 3,
+// which is 0 for normal data, or NaN if it has fewer than 4 samples or they
+// are all equal. It is maintained like Skewness.
+func (t *TDigest) Kurtosis() float64 {
+	t.checkRead()
+
+	if t.count < 4 {
+		return math.NaN()
+	}
+	n := float64(t.count)
+	return n*t.m4/(t.m2*t.m2) - 3
+}
+
+// moments holds the count of a set of samples, their sum and the sums of
+// their squared, cubed and fourth power deviations from their mean.
+type moments struct {
+	count      uint64
+	sum        float64
+	m2, m3, m4 float64
+}
+
+// moments returns the moments of the digest.
+func (t *TDigest) moments() moments {
+	return moments{count: t.count, sum: t.sum, m2: t.m2, m3: t.m3, m4: t.m4}
+}
+
+// setMoments replaces the moments of the digest.
+func (t *TDigest) setMoments(m moments) {
+	t.count, t.sum, t.m2, t.m3, t.m4 = m.count, m.sum, m.m2, m.m3, m.m4
+}
+
+// accumulate adds count samples with the given sum and sum of squared
+// deviations from their mean to the moments of the digest. Their higher
+// moments are taken to be 0, as they are for samples all equal to their
+// mean.
+func (t *TDigest) accumulate(count uint64, sum, m2 float64) {
+	t.combine(moments{count: count, sum: sum, m2: m2})
+}
+
+// momentsOf returns the moments of values, computed in two passes.
+func momentsOf(values []float64) (m moments) {
+	m.count = uint64(len(values))
+	for _, value := range values {
+		m.sum += value
+	}
+	mean := m.sum / float64(m.count)
+	for _, value := range values {
+		d := value - mean
+		d2 := d * d
+		m.m2 += d2
+		m.m3 += d2 * d
+		m.m4 += d2 * d2
+	}
+	return m
+}
+
+// combine adds the samples described by m to the moments of the digest,
+// with the updates of Chan et al. and Pébay.
+func (t *TDigest) combine(m moments) {
+	if m.count == 0 {
+		return
+	}
+	if t.count == 0 {
+		t.setMoments(m)
+		return
+	}
+
+	a, b := float64(t.count), float64(m.count)
+	n := a + b
+	d := m.sum/b - t.sum/a
+	m2 := t.m2 + m.m2 + d*d*a*b/n
+	m3 := t.m3 + m.m3 + d*d*d*a*b*(a-b)/(n*n) + 3*d*(a*m.m2-b*t.m2)/n
+	m4 := t.m4 + m.m4 + d*d*d*d*a*b*(a*a-a*b+b*b)/(n*n*n) +
+		6*d*d*(a*a*m.m2+b*b*t.m2)/(n*n) + 4*d*(a*m.m3-b*t.m3)/n
+	t.count += m.count
+	t.sum += m.sum
+	t.m2, t.m3, t.m4 = m2, m3, m4
+}
+
+// discard removes count samples with the given sum, all equal to their
+// mean, from the moments of the digest, undoing accumulate.
+func (t *TDigest) discard(count uint64, sum float64) {
+	t.remove(moments{count: count, sum: sum})
+}
+
+// remove removes the samples described by m from the moments of the
+// digest, undoing combine.
+func (t *TDigest) remove(m moments) {
+	if m.count == 0 {
+		return
+	}
+	if m.count >= t.count {
+		t.setMoments(moments{})
+		return
+	}
+
+	a, b := float64(t.count-m.count), float64(m.count)
+	n := a + b
+	d := m.sum/b - (t.sum-m.sum)/a
+	m2 := math.Max(0, t.m2-m.m2-d*d*a*b/n)
+	m3 := t.m3 - m.m3 - d*d*d*a*b*(a-b)/(n*n) - 3*d*(a*m.m2-b*m2)/n
+	m4 := t.m4 - m.m4 - d*d*d*d*a*b*(a*a-a*b+b*b)/(n*n*n) - 6*d*d*(a*a*m.m2+b*b*m2)/(n*n) - 4*d*(a*m.m3-b*m3)/n
+	t.count -= m.count
+	t.sum -= m.sum
+	t.m2, t.m3, t.m4 = m2, m3, math.Max(0, m4)
+}
+
+// TrimmedMean returns the mean of the samples between the quantiles lo and
+// hi, such as the mean of the samples between the 5th and 95th percentiles
+// with TrimmedMean(0.05, 0.95). The centroids straddling the boundaries
+// contribute the part of their weight that is inside them. It returns NaN
+// for an empty digest, and the quantile lo if lo and hi are equal.
+//
+// Values of lo and hi must be between 0 and 1 (inclusive) with lo <= hi,
+// will panic otherwise.
+func (t *TDigest) TrimmedMean(lo, hi float64) float64 {
+	if lo < 0 || hi > 1 || !(lo <= hi) {
+		panic("lo and hi must be between 0 and 1 (inclusive) with lo <= hi")
+	}
+	t.checkRead()
+
+	if t.summary.Len() == 0 {
+		return math.NaN()
+	}
+	if lo == hi {
+		return t.quantile(lo)
+	}
+
+	begin, end := lo*float64(t.count), hi*float64(t.count)
+	i := t.summary.SeekSum(begin)
+	cumSum := t.summary.HeadSum(i)
+	for i > 0 && cumSum > begin {
+		i--
+		cumSum -= float64(t.summary.Count(i))
+	}
+
+	var sum float64
+	for ; i < t.summary.Len() && cumSum < end; i++ {
+		c := float64(t.summary.Count(i))
+		inside := math.Min(end, cumSum+c) - math.Max(begin, cumSum)
+		if inside > 0 {
+			sum += inside * t.summary.Mean(i)
+		}
+		cumSum += c
+	}
+	return sum / (end - begin)
+}
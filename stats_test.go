@@ -0,0 +1,330 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func assertMean(t *testing.T, name string, d *TDigest, expected float64) {
+	t.Helper()
+	if got := d.Mean(); math.Abs(got-expected) > 1e-9*math.Max(1, math.Abs(expected)) {
+		t.Errorf("%s: got mean %v, expected %v", name, got, expected)
+	}
+}
+
+func TestMean(t *testing.T) {
+	tdigest := New(100)
+	if !math.IsNaN(tdigest.Mean()) {
+		t.Errorf("Expected a NaN mean for an empty digest")
+	}
+
+	var sum, count float64
+	for i := 0; i < 100000; i++ {
+		x := rand.ExpFloat64() * 10
+		w := uint32(rand.Intn(5) + 1)
+		sum += x * float64(w)
+		count += float64(w)
+		assertNoError(t, tdigest.AddWeighted(x, w))
+	}
+	assertMean(t, "AddWeighted", tdigest, sum/count)
+
+	assertNoError(t, tdigest.Compress())
+	assertMean(t, "Compress", tdigest, sum/count)
+
+	other := New(50)
+	var otherSum float64
+	for i := 0; i < 1000; i++ {
+		x := rand.NormFloat64() + 100
+		otherSum += x
+		assertNoError(t, other.Add(x))
+	}
+	assertNoError(t, tdigest.Merge(other))
+	assertMean(t, "Merge", tdigest, (sum+otherSum)/(count+1000))
+
+	assertNoError(t, tdigest.MergeDestructive(other))
+	assertMean(t, "MergeDestructive", tdigest, (sum+2*otherSum)/(count+2000))
+	if !math.IsNaN(other.Mean()) {
+		t.Errorf("Expected a NaN mean for the drained digest")
+	}
+}
+
+func TestSum(t *testing.T) {
+	tdigest := New(100)
+	if tdigest.Sum() != 0 {
+		t.Errorf("Expected a zero sum for an empty digest, got %v", tdigest.Sum())
+	}
+
+	var sum float64
+	for i := 0; i < 10000; i++ {
+		x := rand.NormFloat64()*1e6 + 0.1
+		sum += 3 * x
+		assertNoError(t, tdigest.AddWeighted(x, 3))
+	}
+	if math.Abs(tdigest.Sum()-sum) > 1e-9*math.Abs(sum) {
+		t.Errorf("got sum %v, expected %v", tdigest.Sum(), sum)
+	}
+
+	decoded, err := FromBytes(tdigest.Marshal(nil))
+	assertNoError(t, err)
+	if decoded.Sum() != tdigest.Sum() {
+		t.Errorf("FromBytes: got sum %v, expected %v", decoded.Sum(), tdigest.Sum())
+	}
+
+	// a digest of a few samples uses the tiny encoding
+	small := New(100)
+	for _, x := range []float64{0.1, 0.2, 1000} {
+		assertNoError(t, small.Add(x))
+	}
+	buf := small.Marshal(nil)
+	if buf[0]>>4 != tinyEncoding {
+		t.Fatalf("Expected the tiny encoding")
+	}
+	decoded, err = FromBytes(buf)
+	assertNoError(t, err)
+	if decoded.Sum() != small.Sum() {
+		t.Errorf("tiny: got sum %v, expected %v", decoded.Sum(), small.Sum())
+	}
+	if decoded.Variance() != small.Variance() {
+		t.Errorf("tiny: got variance %v, expected %v", decoded.Variance(), small.Variance())
+	}
+
+	other := New(100)
+	assertNoError(t, other.Add(-1))
+	assertNoError(t, small.Merge(other))
+	if small.Sum() != 0.1+0.2+1000-1 {
+		t.Errorf("Merge: got sum %v, expected %v", small.Sum(), 0.1+0.2+1000-1)
+	}
+}
+
+func twoPassVariance(data []float64) float64 {
+	var mean float64
+	for _, x := range data {
+		mean += x
+	}
+	mean /= float64(len(data))
+
+	var m2 float64
+	for _, x := range data {
+		m2 += (x - mean) * (x - mean)
+	}
+	return m2 / float64(len(data))
+}
+
+func TestVariance(t *testing.T) {
+	empty := New(100)
+	if !math.IsNaN(empty.Variance()) || !math.IsNaN(empty.StdDev()) {
+		t.Errorf("Expected NaN for an empty digest")
+	}
+
+	single := New(100)
+	assertNoError(t, single.Add(42))
+	if single.Variance() != 0 || single.StdDev() != 0 {
+		t.Errorf("Expected 0 for a single sample, got %v", single.Variance())
+	}
+
+	for name, gen := range map[string]func() float64{
+		"uniform":   rand.Float64,
+		"lognormal": func() float64 { return math.Exp(2 * rand.NormFloat64()) },
+	} {
+		data := make([]float64, 100000)
+		for i := range data {
+			data[i] = gen() + 1e6
+		}
+
+		tdigest := New(100)
+		other := New(100)
+		for i, x := range data {
+			if i%3 == 0 {
+				assertNoError(t, other.Add(x))
+			} else {
+				assertNoError(t, tdigest.Add(x))
+			}
+		}
+		assertNoError(t, tdigest.Merge(other))
+		assertNoError(t, tdigest.Compress())
+
+		expected := twoPassVariance(data)
+		check := func(stage string, got float64) {
+			if math.Abs(got-expected) > 1e-6*expected {
+				t.Errorf("%s %s: got variance %v, expected %v", name, stage, got, expected)
+			}
+		}
+		check("Merge", tdigest.Variance())
+
+		decoded, err := FromBytes(tdigest.Marshal(nil))
+		assertNoError(t, err)
+		if decoded.Variance() != tdigest.Variance() {
+			t.Errorf("%s FromBytes: got variance %v, expected %v", name, decoded.Variance(), tdigest.Variance())
+		}
+
+		drained := New(100)
+		assertNoError(t, drained.MergeDestructive(tdigest))
+		check("MergeDestructive", drained.Variance())
+		if math.Abs(drained.StdDev()-math.Sqrt(expected)) > 1e-6*math.Sqrt(expected) {
+			t.Errorf("%s: got standard deviation %v, expected %v", name, drained.StdDev(), math.Sqrt(expected))
+		}
+	}
+}
+
+// twoPassShape returns the population skewness and excess kurtosis of data,
+// as computed by scipy.stats.skew and scipy.stats.kurtosis.
+func twoPassShape(data []float64) (skewness, kurtosis float64) {
+	var mean float64
+	for _, x := range data {
+		mean += x
+	}
+	mean /= float64(len(data))
+
+	var m2, m3, m4 float64
+	for _, x := range data {
+		d := x - mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	n := float64(len(data))
+	m2, m3, m4 = m2/n, m3/n, m4/n
+	return m3 / math.Pow(m2, 1.5), m4/(m2*m2) - 3
+}
+
+func TestSkewnessKurtosis(t *testing.T) {
+	tdigest := New(100)
+	for i, x := range []float64{1, 2, 4, 8} {
+		if i < 3 && !math.IsNaN(tdigest.Skewness()) || !math.IsNaN(tdigest.Kurtosis()) {
+			t.Errorf("Expected NaN for %d samples, got %v and %v", i, tdigest.Skewness(), tdigest.Kurtosis())
+		}
+		assertNoError(t, tdigest.Add(x))
+	}
+	if math.IsNaN(tdigest.Skewness()) || math.IsNaN(tdigest.Kurtosis()) {
+		t.Errorf("Expected estimates for 4 samples, got %v and %v", tdigest.Skewness(), tdigest.Kurtosis())
+	}
+	equal := New(100)
+	assertNoError(t, equal.AddWeighted(3, 10))
+	if !math.IsNaN(equal.Skewness()) || !math.IsNaN(equal.Kurtosis()) {
+		t.Errorf("Expected NaN for equal samples, got %v and %v", equal.Skewness(), equal.Kurtosis())
+	}
+
+	rng := rand.New(rand.NewSource(0x5ce3))
+	for name, test := range map[string]struct {
+		gen                func() float64
+		skewness, kurtosis float64
+	}{
+		"normal":      {rng.NormFloat64, 0, 0},
+		"exponential": {rng.ExpFloat64, 2, 6},
+	} {
+		data := make([]float64, 100000)
+		parts := []*TDigest{New(100), New(100), New(100)}
+		for i := range data {
+			data[i] = test.gen() + 1000
+			assertNoError(t, parts[i%3].Add(data[i]))
+		}
+
+		skewness, kurtosis := twoPassShape(data)
+		if math.Abs(skewness-test.skewness) > 0.1 || math.Abs(kurtosis-test.kurtosis) > 0.5 {
+			t.Fatalf("%s: got a sample skewness of %v and kurtosis of %v", name, skewness, kurtosis)
+		}
+		check := func(stage string, d *TDigest) {
+			if math.Abs(d.Skewness()-skewness) > 1e-6 || math.Abs(d.Kurtosis()-kurtosis) > 1e-6 {
+				t.Errorf("%s %s: got skewness %v and kurtosis %v, expected %v and %v",
+					name, stage, d.Skewness(), d.Kurtosis(), skewness, kurtosis)
+			}
+		}
+
+		assertNoError(t, parts[0].Merge(parts[1]))
+		assertNoError(t, parts[0].MergeDestructive(parts[2]))
+		check("Merge", parts[0])
+		assertNoError(t, parts[0].Compress())
+		check("Compress", parts[0])
+
+		decoded, err := FromBytes(parts[0].Marshal(nil))
+		assertNoError(t, err)
+		check("FromBytes", decoded)
+
+		// removing samples added on top undoes them
+		for _, x := range []float64{950, 1050, 1100} {
+			assertNoError(t, decoded.Add(x))
+		}
+		for _, x := range []float64{950, 1050, 1100} {
+			if n, err := decoded.RemoveValueWithin(x, 0, 1); n != 1 || err != nil {
+				t.Fatalf("%s: removed %d samples of %v: %v", name, n, x, err)
+			}
+		}
+		check("RemoveValue", decoded)
+	}
+}
+
+func TestDeserializationInvalidMoments(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, tdigest.Add(rand.ExpFloat64()))
+	}
+	buf := tdigest.Marshal(nil)
+
+	// the higher moments are the last section
+	if binary.BigEndian.Uint64(buf[len(buf)-8:]) != math.Float64bits(tdigest.m4) {
+		t.Fatalf("Expected the serialization to end with the higher moments")
+	}
+	for _, moments := range [][2]float64{
+		{math.NaN(), 1}, {math.Inf(1), 1}, {math.Inf(-1), 1}, {0, -1}, {0, math.NaN()},
+	} {
+		corrupt := append([]byte(nil), buf...)
+		binary.BigEndian.PutUint64(corrupt[len(corrupt)-16:], math.Float64bits(moments[0]))
+		binary.BigEndian.PutUint64(corrupt[len(corrupt)-8:], math.Float64bits(moments[1]))
+		if _, err := FromBytes(corrupt); err == nil {
+			t.Errorf("Expected higher moments of %v to be rejected", moments)
+		}
+	}
+}
+
+func TestTrimmedMean(t *testing.T) {
+	if !math.IsNaN(New(100).TrimmedMean(0.05, 0.95)) {
+		t.Errorf("Expected NaN for an empty digest")
+	}
+
+	for name, gen := range map[string]func() float64{
+		"uniform":   rand.Float64,
+		"lognormal": func() float64 { return math.Exp(rand.NormFloat64()) },
+	} {
+		data := make([]float64, 100000)
+		tdigest := New(100)
+		for i := range data {
+			data[i] = gen()
+			assertNoError(t, tdigest.Add(data[i]))
+		}
+		sort.Float64s(data)
+
+		for _, bounds := range [][2]float64{{0, 1}, {0.05, 0.95}, {0.25, 0.75}, {0.9, 0.99}, {0, 0.01}} {
+			lo, hi := bounds[0], bounds[1]
+			var expected float64
+			window := data[int(lo*float64(len(data))):int(hi*float64(len(data)))]
+			for _, x := range window {
+				expected += x
+			}
+			expected /= float64(len(window))
+
+			if got := tdigest.TrimmedMean(lo, hi); math.Abs(got-expected) > 0.005*expected {
+				t.Errorf("%s [%v, %v]: got %v, expected %v", name, lo, hi, got, expected)
+			}
+		}
+	}
+
+	tdigest := New(100)
+	assertNoError(t, tdigest.Add(1))
+	assertNoError(t, tdigest.Add(3))
+	if got := tdigest.TrimmedMean(0.5, 0.5); got != tdigest.Quantile(0.5) {
+		t.Errorf("Expected the median for an empty window, got %v", got)
+	}
+	for _, bounds := range [][2]float64{{-0.1, 0.5}, {0.5, 1.1}, {0.6, 0.4}, {math.NaN(), 0.5}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for %v", bounds)
+				}
+			}()
+			tdigest.TrimmedMean(bounds[0], bounds[1])
+		}()
+	}
+}
@@ -0,0 +1,38 @@
+package tdigest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String returns a one line summary of the digest: its count, number of
+// centroids and compression, and for a non-empty digest its extremes and a
+// few quantiles.
+func (t *TDigest) String() string {
+	t.checkRead()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "tdigest(count=%d centroids=%d compression=%g", t.count, t.summary.Len(), t.compression)
+	if t.count > 0 {
+		fmt.Fprintf(&b, " min=%g max=%g", t.min, t.max)
+		for _, q := range []float64{0.5, 0.9, 0.99} {
+			fmt.Fprintf(&b, " p%g=%g", 100*q, t.quantile(q))
+		}
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// DebugString returns String followed by a line for every centroid, in
+// increasing order of mean, with its index, mean, count and the total count
+// of the centroids up to and including it. The means are printed with as
+// many digits as needed to parse them back exactly.
+func (t *TDigest) DebugString() string {
+	var b strings.Builder
+	b.WriteString(t.String())
+	t.ForEachCentroidCumulative(func(index int, mean float64, count uint32, cumulative uint64) bool {
+		fmt.Fprintf(&b, "\n%d mean=%v count=%d cumulative=%d", index, mean, count, cumulative)
+		return true
+	})
+	return b.String()
+}
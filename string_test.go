@@ -0,0 +1,56 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tdigest := New(100)
+	if got, expected := tdigest.String(), "tdigest(count=0 centroids=0 compression=100)"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	if got, expected := tdigest.DebugString(), tdigest.String(); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+
+	assertNoError(t, tdigest.Add(1))
+	assertNoError(t, tdigest.AddWeighted(2, 2))
+	assertNoError(t, tdigest.Add(3.5))
+	assertNoError(t, tdigest.AddWeighted(0.1, 3))
+
+	expected := "tdigest(count=7 centroids=4 compression=100 min=0.1 max=3.5 p50=" +
+		fmt.Sprint(tdigest.Quantile(0.5)) + " p90=" + fmt.Sprint(tdigest.Quantile(0.9)) +
+		" p99=" + fmt.Sprint(tdigest.Quantile(0.99)) + ")"
+	if got := tdigest.String(); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	if got := fmt.Sprint(tdigest); got != expected {
+		t.Errorf("fmt: got %q, expected %q", got, expected)
+	}
+
+	expected += "\n0 mean=0.1 count=3 cumulative=3" +
+		"\n1 mean=1 count=1 cumulative=4" +
+		"\n2 mean=2 count=2 cumulative=6" +
+		"\n3 mean=3.5 count=1 cumulative=7"
+	if got := tdigest.DebugString(); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+
+	// the means parse back exactly
+	odd := New(100)
+	assertNoError(t, odd.Add(math.Pi))
+	lines := strings.Split(odd.DebugString(), "\n")
+	var index int
+	var mean float64
+	var count uint32
+	var cumulative uint64
+	if _, err := fmt.Sscanf(lines[1], "%d mean=%g count=%d cumulative=%d", &index, &mean, &count, &cumulative); err != nil {
+		t.Fatal(err)
+	}
+	if index != 0 || mean != math.Pi || count != 1 || cumulative != 1 {
+		t.Errorf("parsed %d %v %d %d from %q", index, mean, count, cumulative, lines[1])
+	}
+}
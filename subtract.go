@@ -0,0 +1,111 @@
+package tdigest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Subtract removes the samples of other from the digest, undoing an earlier
+// Merge of other, as when expiring the oldest digest of a rolling
+// aggregate. The weight of every centroid of other is taken from the
+// centroids of the digest with the closest means, which keep their means,
+// and the centroids left without samples are deleted. Count and the mean,
+// variance and other moments of the samples are updated exactly, while the
+// extremes are moved in to the means of the centroids left when other holds
+// them.
+//
+// The result is approximate: the centroids of the digest mix the samples of
+// other with those merged with them, which cannot be told apart again, so
+// the estimates drift a little further from those of a digest that never
+// held other with every subtraction. Rebuilding the aggregate from time to
+// time keeps the error in check.
+//
+// It returns an error if other is nil or holds more samples than the digest,
+// which is left untouched.
+func (t *TDigest) Subtract(other *TDigest) error {
+	if other == nil {
+		return errors.New("cannot subtract a nil digest")
+	}
+	if other != t {
+		other.checkRead()
+	}
+	t.checkRead()
+	if other.count > t.count {
+		return fmt.Errorf("cannot subtract %d samples from a digest of %d", other.count, t.count)
+	}
+
+	t.beginWrite()
+	t.subtract(other)
+	t.endWrite()
+	t.checkThresholds()
+	return nil
+}
+
+func (t *TDigest) subtract(other *TDigest) {
+	s := t.summary
+	// other may be t itself
+	means := append([]float64(nil), other.summary.means...)
+	counts := append([]uint32(nil), other.summary.counts...)
+	m := other.moments()
+	lo, hi := other.min, other.max
+
+	for i, mean := range means {
+		// take the samples from the closest centroids with samples left,
+		// left of mean from l down and right of it from r up
+		remaining := uint64(counts[i])
+		l := s.Floor(mean)
+		r := l + 1
+		for remaining > 0 {
+			for l >= 0 && s.counts[l] == 0 {
+				l--
+			}
+			for r < s.Len() && s.counts[r] == 0 {
+				r++
+			}
+
+			j := r
+			switch {
+			case l < 0 && r == s.Len():
+				remaining = 0
+				continue
+			case r == s.Len() || (l >= 0 && mean-s.means[l] <= s.means[r]-mean):
+				j = l
+			}
+
+			take := uint64(s.counts[j])
+			if take > remaining {
+				take = remaining
+			}
+			if s.m2 != nil {
+				s.m2[j] *= float64(uint64(s.counts[j])-take) / float64(s.counts[j])
+			}
+			s.counts[j] -= uint32(take)
+			remaining -= take
+		}
+	}
+
+	// drop the emptied centroids and update the tree for the new counts
+	w := 0
+	for i := 0; i < s.Len(); i++ {
+		if s.counts[i] > 0 {
+			s.move(w, i)
+			w++
+		}
+	}
+	s.cut(w, s.Len())
+	t.sweeping = false
+
+	if s.Len() == 0 {
+		t.setMoments(moments{})
+		t.min, t.max = math.Inf(1), math.Inf(-1)
+		return
+	}
+	t.remove(m)
+	if lo <= t.min {
+		t.min = math.Max(t.min, s.Mean(0))
+	}
+	if hi >= t.max {
+		t.max = math.Min(t.max, s.Mean(s.Len()-1))
+	}
+}
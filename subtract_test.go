@@ -0,0 +1,100 @@
+package tdigest
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSubtract(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5b7))
+	for name, opts := range optionVariants(nil) {
+		for shift, gen := range map[string]func() float64{
+			"overlapping": func() float64 { return 0.5 + rng.NormFloat64() },
+			"disjoint":    func() float64 { return 10 + rng.ExpFloat64() },
+		} {
+			a, b := New(100, opts...), New(100, opts...)
+			samples := make([]float64, 50000)
+			for i := range samples {
+				samples[i] = rng.NormFloat64()
+				assertNoError(t, a.Add(samples[i]))
+				assertNoError(t, b.Add(gen()))
+			}
+			sort.Float64s(samples)
+
+			aggregate := New(100, opts...)
+			assertNoError(t, aggregate.Merge(a))
+			assertNoError(t, aggregate.Merge(b))
+			assertNoError(t, aggregate.Subtract(b))
+
+			if aggregate.Count() != a.Count() {
+				t.Errorf("%s %s: got %d samples, expected %d", name, shift, aggregate.Count(), a.Count())
+			}
+			for _, c := range [][2]float64{
+				{aggregate.Mean(), a.Mean()},
+				{aggregate.Variance(), a.Variance()},
+				{aggregate.Skewness(), a.Skewness()},
+				{aggregate.Kurtosis(), a.Kurtosis()},
+			} {
+				if math.Abs(c[0]-c[1]) > 1e-6 {
+					t.Errorf("%s %s: got moment %v, expected %v", name, shift, c[0], c[1])
+				}
+			}
+			if aggregate.Min() < a.Min() || aggregate.Max() > 10 {
+				t.Errorf("%s %s: got extremes %v and %v, expected within %v and %v", name, shift,
+					aggregate.Min(), aggregate.Max(), a.Min(), a.Max())
+			}
+
+			for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+				x := aggregate.Quantile(q)
+				rank := float64(sort.SearchFloat64s(samples, x)) / float64(len(samples))
+				if math.Abs(rank-q) > 0.005 {
+					t.Errorf("%s %s: Quantile(%v) = %v has rank %v", name, shift, q, x, rank)
+				}
+			}
+		}
+	}
+}
+
+func TestSubtractLimits(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 100; i++ {
+		assertNoError(t, tdigest.Add(float64(i)))
+	}
+	larger := New(100)
+	for i := 0; i < 101; i++ {
+		assertNoError(t, larger.Add(float64(i)))
+	}
+
+	before := tdigest.Marshal(nil)
+	if err := tdigest.Subtract(larger); err == nil {
+		t.Errorf("Expected an error subtracting a larger digest")
+	}
+	if err := tdigest.Subtract(nil); err == nil {
+		t.Errorf("Expected an error subtracting a nil digest")
+	}
+	if after := tdigest.Marshal(nil); !bytes.Equal(before, after) {
+		t.Errorf("A failed subtraction changed the digest")
+	}
+
+	assertNoError(t, tdigest.Subtract(New(100)))
+	if after := tdigest.Marshal(nil); !bytes.Equal(before, after) {
+		t.Errorf("Subtracting an empty digest changed the digest")
+	}
+
+	top := New(100)
+	for i := 90; i < 100; i++ {
+		assertNoError(t, top.Add(float64(i)))
+	}
+	assertNoError(t, tdigest.Subtract(top))
+	if tdigest.Count() != 90 || tdigest.Max() != 89 || tdigest.Min() != 0 {
+		t.Errorf("Expected 90 samples in [0, 89], got %d in [%v, %v]", tdigest.Count(), tdigest.Min(), tdigest.Max())
+	}
+
+	assertNoError(t, tdigest.Subtract(tdigest))
+	if tdigest.Count() != 0 || tdigest.summary.Len() != 0 || !math.IsNaN(tdigest.Quantile(0.5)) {
+		t.Errorf("Expected subtracting a digest from itself to empty it, got %d samples", tdigest.Count())
+	}
+}
@@ -10,13 +10,28 @@ type summary struct {
 	means  []float64
 	counts []uint32
 	bitree fen
+
+	// base is the position of the first centroid in bitree. Inserting in
+	// the front half of the summary moves base down rather than moving
+	// every count after the insertion point, so that inserting in order at
+	// either end only touches O(log n) entries of the tree.
+	base int
+
+	// m2 holds the sum of the squared deviations of the samples of every
+	// centroid from its mean when the digest tracks centroid variances, and
+	// is nil otherwise.
+	m2 []float64
+
+	// limit bounds the capacity of the buffers when the digest was created
+	// with WithSmallFootprint, and is 0 otherwise.
+	limit int
 }
 
 func newSummary(initialCapacity uint) *summary {
 	s := &summary{
 		means:  make([]float64, 0, initialCapacity),
 		counts: make([]uint32, 0, initialCapacity),
-		bitree: fen{buf: make([]uint32, initialCapacity)},
+		bitree: fen{buf: make([]uint64, initialCapacity)},
 	}
 	return s
 }
@@ -26,6 +41,12 @@ func (s summary) Len() int {
 }
 
 func (s *summary) Add(key float64, value uint32) error {
+	return s.insert(key, value, 0)
+}
+
+// insert adds a centroid with the given sum of squared deviations, which is
+// ignored unless the summary tracks them.
+func (s *summary) insert(key float64, value uint32, m2 float64) error {
 	if math.IsNaN(key) {
 		return fmt.Errorf("Key must not be NaN")
 	}
@@ -36,27 +57,88 @@ func (s *summary) Add(key float64, value uint32) error {
 
 	idx := s.FindInsertionIndex(key)
 
+	if s.limit > 0 && len(s.means) == cap(s.means) {
+		s.grow()
+	}
+
 	s.means = append(s.means, 0)
 	copy(s.means[idx+1:], s.means[idx:])
 
 	s.counts = append(s.counts, 0)
 	copy(s.counts[idx+1:], s.counts[idx:])
 
-	// man i wish there was a better way to handle an insertion, but Add
-	// appears to happen far less than UpdateAt, and when Add does happen,
-	// the size of keycounts is small. There may be some optimization here
-	// by doing deltas, though.
-	for i := idx + 1; i < len(s.means); i++ {
-		s.bitree.Set(i, s.counts[i])
-	}
-
 	s.means[idx] = key
 	s.counts[idx] = value
-	s.bitree.Set(idx, value)
+
+	if s.m2 != nil {
+		s.m2 = append(s.m2, 0)
+		copy(s.m2[idx+1:], s.m2[idx:])
+		s.m2[idx] = m2
+	}
+
+	// the counts on the shorter side of idx move by one position in the
+	// tree. updating them one by one costs O(log n) each, so past a small
+	// fraction of the summary it is cheaper to rebuild the tree in O(n).
+	n := len(s.means)
+	if idx < n/2 {
+		if s.base == 0 || (idx+1)*8 > n {
+			// bounded buffers leave no room in front of the counts, which
+			// makes inserting at the front O(n) for a small n
+			room := n
+			if s.limit > 0 {
+				room = 0
+			}
+			s.rebuildTree(room)
+			return nil
+		}
+		s.base--
+		for i := 0; i <= idx; i++ {
+			s.bitree.Set(s.base+i, uint64(s.counts[i]))
+		}
+	} else {
+		if (n-idx)*8 > n {
+			s.rebuildTree(s.base)
+			return nil
+		}
+		for i := idx; i < n; i++ {
+			s.bitree.Set(s.base+i, uint64(s.counts[i]))
+		}
+	}
 
 	return nil
 }
 
+// grow makes room for one more centroid, doubling the capacity of the
+// buffers up to limit instead of letting append choose it.
+func (s *summary) grow() {
+	size := 2 * cap(s.means)
+	if size < 8 {
+		size = 8
+	}
+	if size > s.limit {
+		size = s.limit
+	}
+	if size <= len(s.means) {
+		size = len(s.means) + 1
+	}
+
+	s.means = append(make([]float64, 0, size), s.means...)
+	s.counts = append(make([]uint32, 0, size), s.counts...)
+	if len(s.bitree.buf) < size {
+		s.bitree.buf = append(make([]uint64, 0, size), s.bitree.buf...)
+	}
+	if s.m2 != nil {
+		s.m2 = append(make([]float64, 0, size), s.m2...)
+	}
+}
+
+// rebuildTree recomputes the tree from the counts, leaving base free
+// positions in front of them.
+func (s *summary) rebuildTree(base int) {
+	s.base = base
+	s.bitree.Rebuild(base, s.counts)
+}
+
 func (s summary) Floor(x float64) int {
 	i, j := 0, len(s.means)
 	for i < j {
@@ -78,7 +160,12 @@ func (s summary) FindInsertionIndex(x float64) int {
 }
 
 func (s summary) HeadSum(index int) (sum float64) {
-	return float64(s.bitree.Sum(index))
+	return float64(s.bitree.Sum(s.base + index))
+}
+
+// RangeSum returns the total weight of the centroids in [i, j).
+func (s summary) RangeSum(i, j int) float64 {
+	return float64(s.bitree.Range(s.base+i, s.base+j))
 }
 
 func (s summary) FindIndex(x float64) int {
@@ -99,6 +186,15 @@ func (s summary) Count(uncheckedIndex int) uint32 {
 	return s.counts[uncheckedIndex]
 }
 
+// M2 returns the sum of squared deviations of the centroid, or 0 if the
+// summary does not track them.
+func (s summary) M2(uncheckedIndex int) float64 {
+	if s.m2 == nil {
+		return 0
+	}
+	return s.m2[uncheckedIndex]
+}
+
 // return the index of the last item which the sum of counts
 // of items before it is less than or equal to `sum`. -1 in
 // case no centroid satisfies the requirement.
@@ -120,26 +216,58 @@ func (s summary) FloorSum(sum float64) (index int, cumSum float64) {
 	return index, cumSum
 }
 
+// SeekSum is like FloorSum but only returns the index, in O(log n) using
+// the tree. It returns -1 for an empty summary.
+func (s summary) SeekSum(sum float64) int {
+	if sum < 0 || s.Len() == 0 {
+		return -1
+	}
+
+	target := uint64(math.MaxUint64)
+	if sum < math.MaxUint64 {
+		target = uint64(sum)
+	}
+	index := s.bitree.Search(target) - s.base
+	if index >= s.Len() {
+		index = s.Len() - 1
+	}
+	return index
+}
+
 func (s *summary) setAt(index int, mean float64, count uint32) {
 	s.means[index] = mean
 	s.counts[index] = count
-	s.adjustRight(index)
-	s.adjustLeft(index)
-	s.bitree.Set(index, count)
+
+	// keep the means sorted, updating the tree for every count that moved
+	lo, hi := index, index
+	if right := s.adjustRight(index); right > hi {
+		hi = right
+	} else if left := s.adjustLeft(index); left < lo {
+		lo = left
+	}
+	for i := lo; i <= hi; i++ {
+		s.bitree.Set(s.base+i, uint64(s.counts[i]))
+	}
 }
 
-func (s *summary) adjustRight(index int) {
-	for i := index + 1; i < len(s.means) && s.means[i-1] > s.means[i]; i++ {
-		s.means[i-1], s.means[i] = s.means[i], s.means[i-1]
-		s.counts[i-1], s.counts[i] = s.counts[i], s.counts[i-1]
+// adjustRight moves the centroid at index to the right until the means are
+// sorted, returning its new index.
+func (s *summary) adjustRight(index int) int {
+	i := index + 1
+	for ; i < len(s.means) && s.means[i-1] > s.means[i]; i++ {
+		s.Swap(i-1, i)
 	}
+	return i - 1
 }
 
-func (s *summary) adjustLeft(index int) {
-	for i := index - 1; i >= 0 && s.means[i] > s.means[i+1]; i-- {
-		s.means[i], s.means[i+1] = s.means[i+1], s.means[i]
-		s.counts[i], s.counts[i+1] = s.counts[i+1], s.counts[i]
+// adjustLeft moves the centroid at index to the left until the means are
+// sorted, returning its new index.
+func (s *summary) adjustLeft(index int) int {
+	i := index - 1
+	for ; i >= 0 && s.means[i] > s.means[i+1]; i-- {
+		s.Swap(i, i+1)
 	}
+	return i + 1
 }
 
 func (s summary) ForEach(f func(float64, uint32) bool) {
@@ -151,9 +279,138 @@ func (s summary) ForEach(f func(float64, uint32) bool) {
 }
 
 func (s summary) Clone() *summary {
-	return &summary{
+	c := &summary{
 		means:  append([]float64{}, s.means...),
 		counts: append([]uint32{}, s.counts...),
 		bitree: s.bitree.Clone(),
+		base:   s.base,
+		limit:  s.limit,
+	}
+	if s.m2 != nil {
+		c.m2 = append([]float64{}, s.m2...)
+	}
+	return c
+}
+
+// Less and Swap make summary a sort.Interface ordering centroids by mean.
+func (s summary) Less(i, j int) bool {
+	return s.means[i] < s.means[j]
+}
+
+func (s summary) Swap(i, j int) {
+	s.means[i], s.means[j] = s.means[j], s.means[i]
+	s.counts[i], s.counts[j] = s.counts[j], s.counts[i]
+	if s.m2 != nil {
+		s.m2[i], s.m2[j] = s.m2[j], s.m2[i]
+	}
+}
+
+// absorb merges the centroid at i into the one at j, leaving the tree as is.
+func (s *summary) absorb(j, i int) {
+	cj, ci := float64(s.counts[j]), float64(s.counts[i])
+	if s.m2 != nil {
+		d := s.means[i] - s.means[j]
+		s.m2[j] += s.m2[i] + d*d*cj*ci/(cj+ci)
+	}
+	s.means[j] = weightedAverage(s.means[j], cj, s.means[i], ci)
+	s.counts[j] += s.counts[i]
+}
+
+// move copies the centroid at i over the one at j, leaving the tree as is.
+func (s *summary) move(j, i int) {
+	s.means[j], s.counts[j] = s.means[i], s.counts[i]
+	if s.m2 != nil {
+		s.m2[j] = s.m2[i]
+	}
+}
+
+// cut removes the centroids in [lo, hi) and rebuilds the tree.
+func (s *summary) cut(lo, hi int) {
+	s.means = append(s.means[:lo], s.means[hi:]...)
+	s.counts = append(s.counts[:lo], s.counts[hi:]...)
+	if s.m2 != nil {
+		s.m2 = append(s.m2[:lo], s.m2[hi:]...)
+	}
+	s.rebuildTree(s.base)
+}
+
+// reset removes every centroid, keeping the allocated buffers.
+func (s *summary) reset() {
+	s.means = s.means[:0]
+	s.counts = s.counts[:0]
+	if s.m2 != nil {
+		s.m2 = s.m2[:0]
+	}
+	s.rebuildTree(0)
+}
+
+// combineSummaries returns a summary holding the centroids of all the given
+// summaries, sorted by mean. It tracks variances if all of them do. The
+// summaries are already sorted, so rather than sorting their centroids
+// again they are merged in pairs, then the results in pairs and so on,
+// keeping ties in the order of the summaries.
+func combineSummaries(summaries []*summary) *summary {
+	var size int
+	variance := true
+	for _, s := range summaries {
+		size += s.Len()
+		variance = variance && s.m2 != nil
+	}
+
+	combined := newSummary(uint(size))
+	if variance {
+		combined.m2 = make([]float64, 0, size)
+	}
+	// ends holds the end of every sorted run of the combined centroids
+	ends := make([]int, 0, len(summaries))
+	for _, s := range summaries {
+		combined.means = append(combined.means, s.means...)
+		combined.counts = append(combined.counts, s.counts...)
+		if variance {
+			combined.m2 = append(combined.m2, s.m2...)
+		}
+		ends = append(ends, combined.Len())
+	}
+
+	scratch := &summary{means: make([]float64, size), counts: make([]uint32, size)}
+	if variance {
+		scratch.m2 = make([]float64, size)
+	}
+	for len(ends) > 1 {
+		merged, lo := ends[:0], 0
+		for i := 0; i < len(ends); i += 2 {
+			mid, hi := ends[i], ends[i]
+			if i+1 < len(ends) {
+				hi = ends[i+1]
+			}
+			mergeRuns(scratch, combined, lo, mid, hi)
+			merged = append(merged, hi)
+			lo = hi
+		}
+		ends = merged
+		combined.means, scratch.means = scratch.means, combined.means
+		combined.counts, scratch.counts = scratch.counts, combined.counts
+		combined.m2, scratch.m2 = scratch.m2, combined.m2
+	}
+	combined.rebuildTree(0)
+
+	return combined
+}
+
+// mergeRuns merges the sorted runs [lo, mid) and [mid, hi) of src into the
+// same positions of dst, taking the centroids of the first run on ties.
+func mergeRuns(dst, src *summary, lo, mid, hi int) {
+	i, j := lo, mid
+	for k := lo; k < hi; k++ {
+		from := i
+		if i == mid || (j < hi && src.means[j] < src.means[i]) {
+			from, j = j, j+1
+		} else {
+			i++
+		}
+		dst.means[k], dst.counts[k] = src.means[from], src.counts[from]
+		if src.m2 != nil {
+			dst.m2[k] = src.m2[from]
+		}
 	}
 }
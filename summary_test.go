@@ -191,3 +191,52 @@ func TestAdjustLeftRight(t *testing.T) {
 		t.Errorf("adjustLeft should have fixed the keys/counts state. %v %v", s.means, s.counts)
 	}
 }
+
+func assertHeadSums(s *summary, t *testing.T) {
+	t.Helper()
+
+	var sum float64
+	for i := 0; i < s.Len(); i++ {
+		if got := s.HeadSum(i); got != sum {
+			t.Fatalf("HeadSum(%d) = %.0f, expected %.0f", i, got, sum)
+		}
+		sum += float64(s.counts[i])
+	}
+}
+
+func TestInsertionKeepsTreeConsistent(t *testing.T) {
+	orders := map[string]func(i int) float64{
+		"ascending":  func(i int) float64 { return float64(i) },
+		"descending": func(i int) float64 { return float64(-i) },
+		"random":     func(i int) float64 { return rand.Float64() },
+		"zigzag":     func(i int) float64 { return float64(i%2*2-1) * float64(i) },
+	}
+
+	for _, key := range orders {
+		s := newSummary(4)
+		for i := 0; i < 1000; i++ {
+			_ = s.Add(key(i), uint32(rand.Intn(10)+1))
+			if i%97 == 0 {
+				assertHeadSums(s, t)
+			}
+		}
+		checkSorted(s, t)
+		assertHeadSums(s, t)
+	}
+}
+
+func TestSetAtKeepsTreeConsistent(t *testing.T) {
+	s := newSummary(10)
+	for i := 0; i < 10; i++ {
+		_ = s.Add(float64(i), uint32(i+1))
+	}
+
+	// moving centroids past their neighbors moves their counts too
+	s.setAt(2, 7.5, 20)
+	checkSorted(s, t)
+	assertHeadSums(s, t)
+
+	s.setAt(8, 0.5, 30)
+	checkSorted(s, t)
+	assertHeadSums(s, t)
+}
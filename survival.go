@@ -0,0 +1,61 @@
+package tdigest
+
+import "math"
+
+// SF returns the survival function at x, the fraction of the samples
+// greater than x, which is 1-CDF(x) with the same interpolation. It sums the
+// weight of the centroids above x rather than subtracting from 1, so small
+// tail fractions such as 1e-7 keep their full precision where 1-CDF(x)
+// would round them.
+//
+// SF(-Inf) is 1 and SF(+Inf) is 0, even for an empty digest, and SF(NaN) is
+// NaN. Otherwise it returns NaN when the digest is empty, or when it was
+// created with WithMinSamples and has too few samples on either side of x.
+func (t *TDigest) SF(x float64) float64 {
+	t.checkRead()
+
+	var sf float64
+	switch {
+	case math.IsNaN(x):
+		return math.NaN()
+	case math.IsInf(x, -1):
+		return 1
+	case math.IsInf(x, 1):
+		return 0
+	case t.summary.Len() == 0:
+		return math.NaN()
+	case t.summary.m2 != nil:
+		sf = t.sfSpread(x)
+	default:
+		w := t.seekCDFWalk(x)
+		index, weight := w.rank(x)
+		sf = (t.summary.RangeSum(index, t.summary.Len()) - weight) / float64(t.count)
+	}
+
+	if !t.enoughSamples(sf, t.minSamples) {
+		return math.NaN()
+	}
+	return sf
+}
+
+// sfSpread is the survival function of a digest with variances, which
+// mirrors cdfSpread from the largest centroid down.
+func (t *TDigest) sfSpread(x float64) float64 {
+	var total float64
+	for i := t.summary.Len() - 1; i >= 0; i-- {
+		lo, hi := t.summary.spreadBounds(i)
+		count := float64(t.summary.Count(i))
+
+		mean := t.summary.Mean(i)
+		switch {
+		case x >= hi:
+			return total / float64(t.count)
+		case x >= mean:
+			return (total + count*(hi-x)/(hi-mean)/2) / float64(t.count)
+		case x >= lo:
+			return (total + count*(0.5+(mean-x)/(mean-lo)/2)) / float64(t.count)
+		}
+		total += count
+	}
+	return 1
+}
@@ -0,0 +1,70 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSF(t *testing.T) {
+	empty := New(100)
+	if !math.IsNaN(empty.SF(0)) || empty.SF(math.Inf(-1)) != 1 || empty.SF(math.Inf(1)) != 0 {
+		t.Errorf("got %v, %v and %v for an empty digest", empty.SF(0), empty.SF(math.Inf(-1)), empty.SF(math.Inf(1)))
+	}
+
+	// a heavy tailed Pareto distribution
+	rng := rand.New(rand.NewSource(0x5f))
+	for name, opts := range optionVariants(nil) {
+		tdigest := New(100, opts...)
+		for i := 0; i < 100000; i++ {
+			assertNoError(t, tdigest.Add(math.Pow(1-rng.Float64(), -1/1.5)))
+		}
+
+		for _, q := range []float64{0.001, 0.1, 0.5, 0.9, 0.999} {
+			x := tdigest.Quantile(q)
+			if sf, c := tdigest.SF(x), tdigest.CDF(x); math.Abs(sf-(1-c)) > 1e-12 {
+				t.Errorf("%s: SF(%v) = %v, expected 1-CDF = %v", name, x, sf, 1-c)
+			}
+		}
+		// the largest sample is a point, except for the intervals of
+		// centroids with variances
+		if sf := tdigest.SF(tdigest.Max()); sf != 0.5/float64(tdigest.Count()) && name != "variance" {
+			t.Errorf("%s: SF(Max) = %v, expected half of the largest sample", name, sf)
+		}
+		if sf := tdigest.SF(2 * tdigest.Max()); sf != 0 {
+			t.Errorf("%s: SF above the samples = %v, expected 0", name, sf)
+		}
+		if sf := tdigest.SF(0); sf != 1 {
+			t.Errorf("%s: SF below the samples = %v, expected 1", name, sf)
+		}
+		if !math.IsNaN(tdigest.SF(math.NaN())) {
+			t.Errorf("%s: expected NaN for NaN", name)
+		}
+	}
+
+	// a tail of 1e-7 is lost to rounding by 1-CDF but not by SF
+	tdigest := New(100)
+	assertNoError(t, tdigest.AddWeighted(1, 4999999))
+	assertNoError(t, tdigest.Add(1000))
+	for _, test := range []struct {
+		x, sf float64
+	}{
+		{1000, 1e-7},
+		{500, 2e-7},
+	} {
+		if sf := tdigest.SF(test.x); math.Abs(sf-test.sf) > 1e-15*test.sf {
+			t.Errorf("SF(%v) = %v, expected %v", test.x, sf, test.sf)
+		}
+		if sf := 1 - tdigest.CDF(test.x); math.Abs(sf-test.sf) < 1e-15*test.sf {
+			t.Errorf("1-CDF(%v) = %v, expected it to be rounded", test.x, sf)
+		}
+	}
+
+	bounded := New(100, WithMinSamples(10))
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, bounded.Add(float64(i)))
+	}
+	if !math.IsNaN(bounded.SF(995)) || math.IsNaN(bounded.SF(500)) {
+		t.Errorf("got %v and %v, expected NaN in the tail only", bounded.SF(995), bounded.SF(500))
+	}
+}
@@ -0,0 +1,210 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// WithExactTails makes the digest keep its k smallest and k largest
+// samples exactly, next to the centroids. Quantiles whose rank falls among
+// them are computed from those samples, like from a sorted slice, and the
+// estimates of the digest in between are kept from crossing them, which
+// makes extreme quantiles such as 0.0001 and 0.9999 exact for up to a few
+// hundred samples on either side.
+//
+// The tails are kept up to date by Add, AddWeighted, Merge and
+// MergeDestructive with digests that keep at least as many, and are
+// recorded by Marshal. A digest that gains or loses samples in any other
+// way, such as by merging one without exact tails or by removing samples,
+// no longer knows its extremes exactly and only uses its centroids. It
+// panics if k is less than 1.
+func WithExactTails(k int) Option {
+	if k < 1 {
+		panic("k must be at least 1")
+	}
+	return func(t *TDigest) {
+		t.tails = &exactTails{k: k}
+	}
+}
+
+// exactTails holds the smallest and largest samples of a digest.
+type exactTails struct {
+	k int
+
+	// low holds the k smallest samples and high the k largest, in
+	// increasing order. They hold every sample of digests of at most k.
+	low, high []float64
+
+	// seen is the number of samples the tails were offered, and they are
+	// exact while it is the count of the digest.
+	seen uint64
+}
+
+// add offers count samples of the given value to the tails.
+func (e *exactTails) add(value float64, count uint32) {
+	e.seen += uint64(count)
+	e.insert(value, count)
+}
+
+// insert adds up to k copies of value to the tails, without counting them.
+func (e *exactTails) insert(value float64, count uint32) {
+	n := e.k
+	if uint64(count) < uint64(n) {
+		n = int(count)
+	}
+
+	if len(e.low) < e.k || value < e.low[len(e.low)-1] {
+		i := sort.SearchFloat64s(e.low, math.Nextafter(value, math.Inf(1)))
+		e.low = insertCopies(e.low, i, value, n)
+		if len(e.low) > e.k {
+			e.low = e.low[:e.k]
+		}
+	}
+	if len(e.high) < e.k || value > e.high[0] {
+		i := sort.SearchFloat64s(e.high, value)
+		e.high = insertCopies(e.high, i, value, n)
+		if extra := len(e.high) - e.k; extra > 0 {
+			e.high = append(e.high[:0], e.high[extra:]...)
+		}
+	}
+}
+
+// insertCopies inserts n copies of value at index i of values.
+func insertCopies(values []float64, i int, value float64, n int) []float64 {
+	for j := 0; j < n; j++ {
+		values = append(values, 0)
+	}
+	copy(values[i+n:], values[i:])
+	for j := i; j < i+n; j++ {
+		values[j] = value
+	}
+	return values
+}
+
+// merge adds the samples of other to the tails. When other holds fewer of
+// them than the tails need, its samples are not counted, which leaves the
+// tails inexact.
+func (e *exactTails) merge(other *exactTails, otherCount uint64) {
+	if other == nil || other.seen != otherCount {
+		return
+	}
+	if other.k >= e.k || other.seen <= uint64(other.k) {
+		e.seen += other.seen
+	}
+
+	// other may be e itself
+	low := append([]float64(nil), other.low...)
+	high := append([]float64(nil), other.high...)
+	for i := len(low) - 1; i >= 0; i-- {
+		e.insert(low[i], 1)
+	}
+	for _, value := range high {
+		e.insert(value, 1)
+	}
+}
+
+// reset empties the tails.
+func (e *exactTails) reset() {
+	e.low, e.high, e.seen = e.low[:0], e.high[:0], 0
+}
+
+// clone returns a copy of the tails.
+func (e *exactTails) clone() *exactTails {
+	if e == nil {
+		return nil
+	}
+	return &exactTails{
+		k:    e.k,
+		low:  append([]float64(nil), e.low...),
+		high: append([]float64(nil), e.high...),
+		seen: e.seen,
+	}
+}
+
+// stitch returns the quantile q of a digest of count samples from the tails
+// when its rank falls among them, and otherwise the estimate x of the
+// digest kept between them. Tails that are nil or inexact return x.
+func (e *exactTails) stitch(q float64, count uint64, x float64) float64 {
+	if e == nil || e.seen != count || count == 0 {
+		return x
+	}
+
+	index := q * float64(count-1)
+	lo, hi := math.Floor(index), math.Ceil(index)
+	if n := float64(len(e.low)); hi < n {
+		return interpolateSorted(e.low, index)
+	}
+	if first := float64(count) - float64(len(e.high)); lo >= first {
+		return interpolateSorted(e.high, index-first)
+	}
+	return math.Max(e.low[len(e.low)-1], math.Min(e.high[0], x))
+}
+
+// interpolateSorted interpolates linearly between the sorted values around
+// index, which must be within them.
+func interpolateSorted(values []float64, index float64) float64 {
+	lo, hi := int(math.Floor(index)), int(math.Ceil(index))
+	if lo == hi {
+		return values[lo]
+	}
+	return values[lo] + (values[hi]-values[lo])*(index-float64(lo))
+}
+
+// appendTo appends the payload of sectionExactTails to buf.
+func (e *exactTails) appendTo(buf []byte) []byte {
+	buf = appendUvarint(buf, uint64(e.k))
+	buf = appendUvarint(buf, e.seen)
+	for _, values := range [][]float64{e.low, e.high} {
+		buf = appendUvarint(buf, uint64(len(values)))
+		for _, value := range values {
+			var scratch [8]byte
+			binary.BigEndian.PutUint64(scratch[:], math.Float64bits(value))
+			buf = append(buf, scratch[:]...)
+		}
+	}
+	return buf
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(buf, scratch[:binary.PutUvarint(scratch[:], x)]...)
+}
+
+// decodeExactTails decodes the payload of sectionExactTails.
+func decodeExactTails(buf []byte) (*exactTails, error) {
+	k, n := binary.Uvarint(buf)
+	if n <= 0 || k < 1 || k > math.MaxInt32 {
+		return nil, errors.New("invalid exact tails")
+	}
+	buf = buf[n:]
+	seen, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return nil, errors.New("invalid exact tails")
+	}
+	buf = buf[n:]
+
+	e := &exactTails{k: int(k), seen: seen}
+	for _, values := range []*[]float64{&e.low, &e.high} {
+		length, n := binary.Uvarint(buf)
+		if n <= 0 || length > k || uint64(len(buf)-n) < 8*length {
+			return nil, errors.New("invalid exact tails")
+		}
+		buf = buf[n:]
+
+		*values = make([]float64, length)
+		for i := range *values {
+			value := math.Float64frombits(binary.BigEndian.Uint64(buf[8*i:]))
+			if math.IsNaN(value) || i > 0 && value < (*values)[i-1] {
+				return nil, errors.New("invalid exact tails")
+			}
+			(*values)[i] = value
+		}
+		buf = buf[8*length:]
+	}
+	if len(buf) > 0 || len(e.low) != len(e.high) {
+		return nil, errors.New("invalid exact tails")
+	}
+	return e, nil
+}
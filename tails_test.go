@@ -0,0 +1,154 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestExactTails(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7a115))
+	data := make([]float64, 1000000)
+	tdigest := New(100, WithExactTails(128))
+	plain := New(100)
+	for i := range data {
+		data[i] = rng.ExpFloat64()
+		assertNoError(t, tdigest.Add(data[i]))
+		assertNoError(t, plain.Add(data[i]))
+	}
+	sort.Float64s(data)
+
+	tails := []float64{0, 0.00001, 0.0001, 0.9999, 0.99999, 1}
+	assertExactTails(t, "digest", tdigest, data, tails)
+	if q := 0.9999; plain.Quantile(q) == quantile(q, data) {
+		t.Errorf("Expected the digest without exact tails to estimate Quantile(%v)", q)
+	}
+
+	previous := math.Inf(-1)
+	for q := 0.0; q <= 1; q += 0.00001 {
+		x := tdigest.Quantile(q)
+		if x < previous {
+			t.Fatalf("Quantile(%v) = %v is lower than %v", q, x, previous)
+		}
+		previous = x
+	}
+	for _, q := range []float64{0.001, 0.5, 0.999} {
+		if err := rankError(q, tdigest.Quantile(q), data); err > 0.001 {
+			t.Errorf("Quantile(%v) is off by %v in rank", q, err)
+		}
+	}
+
+	if got := tdigest.Quantiles(tails); !sameFloats(got, quantiles(tails, data)) {
+		t.Errorf("Quantiles(%v) = %v, expected %v", tails, got, quantiles(tails, data))
+	}
+	if x, err := tdigest.KthLargest(10); err != nil || x != data[len(data)-10] {
+		t.Errorf("KthLargest(10) = %v, %v, expected %v", x, err, data[len(data)-10])
+	}
+
+	decoded, err := FromBytes(tdigest.Marshal(nil))
+	assertNoError(t, err)
+	assertExactTails(t, "decoded", decoded, data, tails)
+	if n := len(tdigest.Marshal(nil)); n != tdigest.MarshaledSizeBytes() {
+		t.Errorf("got %d bytes, MarshaledSizeBytes returned %d", n, tdigest.MarshaledSizeBytes())
+	}
+
+	// digests merged from parts keep the tails exact, unless a part does
+	// not keep enough of them
+	parts := []*TDigest{New(100, WithExactTails(128)), New(100, WithExactTails(256))}
+	perm := rng.Perm(len(data))
+	for i, j := range perm {
+		assertNoError(t, parts[i%2].Add(data[j]))
+	}
+	merged := New(100, WithExactTails(128))
+	assertNoError(t, merged.Merge(parts[0]))
+	assertNoError(t, merged.MergeDestructive(parts[1]))
+	assertExactTails(t, "merged", merged, data, tails)
+
+	assertNoError(t, merged.Merge(New(100, WithExactTails(10))))
+	assertExactTails(t, "merged with an empty digest", merged, data, tails)
+	other := New(100, WithExactTails(10))
+	for i := 0; i < 20; i++ {
+		assertNoError(t, other.Add(float64(i)))
+	}
+	assertNoError(t, merged.Merge(other))
+	if merged.tails.seen == merged.Count() {
+		t.Errorf("Expected the tails to be inexact after merging a digest keeping fewer of them")
+	}
+
+	shouldPanic(func() { WithExactTails(0) }, t, "WithExactTails(0) should panic")
+}
+
+func TestExactTailsSmall(t *testing.T) {
+	tdigest := New(10, WithExactTails(100), WithInterpolation(InterpolationMidpoint))
+	data := make([]float64, 100)
+	for i := range data {
+		data[i] = rand.NormFloat64()
+		assertNoError(t, tdigest.AddWeighted(data[i], 1))
+	}
+	sort.Float64s(data)
+
+	// every sample is in the tails
+	for q := 0.0; q <= 1; q += 0.001 {
+		if got, expected := tdigest.Quantile(q), midpointQuantile(q, data); math.Abs(got-expected) > 1e-12 {
+			t.Fatalf("Quantile(%v) = %v, expected %v", q, got, expected)
+		}
+	}
+
+	// removing samples leaves the digest to the centroids
+	_, err := tdigest.RemoveValueWithin(data[0], 0, 1)
+	assertNoError(t, err)
+	if got, expected := tdigest.Quantile(0), data[0]; got == expected {
+		t.Errorf("Expected Quantile(0) to come from the centroids after removing %v", expected)
+	}
+}
+
+func TestExactTailsWeighted(t *testing.T) {
+	tdigest := New(100, WithExactTails(4))
+	assertNoError(t, tdigest.AddWeighted(10, 3))
+	assertNoError(t, tdigest.AddWeighted(1, 2))
+	assertNoError(t, tdigest.AddWeighted(5, 1000))
+
+	if !sameFloats(tdigest.tails.low, []float64{1, 1, 5, 5}) || !sameFloats(tdigest.tails.high, []float64{5, 10, 10, 10}) {
+		t.Errorf("got tails %v and %v", tdigest.tails.low, tdigest.tails.high)
+	}
+	for _, payload := range [][]byte{
+		{},
+		{0, 0, 0, 0},
+		{1, 5, 2},
+		{1, 5, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		if _, err := decodeExactTails(payload); err == nil {
+			t.Errorf("Expected an error decoding %v", payload)
+		}
+	}
+}
+
+func assertExactTails(t *testing.T, name string, tdigest *TDigest, data, qs []float64) {
+	t.Helper()
+	for _, q := range qs {
+		if got, expected := tdigest.Quantile(q), quantile(q, data); math.Abs(got-expected) > 1e-12*math.Abs(expected) {
+			t.Errorf("%s: Quantile(%v) = %v, expected %v", name, q, got, expected)
+		}
+	}
+}
+
+func quantiles(qs, data []float64) []float64 {
+	out := make([]float64, len(qs))
+	for i, q := range qs {
+		out[i] = quantile(q, data)
+	}
+	return out
+}
+
+func sameFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-12*math.Abs(b[i]) {
+			return false
+		}
+	}
+	return true
+}
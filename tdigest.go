@@ -21,53 +21,299 @@
 package tdigest
 
 import (
+	"errors"
 	"fmt"
 	"math"
-	"math/rand"
+	"sort"
 )
 
-// TDigest is a quantile approximation data structure.
+var (
+	// ErrEmptyDigest is returned by queries that have no answer for a
+	// digest without samples.
+	ErrEmptyDigest = errors.New("tdigest: empty digest")
+
+	// ErrInvalidQuantile is returned for quantiles outside of [0, 1].
+	ErrInvalidQuantile = errors.New("tdigest: quantile must be between 0 and 1 (inclusive)")
+
+	// ErrInvalidRank is returned for ranks outside of [1, Count()].
+	ErrInvalidRank = errors.New("tdigest: rank must be between 1 and the number of samples (inclusive)")
+
+	// ErrInvalidValue is returned for NaN values, and for samples at -Inf or
+	// +Inf unless the digest was created with WithInfiniteValues.
+	ErrInvalidValue = errors.New("tdigest: invalid value")
+
+	// ErrTooFewSamples is returned for estimates that a digest created with
+	// WithMinSamples has too few samples to make.
+	ErrTooFewSamples = errors.New("tdigest: too few samples for the estimate")
+)
+
+// Centroid is a group of Count samples summarized by their Mean.
+type Centroid struct {
+	Mean  float64
+	Count uint32
+}
+
+// TDigest is a quantile approximation data structure. It is not safe for
+// concurrent use.
 type TDigest struct {
 	summary     *summary
 	compression float64
 	count       uint64
 	pcg         pcg
+	writing     bool
+
+	// delta is the maximum number of centroids after compression when the
+	// digest was created with WithMaxCentroidBound, and 0 otherwise.
+	delta int
+
+	// budget is the number of centroids a single insertion may compress
+	// when the digest was created with WithIncrementalCompression, and 0
+	// otherwise. sweeping and cursor track the progress of the current pass.
+	budget   int
+	sweeping bool
+	cursor   int
+
+	// background is set when a Concurrent compacts the digest in the
+	// background, leaving insertions to compress only as a last resort.
+	background bool
+
+	// variance is set when the digest was created with WithCentroidVariance.
+	variance bool
+
+	// step is the grid samples are snapped to when the digest was created
+	// with WithQuantization, and 0 otherwise.
+	step float64
+
+	// metadata is the opaque data attached by SetMetadata, and nil when
+	// there is none.
+	metadata []byte
+
+	// thresholds holds the callbacks registered with OnThreshold, which
+	// are not copied along with the digest.
+	thresholds []*thresholdCallback
+
+	// sum is the sum of the samples and m2 the sum of their squared
+	// deviations from their mean.
+	sum float64
+	m2  float64
+
+	// m3 and m4 are the sums of their cubed and fourth power deviations.
+	m3, m4 float64
+
+	// min and max are the smallest and largest samples, and +Inf and -Inf
+	// before the first one.
+	min, max float64
+
+	// greedy is set when the digest was created with WithGreedyCandidates.
+	greedy bool
+
+	// deterministic is set when the digest was created with
+	// WithDeterministicCompress.
+	deterministic bool
+
+	// infinite is set when the digest was created with WithInfiniteValues.
+	infinite bool
+
+	// interpolation is the mode set by WithInterpolation.
+	interpolation Interpolation
+
+	// tails holds the extreme samples when the digest was created with
+	// WithExactTails, and is nil otherwise.
+	tails *exactTails
+
+	// maxCentroids bounds the number of centroids when the digest was
+	// created with WithSmallFootprint, and is 0 otherwise.
+	maxCentroids int
+
+	// capacity is the initial capacity of the summary when the digest was
+	// created with WithCapacity, and 0 otherwise.
+	capacity int
+
+	// trigger is the number of centroids above which the digest compresses
+	// itself when it was created with WithAutoCompressThreshold, and 0
+	// otherwise.
+	trigger int
+
+	// seed is the seed of WithSeed the generator started from.
+	seed uint64
+
+	// compressing is set while compress adds the centroids back, which must
+	// not compress the digest again however low its threshold.
+	compressing bool
+
+	// minSamples is the number of samples WithMinSamples requires in the
+	// tail of the quantiles estimated by queries.
+	minSamples uint64
+
+	// generation is incremented by every mutation, so that results derived
+	// from the summary can be cached until it changes.
+	generation uint64
+}
+
+// Option configures a digest created with New.
+type Option func(*TDigest)
+
+// WithMaxCentroidBound makes the digest size its centroids with the arcsine
+// scale function of the 2019 t-digest paper, normalized so that compression
+// never leaves more than delta centroids. Between compressions the digest
+// grows to at most 2*delta centroids before compressing itself, which makes
+// its memory use predictable.
+//
+// The compression passed to New is replaced by delta/π, which is the legacy
+// compression allowing the same centroid weight at the median. It panics if
+// delta is less than 1.
+func WithMaxCentroidBound(delta int) Option {
+	if delta < 1 {
+		panic("delta must be at least 1")
+	}
+	return func(t *TDigest) {
+		t.delta = delta
+		t.compression = float64(delta) / math.Pi
+	}
+}
+
+// beginWrite and endWrite bracket every mutation of the digest, and
+// checkRead is called by every query. The digest is not safe for concurrent
+// use, and like the runtime's map checks these catch most violations with a
+// descriptive panic instead of silently corrupting the summary.
+func (t *TDigest) beginWrite() {
+	if t.writing {
+		panic("concurrent tdigest mutation detected")
+	}
+	t.writing = true
+}
+
+func (t *TDigest) endWrite() {
+	if !t.writing {
+		panic("concurrent tdigest mutation detected")
+	}
+	t.writing = false
+	t.generation++
+}
+
+func (t *TDigest) checkRead() {
+	if t.writing {
+		panic("concurrent tdigest mutation detected")
+	}
 }
 
 // New creates a new digest.
-func New(compression float64) *TDigest {
-	return &TDigest{
+//
+// Compressions below 1, including NaN, are treated as 1. Smaller values
+// would not allow a digest to compress anything, turning it into an ever
+// growing list of samples.
+func New(compression float64, opts ...Option) *TDigest {
+	t := &TDigest{
 		compression: compression,
 		count:       0,
-		summary:     newSummary(estimateCapacity(compression)),
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.delta == 0 && !(t.compression >= 1) {
+		t.compression = 1
 	}
+	t.summary = t.newSummary(t.estimateCapacity())
+	return t
 }
 
+// newSummary returns an empty summary tracking centroid variances if the
+// digest does.
+func (t *TDigest) newSummary(initialCapacity uint) *summary {
+	s := newSummary(initialCapacity)
+	if t.variance {
+		s.m2 = make([]float64, 0, initialCapacity)
+	}
+	s.limit = t.maxCentroids
+	return s
+}
+
+// _quantile interpolates linearly between the means at the two indexes.
+// The result is non-decreasing in index and stays between the two means,
+// even when they are equal, so that quantiles do not decrease from one
+// pair of centroids to the next.
 func _quantile(index float64, previousIndex float64, nextIndex float64, previousMean float64, nextMean float64) float64 {
-	delta := nextIndex - previousIndex
-	previousWeight := (nextIndex - index) / delta
-	nextWeight := (index - previousIndex) / delta
-	return previousMean*previousWeight + nextMean*nextWeight
+	nextWeight := (index - previousIndex) / (nextIndex - previousIndex)
+	x := previousMean + (nextMean-previousMean)*nextWeight
+	if previousMean <= nextMean {
+		return math.Max(previousMean, math.Min(nextMean, x))
+	}
+	return math.Max(nextMean, math.Min(previousMean, x))
 }
 
 // Quantile returns the desired percentile estimation.
 //
+// The quantiles 0 and 1 are the exact smallest and largest samples, as
+// returned by Min and Max, and no estimate lies outside of them.
+//
 // Values of p must be between 0 and 1 (inclusive), will panic otherwise.
+//
+// It returns NaN when the digest is empty, or when it was created with
+// WithMinSamples and has too few samples to estimate q. Use QuantileE to
+// tell these cases apart.
 func (t *TDigest) Quantile(q float64) float64 {
-	if q < 0 || q > 1 {
+	x, err := t.QuantileE(q)
+	if err == ErrInvalidQuantile && !math.IsNaN(q) {
 		panic("q must be between 0 and 1 (inclusive)")
 	}
+	return x
+}
+
+// QuantileE is like Quantile but reports the cases without an estimate with
+// an error rather than a panic or NaN: ErrInvalidQuantile if q is outside
+// of [0, 1] or NaN, ErrEmptyDigest for an empty digest and ErrTooFewSamples
+// when WithMinSamples prevents the estimate.
+func (t *TDigest) QuantileE(q float64) (float64, error) {
+	if q < 0 || q > 1 || math.IsNaN(q) {
+		return math.NaN(), ErrInvalidQuantile
+	}
+	t.checkRead()
+
+	if t.summary.Len() == 0 {
+		return math.NaN(), ErrEmptyDigest
+	} else if !t.enoughSamples(q, t.minSamples) {
+		return math.NaN(), ErrTooFewSamples
+	}
+	return t.quantile(q), nil
+}
+
+// quantile is Quantile without the checks, for estimates that are not
+// subject to WithMinSamples.
+func (t *TDigest) quantile(q float64) float64 {
+	if t.interpolation == InterpolationMidpoint && t.count > 1 {
+		return t.quantileMidpoint(q)
+	}
+	return t.quantileLinear(q)
+}
+
+// quantileLinear estimates the quantile q with InterpolationLinear.
+func (t *TDigest) quantileLinear(q float64) float64 {
+	return t.tails.stitch(q, t.count, t.estimate(q))
+}
 
+// estimate estimates the quantile q from the centroids alone.
+func (t *TDigest) estimate(q float64) float64 {
 	if t.summary.Len() == 0 {
 		return math.NaN()
+	} else if t.summary.m2 != nil {
+		return t.pin(q, t.quantileSpread(q))
 	} else if t.summary.Len() == 1 {
-		return t.summary.Mean(0)
+		return t.pin(q, t.summary.Mean(0))
 	}
 
 	index := q * float64(t.count-1)
+	next, total := t.summary.FloorSum(index)
+	return t.pin(q, t.quantileFrom(index, next, total))
+}
+
+// quantileFrom estimates the sample at the given index, as found by
+// FloorSum(index) which returned next and total. The digest must have at
+// least two centroids without variances.
+func (t *TDigest) quantileFrom(index float64, next int, total float64) float64 {
 	previousMean := math.NaN()
 	previousIndex := float64(0)
-	next, total := t.summary.FloorSum(index)
 
 	if next > 0 {
 		previousMean = t.summary.Mean(next - 1)
@@ -85,6 +331,7 @@ func (t *TDigest) Quantile(q float64) float64 {
 				// assume linear growth
 				nextIndex2 := total + float64(t.summary.Count(next)) + float64(t.summary.Count(next+1)-1)/2
 				previousMean = (nextIndex2*t.summary.Mean(next) - nextIndex*t.summary.Mean(next+1)) / (nextIndex2 - nextIndex)
+				previousMean = math.Min(previousMean, t.summary.Mean(next))
 			}
 			// common case: two centroids found, the result in in between
 			return _quantile(index, previousIndex, nextIndex, previousMean, t.summary.Mean(next))
@@ -92,6 +339,7 @@ func (t *TDigest) Quantile(q float64) float64 {
 			// the index is after the last centroid
 			nextIndex2 := float64(t.count - 1)
 			nextMean2 := (t.summary.Mean(next)*(nextIndex2-previousIndex) - previousMean*(nextIndex2-nextIndex)) / (nextIndex - previousIndex)
+			nextMean2 = math.Max(nextMean2, t.summary.Mean(next))
 			return _quantile(index, nextIndex, nextIndex2, t.summary.Mean(next), nextMean2)
 		}
 		total += float64(t.summary.Count(next))
@@ -114,21 +362,123 @@ func weightedAverage(x1 float64, w1 float64, x2 float64, w2 float64) float64 {
 // It's the main entry point for the digest and very likely the only
 // method to be used for collecting samples. The count parameter is for
 // when you are registering a sample that occurred multiple times - the
-// most common value for this is 1.
+// most common value for this is 1. A count larger than a centroid may hold
+// at the quantile of the samples is split over several centroids.
 //
-// This will emit an error if `value` is NaN of if `count` is zero.
+// This will emit an error if `count` is zero, and ErrInvalidValue if
+// `value` is NaN or infinite, as described in WithInfiniteValues. The digest
+// is left untouched by a sample it rejects.
 func (t *TDigest) AddWeighted(value float64, count uint32) (err error) {
+	t.beginWrite()
+	err = t.addWeighted(value, count)
+	t.endWrite()
+	t.checkThresholds()
+	return err
+}
 
+func (t *TDigest) addWeighted(value float64, count uint32) (err error) {
+	if err := t.checkValue(value); err != nil {
+		return err
+	}
+	value = t.quantize(value)
 	if count == 0 {
 		return fmt.Errorf("Illegal datapoint <value: %.4f, count: %d>", value, count)
 	}
+	for piece, left := t.pieceSize(value, count), count; left > 0; left -= piece {
+		if piece > left {
+			piece = left
+		}
+		if err := t.addCentroid(value, piece, 0); err != nil {
+			return err
+		}
+	}
+	if t.tails != nil {
+		t.tails.add(value, count)
+	}
+	return nil
+}
 
-	if t.summary.Len() == 0 {
-		err = t.summary.Add(value, count)
-		t.count = uint64(count)
+// pieceSize returns the number of samples of each of the centroids count
+// samples at value are split into. A single centroid holding more samples
+// than the threshold at their quantile would never be broken up again,
+// leaving the digest unable to resolve the quantiles around value however
+// many samples follow, so they are split into as few even pieces as the
+// threshold allows, which compressing later merges back where it can.
+func (t *TDigest) pieceSize(value float64, count uint32) uint32 {
+	if count == 1 {
+		return 1
+	}
+
+	// the samples take the ranks [lo, lo+count) of the digest holding them
+	n := float64(t.count) + float64(count)
+	lo := t.summary.HeadSum(t.summary.Floor(value) + 1)
+	q := (lo + float64(count-1)/2) / (n - 1)
+	limit := math.Floor(t.thresholdAt(n, q))
+	if float64(count) <= limit || limit < 2 {
+		// where the threshold allows no centroid of several samples, no
+		// sample is merged with the others and splitting would not help
+		return count
+	}
+
+	pieces := math.Ceil(float64(count) / limit)
+	return uint32(math.Ceil(float64(count) / pieces))
+}
+
+// AddCentroid adds a centroid of count samples at exactly mean, as when
+// importing centroids from another sketch or a histogram. Unlike
+// AddWeighted, which merges the samples into one of the closest centroids
+// with room for them, it always inserts a new centroid, which is only merged
+// with others once the digest compresses itself as usual. The samples update
+// Count, the extremes and the moments as if they were all at mean, and leave
+// the tails of WithExactTails inexact. FromBytes restores the centroids of a
+// digest the same way.
+//
+// It returns ErrInvalidValue if mean is rejected by AddWeighted, and an
+// error if count is zero.
+func (t *TDigest) AddCentroid(mean float64, count uint32) (err error) {
+	if err := t.checkValue(mean); err != nil {
 		return err
 	}
 
+	t.beginWrite()
+	err = t.addCentroidAt(mean, count, 0)
+	t.endWrite()
+	t.checkThresholds()
+	return err
+}
+
+// addCentroidAt inserts a centroid of count samples with the given mean and
+// sum of squared deviations from it, which is ignored unless the digest
+// tracks variances.
+func (t *TDigest) addCentroidAt(mean float64, count uint32, m2 float64) error {
+	if count == 0 {
+		return fmt.Errorf("invalid count: %d", count)
+	}
+	if err := t.summary.insert(mean, count, m2); err != nil {
+		return err
+	}
+	t.observe(mean, mean)
+	t.accumulate(uint64(count), mean*float64(count), m2)
+
+	return t.autoCompress()
+}
+
+// addCentroid adds count samples with the given mean and sum of squared
+// deviations from it, which is ignored unless the digest tracks variances.
+func (t *TDigest) addCentroid(value float64, count uint32, m2 float64) (err error) {
+	if count == 0 {
+		return fmt.Errorf("Illegal datapoint <value: %.4f, count: %d>", value, count)
+	}
+	t.observe(value, value)
+
+	if t.summary.Len() == 0 {
+		if err := t.summary.insert(value, count, m2); err != nil {
+			return err
+		}
+		t.accumulate(uint64(count), value*float64(count), m2)
+		return nil
+	}
+
 	begin := t.summary.Floor(value)
 	if begin == -1 {
 		begin = 0
@@ -139,22 +489,22 @@ func (t *TDigest) AddWeighted(value float64, count uint32) (err error) {
 	closest := t.chooseMergeCandidate(begin, end, value, count)
 
 	if closest == t.summary.Len() {
-		err = t.summary.Add(value, count)
+		err = t.summary.insert(value, count, m2)
 		if err != nil {
 			return err
 		}
 	} else {
 		c := float64(t.summary.Count(closest))
+		if t.summary.m2 != nil {
+			d := value - t.summary.Mean(closest)
+			t.summary.m2[closest] += m2 + d*d*c*float64(count)/(c+float64(count))
+		}
 		newMean := weightedAverage(t.summary.Mean(closest), c, value, float64(count))
 		t.summary.setAt(closest, newMean, uint32(c)+count)
 	}
-	t.count += uint64(count)
+	t.accumulate(uint64(count), value*float64(count), m2)
 
-	if float64(t.summary.Len()) > 20*t.compression {
-		err = t.Compress()
-	}
-
-	return err
+	return t.autoCompress()
 }
 
 // Count returns the total number of samples this digest represents
@@ -174,15 +524,34 @@ func (t *TDigest) AddWeighted(value float64, count uint32) (err error) {
 // is reached (say, minimum number of samples or a small relative
 // error between new and old digests).
 func (t TDigest) Count() uint64 {
+	t.checkRead()
 	return t.count
 }
 
+// TotalWeight returns the total weight of the samples in the digest, the
+// same as Count. Both are 64 bits wide, as are the sums the digest keeps
+// over the counts of its centroids, so that a digest may hold more than 2^32
+// samples although every centroid holds at most that many.
+func (t *TDigest) TotalWeight() uint64 {
+	return t.Count()
+}
+
 // Add is an alias for AddWeighted(x,1)
 // Read the documentation for AddWeighted for more details.
 func (t *TDigest) Add(value float64) error {
 	return t.AddWeighted(value, 1)
 }
 
+// Observe is like Add, but drops the values Add rejects, such as NaN, as
+// the Observer interface of Prometheus client libraries has no room for an
+// error. It lets a digest stand in for a Prometheus summary or histogram in
+// code written against that interface. The digest is not safe for
+// concurrent use, unlike most implementations of the interface: wrap it in
+// a Concurrent for that.
+func (t *TDigest) Observe(value float64) {
+	_ = t.Add(value)
+}
+
 // Compress tries to reduce the number of individual centroids stored
 // in the digest.
 //
@@ -194,20 +563,88 @@ func (t *TDigest) Add(value float64) error {
 // may completely ignore this and it will compress itself automatically
 // after it grows too much. If you are minimizing network traffic
 // it might be a good idea to compress before serializing.
+//
+// Compressing adds the centroids back in random order, unless the digest
+// was created with WithMaxCentroidBound or WithDeterministicCompress.
 func (t *TDigest) Compress() (err error) {
+	t.beginWrite()
+	err = t.compress()
+	t.endWrite()
+	t.checkThresholds()
+	return err
+}
+
+// Compression returns the compression of the digest. For digests created
+// with WithMaxCentroidBound it is delta/π.
+func (t *TDigest) Compression() float64 {
+	t.checkRead()
+	return t.compression
+}
+
+// Rescale changes the compression of the digest and compresses it, which
+// merges its centroids as allowed by the new compression. Lowering the
+// compression saves memory and serialized size at the cost of precision,
+// while raising it only makes the digest more precise from then on, as
+// merged centroids cannot be split. Count, the extremes and the other
+// statistics are kept, and quantiles change about as much as with a digest
+// of the new compression. Compressions below 1 are treated as 1, as in New,
+// and digests created with WithMaxCentroidBound get the delta closest to
+// newCompression*π.
+//
+// It returns an error if newCompression is not positive and finite, or if
+// the digest was created with WithSmallFootprint, whose bound it must keep.
+func (t *TDigest) Rescale(newCompression float64) error {
+	if !(newCompression > 0) || math.IsInf(newCompression, 0) {
+		return fmt.Errorf("invalid compression: %v", newCompression)
+	}
+	if t.maxCentroids > 0 {
+		return errors.New("cannot rescale a digest with a small footprint")
+	}
+
+	defer t.checkThresholds()
+	t.beginWrite()
+	defer t.endWrite()
+
+	if t.delta > 0 {
+		t.delta = int(math.Max(1, math.Round(newCompression*math.Pi)))
+		t.compression = float64(t.delta) / math.Pi
+	} else {
+		t.compression = math.Max(1, newCompression)
+	}
+
+	if err := t.compress(); err != nil {
+		return err
+	}
+	t.summary = t.summary.Clone()
+	return nil
+}
+
+func (t *TDigest) compress() (err error) {
+	t.sweeping = false
+
 	if t.summary.Len() <= 1 {
 		return nil
 	}
 
+	if t.delta > 0 || t.deterministic {
+		t.cluster()
+		return nil
+	}
+
 	oldTree := t.summary
-	t.summary = newSummary(uint(t.summary.Len()))
-	t.count = 0
+	t.summary = t.newSummary(uint(t.summary.Len()))
 
-	shuffle(oldTree.means, oldTree.counts)
-	oldTree.ForEach(func(mean float64, count uint32) bool {
-		err = t.AddWeighted(mean, count)
-		return err == nil
-	})
+	// the moments of the centroids are not exactly those of the samples,
+	// which compressing does not change
+	saved := t.moments()
+	t.setMoments(moments{})
+	shuffle(oldTree, &t.pcg)
+	t.compressing = true
+	for i := 0; i < oldTree.Len() && err == nil; i++ {
+		err = t.addCentroid(oldTree.Mean(i), oldTree.Count(i), oldTree.M2(i))
+	}
+	t.compressing = false
+	t.setMoments(saved)
 
 	return err
 }
@@ -217,66 +654,305 @@ func (t *TDigest) Compress() (err error) {
 // Merging is useful when you have multiple TDigest instances running
 // in separate threads and you want to compute quantiles over all the
 // samples. This is particularly important on a scatter-gather/map-reduce
-// scenario.
+// scenario. The metadata of other is not merged, the receiver keeps its own.
+// The centroids of both digests are merged in a single sorted pass that
+// clusters them as compress does, so the result does not depend on any
+// random choice.
+//
+// A nil other is an empty digest, and merging it does nothing. Merging a
+// digest into itself merges a copy of it, doubling every sample, as
+// merging an identical digest would. An empty receiver with the same
+// options as other takes a copy of its centroids as they are.
 func (t *TDigest) Merge(other *TDigest) (err error) {
+	if other == nil {
+		return nil
+	}
+	if other != t {
+		other.checkRead()
+	}
+	t.beginWrite()
+	err = t.merge(other)
+	t.endWrite()
+	t.checkThresholds()
+	return err
+}
+
+func (t *TDigest) merge(other *TDigest) (err error) {
 	if other.summary.Len() == 0 {
 		return nil
 	}
 
 	// We must keep the other digest intact
-	data := other.summary.Clone()
-	shuffle(data.means, data.counts)
+	if t.tails != nil {
+		t.tails.merge(other.tails, other.count)
+	}
+	t.observe(other.min, other.max)
+	saved, others := t.moments(), other.moments()
+	if t.summary.Len() == 0 && t.sameLayout(other) {
+		t.summary = other.summary.Clone()
+		t.sweeping, t.cursor = false, 0
+	} else {
+		err = t.mergeFrom(other.summary, true)
+	}
+	t.setMoments(saved)
+	t.combine(others)
+	return err
+}
+
+// mergeFrom merges the centroids of data with those of the digest, the
+// moments of the digest already counting them. They are merged in a single
+// sorted pass, except in the digests that AddBatch adds values to one at a
+// time, which add them one by one in random order instead, shuffling a copy
+// of data if clone is set.
+func (t *TDigest) mergeFrom(data *summary, clone bool) error {
+	if t.addsEach() {
+		if clone {
+			data = data.Clone()
+		}
+		return t.mergeSummary(data)
+	}
+	t.mergeSortedSummary(data)
+	return nil
+}
+
+// mergeSummary adds the centroids of data to the digest in random order,
+// shuffling data in place.
+func (t *TDigest) mergeSummary(data *summary) (err error) {
+	shuffle(data, &t.pcg)
 
-	data.ForEach(func(mean float64, count uint32) bool {
-		err = t.AddWeighted(mean, count)
-		return err == nil
-	})
+	for i := 0; i < data.Len() && err == nil; i++ {
+		err = t.addCentroid(data.Mean(i), data.Count(i), data.M2(i))
+	}
+	return err
+}
+
+// MergeDestructive is like Merge but consumes other, which is left empty
+// afterwards. When the receiver is empty and both digests share the same
+// configuration it takes over the centroids of other without copying them,
+// and otherwise it merges them without making a copy first. This makes
+// combining many single-use digests noticeably cheaper than Merge.
+//
+// Merging a digest into itself or a nil one behaves like Merge.
+func (t *TDigest) MergeDestructive(other *TDigest) (err error) {
+	if other == t || other == nil {
+		return t.Merge(other)
+	}
+
+	other.beginWrite()
+	t.beginWrite()
+	if t.tails != nil {
+		t.tails.merge(other.tails, other.count)
+	}
+	if other.tails != nil {
+		other.tails.reset()
+	}
+	if t.summary.Len() == 0 && t.sameLayout(other) {
+		t.summary, other.summary = other.summary, t.summary
+		saved := t.moments()
+		t.setMoments(other.moments())
+		other.setMoments(saved)
+		t.min, other.min = other.min, t.min
+		t.max, other.max = other.max, t.max
+		t.sweeping, t.cursor = false, 0
+	} else if other.summary.Len() > 0 {
+		t.observe(other.min, other.max)
+		saved := t.moments()
+		err = t.mergeFrom(other.summary, false)
+		t.setMoments(saved)
+		t.combine(other.moments())
+		other.summary.reset()
+		other.setMoments(moments{})
+		other.min, other.max = math.Inf(1), math.Inf(-1)
+	}
+	other.sweeping, other.cursor = false, 0
+	t.endWrite()
+	other.endWrite()
+	t.checkThresholds()
 	return err
 }
 
+// sameLayout reports whether the summary of other could have been built by
+// t, so that t can use it as its own.
+func (t *TDigest) sameLayout(other *TDigest) bool {
+	return t.compression == other.compression &&
+		t.delta == other.delta &&
+		t.step == other.step &&
+		t.maxCentroids == other.maxCentroids &&
+		t.trigger == other.trigger &&
+		(t.summary.m2 != nil) == (other.summary.m2 != nil)
+}
+
 // CDF computes the fraction in which all samples are less than
 // or equal to the given value.
+//
+// As in the reference implementation, the weight between the means of two
+// centroids is interpolated from the half of each facing the other, except
+// for centroids that are single samples, or whose mean is one of the
+// extremes, which are points. The smallest and largest samples are points
+// at the exact extremes. Samples exactly equal to value count for half of
+// their weight.
+//
+// CDF(-Inf) is 0 and CDF(+Inf) is 1, even for an empty digest, and CDF(NaN)
+// is NaN. Otherwise it returns NaN when the digest is empty, or when it was
+// created with WithMinSamples and has too few samples on either side of
+// value. Use CDFE to tell these cases apart.
 func (t *TDigest) CDF(value float64) float64 {
-	if t.summary.Len() == 0 {
+	c, _ := t.CDFE(value)
+	return c
+}
+
+// CDFE is like CDF but reports the cases without an estimate with an
+// error rather than NaN: ErrInvalidValue if value is NaN, ErrEmptyDigest
+// for an empty digest and ErrTooFewSamples when WithMinSamples prevents the
+// estimate.
+func (t *TDigest) CDFE(value float64) (float64, error) {
+	if math.IsNaN(value) {
+		return math.NaN(), ErrInvalidValue
+	}
+	t.checkRead()
+
+	if math.IsInf(value, 0) {
+		return t.cdf(value), nil
+	} else if t.summary.Len() == 0 {
+		return math.NaN(), ErrEmptyDigest
+	}
+	c := t.cdf(value)
+	if !t.enoughSamples(c, t.minSamples) {
+		return math.NaN(), ErrTooFewSamples
+	}
+	return c, nil
+}
+
+// cdf is CDF without the checks, for estimates that are not subject to
+// WithMinSamples.
+func (t *TDigest) cdf(value float64) float64 {
+	if math.IsNaN(value) {
 		return math.NaN()
-	} else if t.summary.Len() == 1 {
-		if value < t.summary.Mean(0) {
-			return 0
-		}
+	} else if math.IsInf(value, -1) {
+		return 0
+	} else if math.IsInf(value, 1) {
 		return 1
+	} else if t.summary.Len() == 0 {
+		return math.NaN()
+	} else if t.summary.m2 != nil {
+		return t.cdfSpread(value)
 	}
 
-	// We have at least 2 centroids
-	left := (t.summary.Mean(1) - t.summary.Mean(0)) / 2
-	right := left
-	tot := 0.0
+	w := cdfWalk{t: t}
+	return w.cdf(value)
+}
 
-	for i := 1; i < t.summary.Len()-1; i++ {
-		prevMean := t.summary.Mean(i - 1)
-		if value < prevMean+right {
-			v := (tot + float64(t.summary.Count(i-1))*interpolate(value, prevMean-left, prevMean+right)) / float64(t.Count())
-			if v > 0 {
-				return v
-			}
-			return 0
+// cdfWalk computes the CDF of a non-empty digest without variances, keeping
+// its position among the centroids so that increasing values are evaluated
+// in a single pass.
+type cdfWalk struct {
+	t *TDigest
+	// i is the last centroid whose mean is below the values seen so far,
+	// or the first one, and tot the weight of the centroids before it.
+	i   int
+	tot float64
+}
+
+// seekCDFWalk returns a walk positioned for value without going through
+// the centroids before it.
+func (t *TDigest) seekCDFWalk(value float64) cdfWalk {
+	i := sort.SearchFloat64s(t.summary.means, value) - 1
+	if i < 0 {
+		i = 0
+	}
+	return cdfWalk{t: t, i: i, tot: t.summary.HeadSum(i)}
+}
+
+// cdf returns the CDF at value, which must not be lower than any value
+// previously given to the walk.
+func (w *cdfWalk) cdf(value float64) float64 {
+	_, weight := w.rank(value)
+	return (w.tot + weight) / float64(w.t.count)
+}
+
+// rank returns the weight at or below value as that of the centroids
+// before index plus weight.
+func (w *cdfWalk) rank(value float64) (index int, weight float64) {
+	t, s := w.t, w.t.summary
+	n := s.Len()
+	for w.i+1 < n && s.Mean(w.i+1) < value {
+		w.tot += float64(s.Count(w.i))
+		w.i++
+	}
+	first, last := s.Mean(0), s.Mean(n-1)
+
+	switch {
+	case math.IsNaN(value):
+		return w.i, math.NaN()
+	case math.IsInf(value, -1):
+		// CDF(-Inf) is 0 even with samples at -Inf
+		return w.i, -w.tot
+	case math.IsInf(value, 1):
+		return w.i, float64(t.count) - w.tot
+	case value < t.min:
+		return w.i, 0
+	case value > t.max:
+		return w.i, float64(s.Count(w.i))
+	case value < first:
+		// the smallest sample is a point at the minimum, the rest of the
+		// first half of the first centroid is spread up to its mean
+		if value == t.min {
+			return w.i, 0.5
 		}
+		return w.i, 1 + interpolate(value, t.min, first)*(float64(s.Count(0))/2-1)
+	case value > last:
+		c := float64(s.Count(n - 1))
+		if value == t.max {
+			return w.i, c - 0.5
+		}
+		return w.i, c - 1 - interpolate(value, t.max, last)*(c/2-1)
+	}
 
-		tot += float64(t.summary.Count(i - 1))
-		left = right
-		right = (t.summary.Mean(i+1) - t.summary.Mean(i)) / 2
+	// samples at value count for half
+	start, before := w.i, 0.0
+	if s.Mean(start) != value && start+1 < n && s.Mean(start+1) == value {
+		start, before = start+1, float64(s.Count(start))
+	}
+	if s.Mean(start) == value {
+		var equal float64
+		for j := start; j < n && s.Mean(j) == value; j++ {
+			equal += float64(s.Count(j))
+		}
+		return w.i, before + equal/2
 	}
 
-	// last centroid, the summary length is at least two
-	aIdx := t.summary.Len() - 2
-	aMean := t.summary.Mean(aIdx)
-	if value < aMean+right {
-		aCount := float64(t.summary.Count(aIdx))
-		return (tot + aCount*interpolate(value, aMean-left, aMean+right)) / float64(t.Count())
+	// value is strictly between the means of two centroids, whose halves
+	// facing each other are spread in between unless they are points
+	left, right := float64(s.Count(w.i)), float64(s.Count(w.i+1))
+	var leftExcluded, rightExcluded float64
+	if w.isPoint(w.i) {
+		leftExcluded = left / 2
 	}
-	return 1
+	if w.isPoint(w.i + 1) {
+		rightExcluded = right / 2
+	}
+	between := (left+right)/2 - leftExcluded - rightExcluded
+	return w.i, left/2 + leftExcluded + between*interpolate(value, s.Mean(w.i), s.Mean(w.i+1))
+}
+
+// isPoint reports whether all the samples of the centroid at index have
+// its mean, which is the case of single samples and of centroids at the
+// extremes.
+func (w *cdfWalk) isPoint(index int) bool {
+	mean := w.t.summary.Mean(index)
+	return w.t.summary.Count(index) == 1 || mean == w.t.min || mean == w.t.max
 }
 
 func interpolate(x, x0, x1 float64) float64 {
+	// a finite x is infinitely closer to a finite end than to an infinite one
+	switch inf0, inf1 := math.IsInf(x0, 0), math.IsInf(x1, 0); {
+	case inf0 && inf1:
+		return 0.5
+	case inf0:
+		return 1
+	case inf1:
+		return 0
+	}
 	return (x - x0) / (x1 - x0)
 }
 
@@ -285,9 +961,119 @@ func interpolate(x, x0, x1 float64) float64 {
 // Iteration stops when the supplied function returns false, or when all
 // centroids have been iterated.
 func (t *TDigest) ForEachCentroid(f func(mean float64, count uint32) bool) {
+	t.checkRead()
 	t.summary.ForEach(f)
 }
 
+// ForEachCentroidCumulative is like ForEachCentroid but also passes the
+// index of every centroid and the total weight of the centroids up to and
+// including it, which is the weight the tree used by Quantile and CDF holds
+// for them.
+func (t *TDigest) ForEachCentroidCumulative(f func(index int, mean float64, count uint32, cumulative uint64) bool) {
+	t.checkRead()
+
+	var cumulative uint64
+	for i := 0; i < t.summary.Len(); i++ {
+		cumulative += uint64(t.summary.Count(i))
+		if !f(i, t.summary.Mean(i), t.summary.Count(i), cumulative) {
+			return
+		}
+	}
+}
+
+// Centroids returns a copy of the centroids of the digest in increasing
+// order of mean. It is empty for an empty digest.
+func (t *TDigest) Centroids() []Centroid {
+	t.checkRead()
+
+	centroids := make([]Centroid, t.summary.Len())
+	for i := range centroids {
+		centroids[i] = Centroid{Mean: t.summary.Mean(i), Count: t.summary.Count(i)}
+	}
+	return centroids
+}
+
+// MaxCentroidWeightAt returns the largest weight a centroid covering the
+// quantile q is currently allowed to reach. The value depends on the number
+// of samples in the digest and on its compression, and is the limit used
+// when deciding whether new samples can be merged into an existing centroid.
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) MaxCentroidWeightAt(q float64) float64 {
+	if q < 0 || q > 1 {
+		panic("q must be between 0 and 1 (inclusive)")
+	}
+	t.checkRead()
+	return t.threshold(q)
+}
+
+// CentroidSpanAt returns the rank interval [loRank, hiRank) covered by the
+// centroid containing the rank q*Count(). The width of the interval is the
+// weight of that centroid. Both values are NaN for an empty digest.
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) CentroidSpanAt(q float64) (loRank, hiRank float64) {
+	if q < 0 || q > 1 {
+		panic("q must be between 0 and 1 (inclusive)")
+	}
+	t.checkRead()
+
+	if t.summary.Len() == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	index, cumSum := t.centroidAt(q)
+	return cumSum, cumSum + float64(t.summary.Count(index))
+}
+
+// CentroidAt returns the centroid containing the rank q*Count() along with
+// the rank interval [loRank, hiRank) it covers, which is useful to see what
+// a suspicious quantile estimate was derived from. It returns ErrEmptyDigest
+// for an empty digest and ErrInvalidQuantile if q is outside of [0, 1].
+func (t *TDigest) CentroidAt(q float64) (c Centroid, loRank, hiRank uint64, err error) {
+	if q < 0 || q > 1 || math.IsNaN(q) {
+		return Centroid{}, 0, 0, ErrInvalidQuantile
+	}
+	t.checkRead()
+
+	if t.summary.Len() == 0 {
+		return Centroid{}, 0, 0, ErrEmptyDigest
+	}
+
+	index, cumSum := t.centroidAt(q)
+	c = Centroid{Mean: t.summary.Mean(index), Count: t.summary.Count(index)}
+	return c, uint64(cumSum), uint64(cumSum) + uint64(c.Count), nil
+}
+
+// NearestCentroid returns the centroid whose mean is the closest to x, the
+// one with the lowest mean when several are equally close. This is where
+// inserting x would start looking for a centroid to merge it into. It
+// returns false for an empty digest or if x is NaN.
+func (t *TDigest) NearestCentroid(x float64) (Centroid, bool) {
+	t.checkRead()
+
+	if t.summary.Len() == 0 || math.IsNaN(x) {
+		return Centroid{}, false
+	}
+
+	begin := t.summary.Floor(x)
+	if begin == -1 {
+		begin = 0
+	}
+	closest, _ := t.findNeighbors(begin, x)
+	for closest > 0 && t.summary.Mean(closest-1) == t.summary.Mean(closest) {
+		closest--
+	}
+	return Centroid{Mean: t.summary.Mean(closest), Count: t.summary.Count(closest)}, true
+}
+
+// centroidAt returns the index of the centroid containing the rank
+// q*Count() and the total weight of the centroids before it. The digest
+// must not be empty.
+func (t *TDigest) centroidAt(q float64) (index int, cumSum float64) {
+	return t.summary.FloorSum(q * float64(t.count))
+}
+
 func (t TDigest) findNeighbors(start int, value float64) (int, int) {
 	minDistance := math.MaxFloat64
 	lastNeighbor := t.summary.Len()
@@ -304,7 +1090,7 @@ func (t TDigest) findNeighbors(start int, value float64) (int, int) {
 	return start, lastNeighbor
 }
 
-func (t TDigest) chooseMergeCandidate(begin, end int, value float64, count uint32) int {
+func (t *TDigest) chooseMergeCandidate(begin, end int, value float64, count uint32) int {
 	closest := t.summary.Len()
 	sum := t.summary.HeadSum(begin)
 	var n int
@@ -317,11 +1103,14 @@ func (t TDigest) chooseMergeCandidate(begin, end int, value float64, count uint3
 		} else {
 			q = (sum + (c-1)/2) / float64(t.count-1)
 		}
-		k := 4 * float64(t.count) * q * (1 - q) / t.compression
-
-		if c+float64(count) <= k {
+		if c+float64(count) <= t.threshold(q) && c+float64(count) <= math.MaxUint32 {
 			n++
-			if fastMod(t.pcg.Uint32(), n) == 0 {
+			if t.greedy {
+				// the lightest candidate, the first one on ties
+				if closest == t.summary.Len() || t.summary.Count(neighbor) < t.summary.Count(closest) {
+					closest = neighbor
+				}
+			} else if fastMod(t.pcg.Uint32(), n) == 0 {
 				closest = neighbor
 			}
 		}
@@ -330,13 +1119,11 @@ func (t TDigest) chooseMergeCandidate(begin, end int, value float64, count uint3
 	return closest
 }
 
-func shuffle(means []float64, counts []uint32) {
-	for i := len(means) - 1; i > 1; i-- {
-		j := rand.Intn(i + 1)
-		means[i], means[j], counts[i], counts[j] = means[j], means[i], counts[j], counts[i]
+// shuffle randomizes the order of the centroids of s using the generator of
+// the digest, which keeps math/rand and its global state out of the package.
+func shuffle(s *summary, rng *pcg) {
+	for i := s.Len() - 1; i > 1; i-- {
+		j := fastMod(rng.Uint32(), i+1)
+		s.Swap(i, j)
 	}
 }
-
-func estimateCapacity(compression float64) uint {
-	return uint(compression) * 10
-}
@@ -3,6 +3,7 @@ package tdigest
 import (
 	"math"
 	"math/rand"
+	"reflect"
 	"sort"
 	"testing"
 	"time"
@@ -16,6 +17,21 @@ func init() { rand.Seed(time.Now().UnixNano()) }
 // Since there's a random number call inside tdigest this breaks repeatability
 // for all tests. So, no test concurrency here.
 
+// optionVariants returns the options of the digests most tests run with,
+// the default ones, WithMaxCentroidBound and WithCentroidVariance, along
+// with the variants in extra, keyed by name.
+func optionVariants(extra map[string][]Option) map[string][]Option {
+	variants := map[string][]Option{
+		"default":  nil,
+		"bounded":  {WithMaxCentroidBound(100)},
+		"variance": {WithCentroidVariance()},
+	}
+	for name, opts := range extra {
+		variants[name] = opts
+	}
+	return variants
+}
+
 func TestTInternals(t *testing.T) {
 	tdigest := New(100)
 
@@ -186,6 +202,50 @@ func TestSingletonInACrowd(t *testing.T) {
 	}
 }
 
+func TestSingletonInACrowdCDF(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(10)
+	}
+	_ = tdigest.Add(20)
+	_ = tdigest.Compress()
+
+	// the outlier is a step of 1/count at 20, not a ramp between the means
+	n := float64(tdigest.Count())
+	for _, x := range []float64{10.001, 11, 12.5, 15, 17.5, 19, 19.999} {
+		if result := tdigest.CDF(x); result != (n-1)/n {
+			t.Errorf("Expected CDF(%v) = %v, but got %v", x, (n-1)/n, result)
+		}
+	}
+	if result := tdigest.CDF(20); result != (n-0.5)/n {
+		t.Errorf("Expected CDF(20) = %v, but got %v", (n-0.5)/n, result)
+	}
+	if result := tdigest.CDF(20.001); result != 1 {
+		t.Errorf("Expected CDF(20.001) = 1, but got %v", result)
+	}
+	if result := tdigest.CDF(9.999); result != 0 {
+		t.Errorf("Expected CDF(9.999) = 0, but got %v", result)
+	}
+}
+
+func TestSingletonCDF(t *testing.T) {
+	tdigest := New(100)
+	_ = tdigest.Add(5)
+	for x, expected := range map[float64]float64{4: 0, 5: 0.5, 6: 1} {
+		if result := tdigest.CDF(x); result != expected {
+			t.Errorf("Expected CDF(%v) = %v, but got %v", x, expected, result)
+		}
+	}
+
+	// two singletons are two steps
+	_ = tdigest.Add(7)
+	for x, expected := range map[float64]float64{5: 0.25, 5.5: 0.5, 6.5: 0.5, 7: 0.75, 8: 1} {
+		if result := tdigest.CDF(x); result != expected {
+			t.Errorf("Expected CDF(%v) = %v, but got %v", x, expected, result)
+		}
+	}
+}
+
 func TestRespectBounds(t *testing.T) {
 	tdigest := New(10)
 
@@ -194,7 +254,7 @@ func TestRespectBounds(t *testing.T) {
 		_ = tdigest.Add(f)
 	}
 
-	quantiles := []float64{0.01, 0.25, 0.5, 0.75, 0.999}
+	quantiles := []float64{0, 0.01, 0.25, 0.5, 0.75, 0.999, 1}
 	for _, q := range quantiles {
 		result := tdigest.Quantile(q)
 		if result < 0 {
@@ -204,6 +264,31 @@ func TestRespectBounds(t *testing.T) {
 			t.Errorf("q(%.3f) = %.4f > 281", q, result)
 		}
 	}
+
+	// once the extreme centroids hold several samples their means are
+	// inside the range, but the endpoints are still the exact extremes
+	for name, opts := range optionVariants(map[string][]Option{
+		"bounded": {WithMaxCentroidBound(10)},
+	}) {
+		tdigest := New(5, opts...)
+		min, max := math.Inf(1), math.Inf(-1)
+		for i := 0; i < 10000; i++ {
+			x := rand.ExpFloat64()
+			min, max = math.Min(min, x), math.Max(max, x)
+			_ = tdigest.Add(x)
+		}
+		_ = tdigest.Compress()
+
+		if tdigest.Quantile(0) != min || tdigest.Quantile(1) != max {
+			t.Errorf("%s: got endpoints %v and %v, expected %v and %v", name, tdigest.Quantile(0), tdigest.Quantile(1), min, max)
+		}
+		for i := 0; i <= 1000; i++ {
+			q := float64(i) / 1000
+			if result := tdigest.Quantile(q); result < min || result > max {
+				t.Errorf("%s: q(%.3f) = %v outside of [%v, %v]", name, q, result, min, max)
+			}
+		}
+	}
 }
 
 func TestWeights(t *testing.T) {
@@ -386,7 +471,7 @@ func shouldPanic(f func(), t *testing.T, message string) {
 	defer func() {
 		tryRecover := recover()
 		if tryRecover == nil {
-			t.Errorf(message)
+			t.Error(message)
 		}
 	}()
 	f()
@@ -404,6 +489,123 @@ func TestPanic(t *testing.T) {
 	}, t, "Quantile > 1 should panic!")
 }
 
+func TestTotalWeightBeyond32Bits(t *testing.T) {
+	for name, opts := range optionVariants(map[string][]Option{
+		"bounded": {WithMaxCentroidBound(10)},
+	}) {
+		tdigest := New(1, opts...)
+		for i := 0; i < 100; i++ {
+			assertNoError(t, tdigest.AddWeighted(float64(i), 50000000))
+		}
+		if tdigest.TotalWeight() != 5000000000 || tdigest.Count() != 5000000000 {
+			t.Fatalf("%s: got total weight %d, expected 5000000000", name, tdigest.TotalWeight())
+		}
+
+		var total uint64
+		tdigest.ForEachCentroidCumulative(func(index int, mean float64, count uint32, cumulative uint64) bool {
+			total += uint64(count)
+			if uint64(tdigest.summary.HeadSum(index+1)) != total {
+				t.Errorf("%s: got a cumulative weight of %v at %d, expected %d", name, tdigest.summary.HeadSum(index+1), index, total)
+			}
+			return true
+		})
+
+		for _, q := range []float64{0.1, 0.5, 0.9} {
+			if got := tdigest.Quantile(q); math.Abs(got-100*q) > 10 {
+				t.Errorf("%s: Quantile(%v) = %v, expected about %v", name, q, got, 100*q)
+			}
+			if got := tdigest.CDF(100 * q); math.Abs(got-q) > 0.1 {
+				t.Errorf("%s: CDF(%v) = %v, expected about %v", name, 100*q, got, q)
+			}
+		}
+
+		other := New(1, opts...)
+		assertNoError(t, other.Merge(tdigest))
+		assertNoError(t, other.Merge(tdigest))
+		if other.TotalWeight() != 10000000000 {
+			t.Errorf("%s: got total weight %d after merging, expected 10000000000", name, other.TotalWeight())
+		}
+	}
+}
+
+func TestCentroidCountsDoNotOverflow(t *testing.T) {
+	for name, opts := range optionVariants(map[string][]Option{
+		"bounded": {WithMaxCentroidBound(10)},
+	}) {
+		tdigest := New(1, opts...)
+		for i := 0; i < 3; i++ {
+			assertNoError(t, tdigest.AddWeighted(1, math.MaxUint32))
+			assertNoError(t, tdigest.AddWeighted(2, math.MaxUint32-1))
+		}
+		assertNoError(t, tdigest.Compress())
+		assertNoError(t, tdigest.Merge(tdigest.Clone()))
+
+		expected := uint64(12*math.MaxUint32 - 6)
+		var total uint64
+		tdigest.ForEachCentroid(func(mean float64, count uint32) bool {
+			total += uint64(count)
+			return true
+		})
+		if total != expected || tdigest.Count() != expected {
+			t.Errorf("%s: got centroids of %d samples and a count of %d, expected %d", name, total, tdigest.Count(), expected)
+		}
+		if tdigest.Quantile(0.25) != 1 || tdigest.Quantile(0.75) != 2 {
+			t.Errorf("%s: got quantiles %v and %v, expected 1 and 2", name, tdigest.Quantile(0.25), tdigest.Quantile(0.75))
+		}
+
+		decoded, err := FromBytes(tdigest.Marshal(nil))
+		assertNoError(t, err)
+		if decoded.Count() != expected {
+			t.Errorf("%s: got a count of %d after decoding, expected %d", name, decoded.Count(), expected)
+		}
+	}
+}
+
+func TestAddWeightedSplitsLargeCounts(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5b1))
+	tdigest := New(100)
+	var samples []float64
+	for i := 0; i < 100000; i++ {
+		if i%10000 == 0 {
+			x := rng.Float64()
+			assertNoError(t, tdigest.AddWeighted(x, 100000))
+			for j := 0; j < 100000; j++ {
+				samples = append(samples, x)
+			}
+		}
+		x := rng.Float64()
+		assertNoError(t, tdigest.Add(x))
+		samples = append(samples, x)
+	}
+	sort.Float64s(samples)
+
+	if tdigest.Count() != uint64(len(samples)) {
+		t.Fatalf("got count %d, expected %d", tdigest.Count(), len(samples))
+	}
+	for _, c := range tdigest.Centroids() {
+		if limit := 2 * tdigest.MaxCentroidWeightAt(0.5); float64(c.Count) > limit {
+			t.Errorf("got a centroid of %d samples at %v, expected at most %v", c.Count, c.Mean, limit)
+		}
+	}
+	for _, q := range []float64{0.1, 0.5, 0.9} {
+		// the samples equal to x span the ranks [lo, hi]
+		x := tdigest.Quantile(q)
+		lo := float64(sort.SearchFloat64s(samples, x)) / float64(len(samples))
+		hi := float64(sort.SearchFloat64s(samples, math.Nextafter(x, math.Inf(1)))) / float64(len(samples))
+		if q < lo-0.01 || q > hi+0.01 {
+			t.Errorf("Quantile(%v) = %v has ranks [%v, %v]", q, x, lo, hi)
+		}
+	}
+
+	// digests too small to merge anything keep the samples together
+	small := New(100)
+	assertNoError(t, small.AddWeighted(1, 3))
+	assertNoError(t, small.AddWeighted(2, 3))
+	if n := small.summary.Len(); n != 2 {
+		t.Errorf("got %d centroids for a small digest, expected 2", n)
+	}
+}
+
 func TestForEachCentroid(t *testing.T) {
 	tdigest := New(10)
 
@@ -432,6 +634,130 @@ func TestForEachCentroid(t *testing.T) {
 	}
 }
 
+func TestForEachCentroidCumulative(t *testing.T) {
+	tdigest := New(10)
+	for i := 0; i < 1000; i++ {
+		_ = tdigest.AddWeighted(rand.Float64(), uint32(rand.Intn(10)+1))
+	}
+
+	calls := 0
+	tdigest.ForEachCentroidCumulative(func(index int, mean float64, count uint32, cumulative uint64) bool {
+		if index != calls {
+			t.Errorf("got index %d, expected %d", index, calls)
+		}
+		if mean != tdigest.summary.Mean(index) || count != tdigest.summary.Count(index) {
+			t.Errorf("got centroid (%v, %d) at %d, expected (%v, %d)", mean, count, index, tdigest.summary.Mean(index), tdigest.summary.Count(index))
+		}
+		if expected := uint64(tdigest.summary.HeadSum(index + 1)); cumulative != expected {
+			t.Errorf("got cumulative weight %d at %d, expected %d", cumulative, index, expected)
+		}
+		calls++
+		return true
+	})
+	if calls != tdigest.summary.Len() {
+		t.Errorf("got %d calls, expected %d", calls, tdigest.summary.Len())
+	}
+
+	var last uint64
+	calls = 0
+	tdigest.ForEachCentroidCumulative(func(index int, mean float64, count uint32, cumulative uint64) bool {
+		calls++
+		last = cumulative
+		return calls != 3
+	})
+	if calls != 3 {
+		t.Errorf("ForEachCentroidCumulative did not stop after 3 calls, got %d", calls)
+	}
+	if last == tdigest.Count() {
+		t.Errorf("Expected a partial cumulative weight after stopping early")
+	}
+
+	New(10).ForEachCentroidCumulative(func(int, float64, uint32, uint64) bool {
+		t.Errorf("Expected no calls for an empty digest")
+		return true
+	})
+}
+
+func TestCentroids(t *testing.T) {
+	if centroids := New(10).Centroids(); centroids == nil || len(centroids) != 0 {
+		t.Errorf("Expected no centroids for an empty digest, got %v", centroids)
+	}
+
+	tdigest := New(10)
+	for i := 0; i < 1000; i++ {
+		_ = tdigest.Add(rand.Float64())
+	}
+
+	var expected []Centroid
+	tdigest.ForEachCentroid(func(mean float64, count uint32) bool {
+		expected = append(expected, Centroid{Mean: mean, Count: count})
+		return true
+	})
+	centroids := tdigest.Centroids()
+	if !reflect.DeepEqual(centroids, expected) {
+		t.Fatalf("got centroids %v, expected %v", centroids, expected)
+	}
+	for i := 1; i < len(centroids); i++ {
+		if centroids[i].Mean < centroids[i-1].Mean {
+			t.Errorf("centroids out of order at %d", i)
+		}
+	}
+
+	// the copy is not the digest
+	fingerprint := tdigest.Fingerprint()
+	for i := range centroids {
+		centroids[i] = Centroid{Mean: -1, Count: 1e6}
+	}
+	if tdigest.Fingerprint() != fingerprint {
+		t.Errorf("Modifying the centroids modified the digest")
+	}
+}
+
+func TestAddCentroid(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rand.NormFloat64())
+	}
+
+	rebuilt := New(100)
+	for _, c := range tdigest.Centroids() {
+		if err := rebuilt.AddCentroid(c.Mean, c.Count); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rebuilt.Count() != tdigest.Count() {
+		t.Errorf("got count %d, expected %d", rebuilt.Count(), tdigest.Count())
+	}
+	if rebuilt.Min() != tdigest.Centroids()[0].Mean {
+		t.Errorf("got min %v, expected the smallest mean", rebuilt.Min())
+	}
+	for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+		got, expected := rebuilt.Quantile(q), tdigest.Quantile(q)
+		if math.Abs(got-expected) > 0.01 {
+			t.Errorf("q=%v: got %v, expected %v", q, got, expected)
+		}
+	}
+
+	// the centroid is not merged into its neighbor
+	small := New(100)
+	_ = small.AddCentroid(1, 10)
+	_ = small.AddCentroid(1.5, 3)
+	expected := []Centroid{{Mean: 1, Count: 10}, {Mean: 1.5, Count: 3}}
+	if centroids := small.Centroids(); !reflect.DeepEqual(centroids, expected) {
+		t.Errorf("got centroids %v, expected %v", centroids, expected)
+	}
+
+	if err := small.AddCentroid(math.NaN(), 1); err != ErrInvalidValue {
+		t.Errorf("got %v for a NaN mean, expected ErrInvalidValue", err)
+	}
+	if err := small.AddCentroid(2, 0); err == nil {
+		t.Errorf("expected an error for a zero count")
+	}
+	if small.Count() != 13 {
+		t.Errorf("rejected centroids changed the count to %d", small.Count())
+	}
+}
+
 func benchmarkAdd(compression float64, b *testing.B) {
 	t := New(compression)
 
@@ -462,3 +788,555 @@ func BenchmarkAdd10(b *testing.B) {
 func BenchmarkAdd100(b *testing.B) {
 	benchmarkAdd(100, b)
 }
+
+func benchmarkAddOrdered(value func(n, total int) float64, b *testing.B) {
+	t := New(100)
+
+	data := make([]float64, b.N)
+	for n := 0; n < b.N; n++ {
+		data[n] = value(n, b.N)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		err := t.Add(data[n])
+		if err != nil {
+			b.Error(err)
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkAddAscending(b *testing.B) {
+	benchmarkAddOrdered(func(n, total int) float64 { return float64(n) }, b)
+}
+
+func BenchmarkAddDescending(b *testing.B) {
+	benchmarkAddOrdered(func(n, total int) float64 { return float64(total - n) }, b)
+}
+
+func TestSmallCompression(t *testing.T) {
+	for _, compression := range []float64{0.1, 0.5, 1, -1, math.NaN()} {
+		tdigest := New(compression)
+		if tdigest.compression != 1 {
+			t.Errorf("compression=%v: expected it to be treated as 1, got %v", compression, tdigest.compression)
+		}
+
+		for i := 0; i < 10000; i++ {
+			assertNoError(t, tdigest.Add(rand.Float64()))
+			if tdigest.summary.Len() > 20 {
+				t.Fatalf("compression=%v: grew to %d centroids", compression, tdigest.summary.Len())
+			}
+		}
+
+		before := tdigest.summary.Len()
+		assertNoError(t, tdigest.Compress())
+		if tdigest.summary.Len() > before || tdigest.Count() != 10000 {
+			t.Errorf("compression=%v: compressed %d centroids to %d and %d samples",
+				compression, before, tdigest.summary.Len(), tdigest.Count())
+		}
+
+		previous := math.Inf(-1)
+		for _, q := range []float64{0, 0.01, 0.25, 0.5, 0.75, 0.99, 1} {
+			v := tdigest.Quantile(q)
+			if v < previous || v < -0.5 || v > 1.5 {
+				t.Errorf("compression=%v: Quantile(%.2f) = %v", compression, q, v)
+			}
+			previous = v
+		}
+		// a handful of centroids only gives a rough idea of the median
+		if median := tdigest.Quantile(0.5); math.Abs(median-0.5) > 0.25 {
+			t.Errorf("compression=%v: median %v", compression, median)
+		}
+	}
+}
+
+func TestMaxCentroidWeightAt(t *testing.T) {
+	tdigest := New(50)
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rand.Float64())
+	}
+
+	for _, q := range []float64{0, 0.001, 0.1, 0.5, 0.9, 1} {
+		exp := 4 * float64(tdigest.Count()) * q * (1 - q) / 50
+		if got := tdigest.MaxCentroidWeightAt(q); !closeEnough(got, exp) {
+			t.Errorf("MaxCentroidWeightAt(%.3f) = %.4f, expected %.4f", q, got, exp)
+		}
+		if got := tdigest.MaxCentroidWeightAt(q); got != tdigest.threshold(q) {
+			t.Errorf("MaxCentroidWeightAt(%.3f) = %.4f differs from the internal threshold", q, got)
+		}
+	}
+
+	shouldPanic(func() {
+		tdigest.MaxCentroidWeightAt(1.5)
+	}, t, "MaxCentroidWeightAt > 1 should panic!")
+}
+
+func TestCentroidSpanAt(t *testing.T) {
+	tdigest := New(50)
+
+	if lo, hi := tdigest.CentroidSpanAt(0.5); !math.IsNaN(lo) || !math.IsNaN(hi) {
+		t.Errorf("CentroidSpanAt() on an empty digest should return NaN. Got: %.4f, %.4f", lo, hi)
+	}
+
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rand.Float64())
+	}
+	count := float64(tdigest.Count())
+
+	// walk the spans from the left, each one should start where the previous
+	// one ended and together they should cover the whole count
+	var total float64
+	spans := 0
+	for total < count {
+		lo, hi := tdigest.CentroidSpanAt((total + 0.5) / count)
+		if lo != total {
+			t.Fatalf("span starting at %.0f, expected %.0f", lo, total)
+		}
+		if hi <= lo {
+			t.Fatalf("empty span [%.0f, %.0f)", lo, hi)
+		}
+		total = hi
+		spans++
+	}
+
+	if total != count {
+		t.Errorf("spans sum to %.0f, expected %.0f", total, count)
+	}
+	if spans != tdigest.summary.Len() {
+		t.Errorf("walked %d spans, expected %d", spans, tdigest.summary.Len())
+	}
+
+	lo, hi := tdigest.CentroidSpanAt(1)
+	if hi != count || lo != count-float64(tdigest.summary.Count(tdigest.summary.Len()-1)) {
+		t.Errorf("CentroidSpanAt(1) = [%.0f, %.0f), expected the last centroid", lo, hi)
+	}
+}
+
+func TestQuantileE(t *testing.T) {
+	tdigest := New(100, WithMinSamples(10))
+
+	for _, q := range []float64{0, 0.5, 1} {
+		if x, err := tdigest.QuantileE(q); err != ErrEmptyDigest || !math.IsNaN(x) {
+			t.Errorf("QuantileE(%v) = %v, %v on an empty digest, expected NaN, ErrEmptyDigest", q, x, err)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, tdigest.Add(float64(i)))
+	}
+
+	for _, q := range []float64{-0.1, 1.1, math.Inf(-1), math.Inf(1), math.NaN()} {
+		if x, err := tdigest.QuantileE(q); err != ErrInvalidQuantile || !math.IsNaN(x) {
+			t.Errorf("QuantileE(%v) = %v, %v, expected NaN, ErrInvalidQuantile", q, x, err)
+		}
+	}
+	if x, err := tdigest.QuantileE(0.001); err != ErrTooFewSamples || !math.IsNaN(x) {
+		t.Errorf("QuantileE(0.001) = %v, %v, expected NaN, ErrTooFewSamples", x, err)
+	}
+
+	for _, q := range []float64{0.01, 0.5, 0.99} {
+		x, err := tdigest.QuantileE(q)
+		assertNoError(t, err)
+		if x != tdigest.Quantile(q) {
+			t.Errorf("QuantileE(%v) = %v, Quantile returned %v", q, x, tdigest.Quantile(q))
+		}
+	}
+
+	shouldPanic(func() { tdigest.Quantile(-0.1) }, t, "Quantile(-0.1) should panic")
+	shouldPanic(func() { tdigest.Quantile(1.1) }, t, "Quantile(1.1) should panic")
+	if x := tdigest.Quantile(math.NaN()); !math.IsNaN(x) {
+		t.Errorf("Quantile(NaN) = %v, expected NaN", x)
+	}
+}
+
+func TestCDFE(t *testing.T) {
+	tdigest := New(100, WithMinSamples(10))
+
+	if c, err := tdigest.CDFE(0); err != ErrEmptyDigest || !math.IsNaN(c) {
+		t.Errorf("CDFE(0) = %v, %v on an empty digest, expected NaN, ErrEmptyDigest", c, err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, tdigest.Add(float64(i)))
+	}
+
+	for _, x := range []float64{-1, 0, 999, 1000} {
+		if c, err := tdigest.CDFE(x); err != ErrTooFewSamples || !math.IsNaN(c) {
+			t.Errorf("CDFE(%v) = %v, %v, expected NaN, ErrTooFewSamples", x, c, err)
+		}
+	}
+	for _, x := range []float64{10.5, 500, 900} {
+		c, err := tdigest.CDFE(x)
+		assertNoError(t, err)
+		if c != tdigest.CDF(x) {
+			t.Errorf("CDFE(%v) = %v, CDF returned %v", x, c, tdigest.CDF(x))
+		}
+	}
+}
+
+func TestCDFInfinities(t *testing.T) {
+	inf := math.Inf(1)
+	digests := map[string]*TDigest{
+		"empty":         New(100),
+		"single":        New(100),
+		"single weight": New(100),
+		"populated":     New(100),
+		"variance":      New(100, WithCentroidVariance()),
+		"min samples":   New(100, WithMinSamples(10)),
+		"infinite":      New(100, WithInfiniteValues()),
+	}
+	assertNoError(t, digests["single"].Add(1))
+	assertNoError(t, digests["single weight"].AddWeighted(1, 10))
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, digests["populated"].Add(rand.NormFloat64()))
+		assertNoError(t, digests["variance"].Add(rand.NormFloat64()))
+		assertNoError(t, digests["min samples"].Add(rand.NormFloat64()))
+	}
+	assertNoError(t, digests["infinite"].AddWeighted(-inf, 3))
+	assertNoError(t, digests["infinite"].AddWeighted(0, 3))
+	assertNoError(t, digests["infinite"].AddWeighted(inf, 3))
+
+	for name, tdigest := range digests {
+		if c := tdigest.CDF(-inf); c != 0 {
+			t.Errorf("%s: CDF(-Inf) = %v, expected 0", name, c)
+		}
+		if c := tdigest.CDF(inf); c != 1 {
+			t.Errorf("%s: CDF(+Inf) = %v, expected 1", name, c)
+		}
+		if c := tdigest.CDF(math.NaN()); !math.IsNaN(c) {
+			t.Errorf("%s: CDF(NaN) = %v, expected NaN", name, c)
+		}
+
+		if c, err := tdigest.CDFE(-inf); c != 0 || err != nil {
+			t.Errorf("%s: CDFE(-Inf) = %v, %v, expected 0", name, c, err)
+		}
+		if c, err := tdigest.CDFE(inf); c != 1 || err != nil {
+			t.Errorf("%s: CDFE(+Inf) = %v, %v, expected 1", name, c, err)
+		}
+		if c, err := tdigest.CDFE(math.NaN()); !math.IsNaN(c) || err != ErrInvalidValue {
+			t.Errorf("%s: CDFE(NaN) = %v, %v, expected NaN, ErrInvalidValue", name, c, err)
+		}
+
+		cs := tdigest.CDFs([]float64{-inf, inf})
+		if cs[0] != 0 || cs[1] != 1 {
+			t.Errorf("%s: CDFs(-Inf, +Inf) = %v, expected [0 1]", name, cs)
+		}
+		if cs := tdigest.CDFs([]float64{inf, math.NaN()}); cs[0] != 1 || !math.IsNaN(cs[1]) {
+			t.Errorf("%s: CDFs(+Inf, NaN) = %v, expected [1 NaN]", name, cs)
+		}
+
+		if n := tdigest.CountBetween(-inf, inf); n != tdigest.Count() {
+			t.Errorf("%s: CountBetween(-Inf, +Inf) = %d, expected %d", name, n, tdigest.Count())
+		}
+		if n := tdigest.CountBetween(math.NaN(), inf); n != 0 {
+			t.Errorf("%s: CountBetween(NaN, +Inf) = %d, expected 0", name, n)
+		}
+	}
+
+	// finite values are infinitely far from the samples at ±Inf
+	infinite := digests["infinite"]
+	for _, x := range []float64{-1e300, -1, 0, 1, 1e300} {
+		if c := infinite.CDF(x); !(c >= 0.5-1.0/6 && c <= 0.5+1.0/6) {
+			t.Errorf("CDF(%v) = %v, expected the weight at -Inf and around 0", x, c)
+		}
+	}
+	if n := infinite.CountBetween(-inf, -1) + infinite.CountBetween(-1, 1) + infinite.CountBetween(1, inf); n != 9 {
+		t.Errorf("Expected the ranges to add up to 9 samples, got %d", n)
+	}
+}
+
+func TestNearestCentroid(t *testing.T) {
+	tdigest := New(100)
+	if _, ok := tdigest.NearestCentroid(1); ok {
+		t.Errorf("Expected no centroid in an empty digest")
+	}
+
+	for _, x := range []float64{1, 3, 3, 3, 6} {
+		assertNoError(t, tdigest.AddWeighted(x, 1000))
+	}
+	for _, test := range []struct {
+		x    float64
+		mean float64
+	}{
+		{-10, 1}, {1, 1}, {2, 1}, {2.5, 3}, {3, 3}, {4.5, 3}, {5, 6}, {100, 6},
+	} {
+		c, ok := tdigest.NearestCentroid(test.x)
+		if !ok || c.Mean != test.mean {
+			t.Errorf("NearestCentroid(%v) = %v, %v, expected the centroid at %v", test.x, c, ok, test.mean)
+		}
+	}
+	if _, ok := tdigest.NearestCentroid(math.NaN()); ok {
+		t.Errorf("Expected no centroid for NaN")
+	}
+
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, tdigest.Add(rand.NormFloat64()))
+	}
+	for i := 0; i < 1000; i++ {
+		x := 4 * rand.NormFloat64()
+		c, ok := tdigest.NearestCentroid(x)
+
+		best := 0
+		for j := 1; j < tdigest.summary.Len(); j++ {
+			if math.Abs(tdigest.summary.Mean(j)-x) < math.Abs(tdigest.summary.Mean(best)-x) {
+				best = j
+			}
+		}
+		expected := Centroid{Mean: tdigest.summary.Mean(best), Count: tdigest.summary.Count(best)}
+		if !ok || c != expected {
+			t.Fatalf("NearestCentroid(%v) = %v, expected %v", x, c, expected)
+		}
+	}
+}
+
+func TestCentroidAt(t *testing.T) {
+	tdigest := New(100)
+
+	if _, _, _, err := tdigest.CentroidAt(0.5); err != ErrEmptyDigest {
+		t.Errorf("Expected ErrEmptyDigest, got %v", err)
+	}
+
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rand.NormFloat64())
+	}
+
+	for _, q := range []float64{-0.1, 1.1, math.NaN()} {
+		if _, _, _, err := tdigest.CentroidAt(q); err != ErrInvalidQuantile {
+			t.Errorf("CentroidAt(%v): expected ErrInvalidQuantile, got %v", q, err)
+		}
+	}
+
+	for q := 0.0; q <= 1; q += 0.001 {
+		c, lo, hi, err := tdigest.CentroidAt(q)
+		assertNoError(t, err)
+
+		rank := q * float64(tdigest.Count())
+		if float64(lo) > rank || float64(hi) < rank || hi-lo != uint64(c.Count) {
+			t.Fatalf("CentroidAt(%.3f) = %v [%d, %d) does not contain rank %.2f", q, c, lo, hi, rank)
+		}
+
+		// the quantile is interpolated between the neighbors of the centroid
+		index := tdigest.summary.FindIndex(c.Mean)
+		lower, upper := math.Inf(-1), math.Inf(1)
+		if index > 0 {
+			lower = tdigest.summary.Mean(index - 1)
+		}
+		if index+1 < tdigest.summary.Len() {
+			upper = tdigest.summary.Mean(index + 1)
+		}
+		if v := tdigest.Quantile(q); v < lower || v > upper {
+			t.Fatalf("Quantile(%.3f) = %.4f outside of the neighbors [%.4f, %.4f]", q, v, lower, upper)
+		}
+	}
+}
+
+func TestMergeDestructive(t *testing.T) {
+	shard := func(seed int64) *TDigest {
+		rng := rand.New(rand.NewSource(seed))
+		d := New(100)
+		for i := 0; i < 1000; i++ {
+			assertNoError(t, d.Add(rng.Float64()))
+		}
+		return d
+	}
+
+	// an empty receiver takes over the centroids
+	other := shard(1)
+	expected := other.Quantile(0.5)
+	dist := New(100)
+	assertNoError(t, dist.MergeDestructive(other))
+	if dist.Count() != 1000 || dist.Quantile(0.5) != expected {
+		t.Errorf("Expected the digest to be taken over. Count %d, median %v != %v", dist.Count(), dist.Quantile(0.5), expected)
+	}
+	if other.Count() != 0 || other.summary.Len() != 0 {
+		t.Errorf("Expected the merged digest to be empty. Count %d, Len %d", other.Count(), other.summary.Len())
+	}
+
+	// a non empty receiver merges them
+	reference := New(100)
+	assertNoError(t, reference.Merge(dist))
+	second := shard(2)
+	assertNoError(t, reference.Merge(second))
+	assertNoError(t, dist.MergeDestructive(second))
+	if dist.Count() != reference.Count() {
+		t.Errorf("Expected MergeDestructive to keep every sample. %d != %d", dist.Count(), reference.Count())
+	}
+	if math.Abs(dist.Quantile(0.5)-reference.Quantile(0.5)) > 0.01 {
+		t.Errorf("Expected MergeDestructive to match Merge. %v != %v", dist.Quantile(0.5), reference.Quantile(0.5))
+	}
+	if second.Count() != 0 || second.summary.Len() != 0 {
+		t.Errorf("Expected the merged digest to be empty. Count %d, Len %d", second.Count(), second.summary.Len())
+	}
+
+	// the emptied digest is usable again
+	assertNoError(t, second.Add(3))
+	if second.Count() != 1 || second.Quantile(0.5) != 3 {
+		t.Errorf("Expected the emptied digest to be reusable")
+	}
+
+	// a digest with a different compression is not taken over
+	small := New(10)
+	assertNoError(t, small.MergeDestructive(shard(3)))
+	if small.compression != 10 || small.Count() != 1000 {
+		t.Errorf("Expected the receiver to keep its compression. Got %v with %d samples", small.compression, small.Count())
+	}
+
+	// merging into itself doubles the samples
+	self := shard(4)
+	assertNoError(t, self.MergeDestructive(self))
+	if self.Count() != 2000 {
+		t.Errorf("Expected merging into itself to double the count. Got %d", self.Count())
+	}
+}
+
+func TestMergeDestructiveFanIn(t *testing.T) {
+	for name, opts := range optionVariants(nil) {
+		rng := rand.New(rand.NewSource(0xfa))
+		copied, consumed := New(100, opts...), New(100, opts...)
+		for i := 0; i < 100; i++ {
+			shard := New(100, opts...)
+			for j := 0; j < 1000; j++ {
+				assertNoError(t, shard.Add(rng.NormFloat64()))
+			}
+			assertNoError(t, copied.Merge(shard))
+			assertNoError(t, consumed.MergeDestructive(shard))
+			if shard.Count() != 0 || shard.summary.Len() != 0 {
+				t.Fatalf("%s: expected the shard to be empty, got %d samples", name, shard.Count())
+			}
+		}
+
+		if consumed.Count() != copied.Count() || consumed.Min() != copied.Min() || consumed.Max() != copied.Max() {
+			t.Errorf("%s: got %d samples in [%v, %v], expected %d in [%v, %v]", name,
+				consumed.Count(), consumed.Min(), consumed.Max(), copied.Count(), copied.Min(), copied.Max())
+		}
+		for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99} {
+			if got, expected := consumed.Quantile(q), copied.Quantile(q); math.Abs(got-expected) > 0.02 {
+				t.Errorf("%s: Quantile(%v) = %v, expected %v", name, q, got, expected)
+			}
+		}
+	}
+}
+
+func TestMergeEdgeCases(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, tdigest.Add(rand.NormFloat64()))
+	}
+	fingerprint := tdigest.Fingerprint()
+
+	// a nil digest is empty
+	assertNoError(t, tdigest.Merge(nil))
+	assertNoError(t, tdigest.MergeDestructive(nil))
+	assertNoError(t, tdigest.Merge(New(100)))
+	if tdigest.Fingerprint() != fingerprint {
+		t.Errorf("Merging an empty digest changed the digest")
+	}
+
+	// an empty receiver copies the centroids
+	empty := New(100)
+	assertNoError(t, empty.Merge(tdigest))
+	if !reflect.DeepEqual(empty.Centroids(), tdigest.Centroids()) || empty.Count() != tdigest.Count() ||
+		empty.Min() != tdigest.Min() || empty.Max() != tdigest.Max() {
+		t.Errorf("Expected merging into an empty digest to copy it")
+	}
+	assertNoError(t, empty.Add(100))
+	if tdigest.Fingerprint() != fingerprint {
+		t.Errorf("The copy shares its centroids with the merged digest")
+	}
+
+	// merging into itself is merging an identical digest
+	twice, copied := tdigest.Clone(), tdigest.Clone()
+	assertNoError(t, twice.Merge(twice))
+	assertNoError(t, copied.Merge(tdigest))
+	if twice.Count() != 2000 || copied.Count() != 2000 {
+		t.Errorf("Expected merging a digest into itself to double the count. Got %d and %d", twice.Count(), copied.Count())
+	}
+	if math.Abs(twice.Quantile(0.5)-tdigest.Quantile(0.5)) > 0.05 {
+		t.Errorf("Expected merging a digest into itself to keep the median %v, got %v", tdigest.Quantile(0.5), twice.Quantile(0.5))
+	}
+}
+
+func TestMergeDeterministic(t *testing.T) {
+	a, b := New(100), New(100)
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, a.Add(rand.NormFloat64()))
+		assertNoError(t, b.Add(rand.ExpFloat64()))
+	}
+
+	first, second := a.Clone(), New(100, WithSeed(42))
+	assertNoError(t, second.Merge(a))
+	assertNoError(t, first.Merge(b))
+	assertNoError(t, second.Merge(b))
+	if !reflect.DeepEqual(first.Centroids(), second.Centroids()) {
+		t.Errorf("Expected merging the same digests to give the same centroids")
+	}
+
+	// the merge keeps the centroids sorted and within the bound of compress
+	compressed := first.Clone()
+	compressed.Compress()
+	if n, max := first.summary.Len(), 2*compressed.summary.Len(); n > max {
+		t.Errorf("Expected at most %d centroids after merging, got %d", max, n)
+	}
+	for i := 1; i < first.summary.Len(); i++ {
+		if first.summary.Mean(i-1) > first.summary.Mean(i) {
+			t.Fatalf("Centroids out of order at %d", i)
+		}
+	}
+}
+
+func benchmarkMergeShards(merge func(t, other *TDigest) error, numShards int, b *testing.B) {
+	shards := make([]*TDigest, numShards)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		for i := range shards {
+			shards[i] = New(100)
+			for j := 0; j < 100; j++ {
+				_ = shards[i].Add(rand.Float64())
+			}
+		}
+		b.StartTimer()
+
+		t := New(100)
+		for _, shard := range shards {
+			if err := merge(t, shard); err != nil {
+				b.Error(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMergeShards(b *testing.B) {
+	benchmarkMergeShards((*TDigest).Merge, 1000, b)
+}
+
+func BenchmarkMergeDestructiveShards(b *testing.B) {
+	benchmarkMergeShards((*TDigest).MergeDestructive, 1000, b)
+}
+
+func BenchmarkMerge100Shards(b *testing.B) {
+	benchmarkMergeShards((*TDigest).Merge, 100, b)
+}
+
+func BenchmarkMergeDestructive100Shards(b *testing.B) {
+	benchmarkMergeShards((*TDigest).MergeDestructive, 100, b)
+}
+
+func BenchmarkMergeTwo1000Centroids(b *testing.B) {
+	// a compression of 100 gives about 1000 centroids for 100000 samples
+	left, right := New(100), New(100)
+	for i := 0; i < 100000; i++ {
+		_ = left.Add(rand.NormFloat64())
+		_ = right.Add(rand.NormFloat64() + 1)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		t := left.Clone()
+		if err := t.Merge(right); err != nil {
+			b.Error(err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package tdigest
+
+import "math"
+
+// thresholdHysteresis is the fraction by which the mass above a threshold
+// must fall below its limit before the callback can fire again.
+const thresholdHysteresis = 0.1
+
+type thresholdCallback struct {
+	value  float64
+	limit  float64
+	fn     func(currentCDF float64)
+	firing bool
+}
+
+// OnThreshold registers fn to be called when more than 1-quantileLimit of
+// the mass of the digest lies above value, that is when the quantileLimit
+// quantile rises above value. It is checked after every method that adds,
+// removes, merges, transforms or compresses samples, and fn is called
+// synchronously with the current fraction of the mass at or below value.
+//
+// fn only fires on the rising edge: it is not called again until the mass
+// above value has fallen below 90% of its limit and then exceeded it again,
+// which keeps a digest hovering around the limit from flapping. Only the
+// means of the centroids are compared to value, so every check is a binary
+// search and a prefix sum.
+//
+// It panics if value is NaN, if quantileLimit is not strictly between 0 and
+// 1, or if fn is nil.
+func (t *TDigest) OnThreshold(value float64, quantileLimit float64, fn func(currentCDF float64)) {
+	if math.IsNaN(value) {
+		panic("value must not be NaN")
+	}
+	if !(quantileLimit > 0 && quantileLimit < 1) {
+		panic("quantileLimit must be between 0 and 1 (exclusive)")
+	}
+	if fn == nil {
+		panic("fn must not be nil")
+	}
+
+	t.beginWrite()
+	defer t.endWrite()
+
+	t.thresholds = append(t.thresholds, &thresholdCallback{
+		value: value,
+		limit: quantileLimit,
+		fn:    fn,
+	})
+}
+
+// checkThresholds fires the callbacks of the thresholds just crossed.
+func (t *TDigest) checkThresholds() {
+	if t.count == 0 {
+		return
+	}
+
+	for _, th := range t.thresholds {
+		cdf := t.summary.HeadSum(t.summary.FindInsertionIndex(th.value)) / float64(t.count)
+		tail, allowed := 1-cdf, 1-th.limit
+
+		switch {
+		case !th.firing && tail > allowed:
+			th.firing = true
+			th.fn(cdf)
+		case th.firing && tail < allowed*(1-thresholdHysteresis):
+			th.firing = false
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package tdigest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestOnThreshold(t *testing.T) {
+	tdigest := New(100)
+
+	var fired []uint64
+	var cdfs []float64
+	tdigest.OnThreshold(100, 0.9, func(cdf float64) {
+		fired = append(fired, tdigest.Count())
+		cdfs = append(cdfs, cdf)
+	})
+
+	add := func(value float64, n int) {
+		for i := 0; i < n; i++ {
+			assertNoError(t, tdigest.Add(value))
+		}
+	}
+
+	// 12 slow samples out of 112 are the first to exceed 10%
+	add(1, 100)
+	add(200, 11)
+	if len(fired) != 0 {
+		t.Fatalf("Fired too early at %v", fired)
+	}
+	add(200, 1)
+	if len(fired) != 1 || fired[0] != 112 {
+		t.Fatalf("Expected to fire at 112 samples, fired at %v", fired)
+	}
+	if cdfs[0] != 100.0/112 {
+		t.Errorf("Expected a CDF of %v, got %v", 100.0/112, cdfs[0])
+	}
+
+	// staying above the limit does not fire again, and neither does dipping
+	// below it without clearing the hysteresis: 22 slow samples out of 225
+	// are 9.8%, 23 out of 226 are 10.2%
+	add(200, 10)
+	add(1, 103)
+	add(200, 1)
+	if len(fired) != 1 {
+		t.Fatalf("Fired again at %v", fired)
+	}
+
+	// 23 slow samples out of 256 are 8.98%, re-arming the callback, then 25
+	// out of 258 are 9.7% and 26 out of 259 are 10.04%
+	add(1, 30)
+	add(200, 2)
+	if len(fired) != 1 {
+		t.Fatalf("Fired again at %v", fired)
+	}
+	add(200, 1)
+	if len(fired) != 2 || fired[1] != 259 {
+		t.Fatalf("Expected to fire again at 259 samples, fired at %v", fired)
+	}
+}
+
+func TestOnThresholdMerge(t *testing.T) {
+	tdigest := New(100)
+	for i := 0; i < 100; i++ {
+		assertNoError(t, tdigest.Add(1))
+	}
+
+	var fired int
+	tdigest.OnThreshold(10, 0.5, func(float64) { fired++ })
+	tdigest.OnThreshold(10, 0.99, func(float64) { fired += 10 })
+
+	other := New(100)
+	for i := 0; i < 50; i++ {
+		assertNoError(t, other.Add(20))
+	}
+	assertNoError(t, tdigest.Merge(other))
+
+	if fired != 10 {
+		t.Errorf("Expected only the 0.99 threshold to fire, got %d", fired)
+	}
+
+	shouldPanic(func() { tdigest.OnThreshold(1, 1, func(float64) {}) }, t, "A limit of 1 should panic")
+	shouldPanic(func() { tdigest.OnThreshold(1, 0.5, nil) }, t, "A nil callback should panic")
+
+	tdigest.writing = true
+	shouldPanic(func() { tdigest.OnThreshold(1, 0.5, func(float64) {}) }, t, "OnThreshold during a write should panic")
+}
+
+func TestOnThresholdOtherWrites(t *testing.T) {
+	var fired int
+	tdigest := New(100)
+	tdigest.OnThreshold(100, 0.9, func(float64) { fired++ })
+
+	// 20 slow samples out of 120 read at once
+	var buf bytes.Buffer
+	for i := 0; i < 120; i++ {
+		x := 1.0
+		if i%6 == 0 {
+			x = 200
+		}
+		assertNoError(t, binary.Write(&buf, binary.LittleEndian, x))
+	}
+	if _, err := tdigest.AddFromReader(&buf, binary.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	if fired != 1 {
+		t.Fatalf("Expected AddFromReader to fire once, fired %d times", fired)
+	}
+
+	// shifting every sample below the value clears the threshold, and
+	// shifting them back fires it again
+	assertNoError(t, tdigest.Shift(-1000))
+	assertNoError(t, tdigest.Shift(1000))
+	if fired != 2 {
+		t.Errorf("Expected Shift to fire again, fired %d times", fired)
+	}
+	assertNoError(t, tdigest.Scale(0.01))
+	assertNoError(t, tdigest.Scale(100))
+	if fired != 3 {
+		t.Errorf("Expected Scale to fire again, fired %d times", fired)
+	}
+
+	histogram := New(100)
+	histogram.OnThreshold(100, 0.9, func(float64) { fired++ })
+	assertNoError(t, histogram.AddHistogram([]Bucket{{Lo: 0, Hi: 10, Count: 80}, {Lo: 200, Hi: 300, Count: 20}}, SpreadMidpoint))
+	if fired != 4 {
+		t.Errorf("Expected AddHistogram to fire, fired %d times", fired-3)
+	}
+}
@@ -0,0 +1,100 @@
+package tdigest
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// TimeWeighted builds a digest of a gauge sampled at irregular intervals,
+// weighting every observed value by how long it was in effect.
+//
+// Weights are measured in multiples of a unit duration. The fraction of a
+// unit left over from every interval is carried into the next one, so the
+// total weight always matches the elapsed time.
+type TimeWeighted struct {
+	digest *TDigest
+	unit   time.Duration
+	now    func() time.Time
+
+	started bool
+	value   float64
+	at      time.Time
+	carry   float64
+}
+
+// NewTimeWeighted creates a time weighted digest counting one unit of weight
+// per unit of time. It panics if unit is not positive.
+func NewTimeWeighted(compression float64, unit time.Duration) *TimeWeighted {
+	if unit <= 0 {
+		panic("unit must be positive")
+	}
+	return &TimeWeighted{
+		digest: New(compression),
+		unit:   unit,
+		now:    time.Now,
+	}
+}
+
+// SetClock replaces the clock used by ObserveNow and FinalizeNow.
+func (w *TimeWeighted) SetClock(now func() time.Time) {
+	w.now = now
+}
+
+// Observe records that the gauge changed to value at the given time, adding
+// the previous value weighted by how long it was in effect.
+func (w *TimeWeighted) Observe(value float64, at time.Time) error {
+	if math.IsNaN(value) {
+		return errors.New("value must not be NaN")
+	}
+	if err := w.Finalize(at); err != nil {
+		return err
+	}
+
+	w.started = true
+	w.value = value
+	return nil
+}
+
+// ObserveNow is like Observe at the current time of the clock.
+func (w *TimeWeighted) ObserveNow(value float64) error {
+	return w.Observe(value, w.now())
+}
+
+// Finalize adds the current value weighted by the time elapsed since it was
+// observed. It can be called any number of times, for instance before
+// querying the digest, and the value stays in effect afterwards.
+func (w *TimeWeighted) Finalize(at time.Time) error {
+	if !w.started {
+		w.at = at
+		return nil
+	}
+	if at.Before(w.at) {
+		return errors.New("observations must not go back in time")
+	}
+
+	units := float64(at.Sub(w.at))/float64(w.unit) + w.carry
+	count := math.Floor(units)
+	w.carry = units - count
+	w.at = at
+
+	if count == 0 {
+		return nil
+	}
+
+	defer w.digest.checkThresholds()
+	w.digest.beginWrite()
+	defer w.digest.endWrite()
+	return w.digest.addCount(w.value, uint64(count))
+}
+
+// FinalizeNow is like Finalize at the current time of the clock.
+func (w *TimeWeighted) FinalizeNow() error {
+	return w.Finalize(w.now())
+}
+
+// Digest returns the digest of the intervals finalized so far. It is shared
+// with w, not a copy.
+func (w *TimeWeighted) Digest() *TDigest {
+	return w.digest
+}
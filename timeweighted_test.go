@@ -0,0 +1,86 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestTimeWeightedStepFunction(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	w := NewTimeWeighted(100, time.Millisecond)
+	w.SetClock(clock.Now)
+
+	type step struct {
+		value    float64
+		duration time.Duration
+	}
+
+	var steps []step
+	var total time.Duration
+	for i := 0; i < 2000; i++ {
+		s := step{value: rand.Float64() * 100, duration: time.Duration(rand.Intn(5000)+1) * time.Millisecond}
+		steps = append(steps, s)
+		total += s.duration
+
+		assertNoError(t, w.ObserveNow(s.value))
+		clock.Advance(s.duration)
+	}
+	assertNoError(t, w.FinalizeNow())
+
+	if w.Digest().Count() != uint64(total/time.Millisecond) {
+		t.Fatalf("Expected a weight of %d, got %d", total/time.Millisecond, w.Digest().Count())
+	}
+
+	sort.Slice(steps, func(i, j int) bool { return steps[i].value < steps[j].value })
+	exact := func(q float64) float64 {
+		target := time.Duration(q * float64(total))
+		var cum time.Duration
+		for _, s := range steps {
+			cum += s.duration
+			if cum >= target {
+				return s.value
+			}
+		}
+		return steps[len(steps)-1].value
+	}
+
+	for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+		if got := w.Digest().Quantile(q); math.Abs(got-exact(q)) > 1 {
+			t.Errorf("Quantile(%.2f) = %.4f, expected %.4f", q, got, exact(q))
+		}
+	}
+}
+
+func TestTimeWeightedCarriesFractions(t *testing.T) {
+	start := time.Unix(0, 0)
+	w := NewTimeWeighted(100, time.Second)
+
+	assertNoError(t, w.Observe(1, start))
+	for i := 1; i <= 10; i++ {
+		assertNoError(t, w.Observe(float64(i%2), start.Add(time.Duration(i)*1500*time.Millisecond)))
+	}
+
+	// 10 intervals of 1.5s add up to 15 units even though no interval
+	// spans exactly a whole number of them
+	if w.Digest().Count() != 15 {
+		t.Errorf("Expected a weight of 15, got %d", w.Digest().Count())
+	}
+
+	if err := w.Observe(1, start); err == nil {
+		t.Errorf("Expected an error observing in the past")
+	}
+	if err := w.Observe(math.NaN(), start.Add(time.Minute)); err == nil {
+		t.Errorf("Expected an error observing NaN")
+	}
+}
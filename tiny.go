@@ -0,0 +1,203 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The tiny encoding packs digests of at most 15 centroids in a couple of
+// bytes of header. Its first byte holds tinyEncoding in the high nibble and
+// the number of centroids in the low one; the larger encodings always start
+// with a zero byte. The second byte is the index of the compression in
+// tinyCompressions, or tinyCompressionInline followed by the compression as
+// a big endian float64.
+//
+// The means are stored as integers scaled by 2^k: k as a zigzag varint, the
+// first scaled mean as a zigzag varint and the difference between every
+// other one and its predecessor as a varint. The counts and the sections
+// follow as in the larger encodings.
+const (
+	tinyEncoding          = 3
+	tinyMaxCentroids      = 15
+	tinyCompressionInline = 0xff
+
+	// tinyMaxBits bounds the magnitude of the scaled means, so that they are
+	// exactly represented by a float64.
+	tinyMaxBits = 52
+)
+
+var tinyCompressions = []float64{10, 20, 25, 50, 75, 100, 150, 200, 250, 300, 400, 500, 1000}
+
+// appendTiny appends the tiny encoding of the digest to buf, reporting
+// whether the digest can use it. It requires every mean to be stored with at
+// least the relative precision of a float32.
+func (t TDigest) appendTiny(buf []byte) ([]byte, bool) {
+	n := t.summary.Len()
+	if n > tinyMaxCentroids {
+		return buf, false
+	}
+
+	// the precision of every mean needs k >= 24-e for its exponent e, and
+	// the largest one must still fit in tinyMaxBits
+	k, maxExp := math.MinInt32, math.MinInt32
+	for _, mean := range t.summary.means {
+		if math.IsInf(mean, 0) {
+			return buf, false
+		}
+		if mean == 0 {
+			continue
+		}
+		_, e := math.Frexp(mean)
+		if 24-e > k {
+			k = 24 - e
+		}
+		if e > maxExp {
+			maxExp = e
+		}
+	}
+	if k == math.MinInt32 {
+		k = 0
+	} else if maxExp+k > tinyMaxBits {
+		return buf, false
+	}
+
+	// means that are exact with fewer bits, such as integers, are stored
+	// with as few as possible
+	scaled := make([]int64, n)
+	exact := true
+	for i, mean := range t.summary.means {
+		x := math.Ldexp(mean, k)
+		scaled[i] = int64(math.Round(x))
+		exact = exact && float64(scaled[i]) == x
+	}
+	for exact {
+		even := true
+		for _, s := range scaled {
+			even = even && s%2 == 0
+		}
+		if !even || !anyNonZero(scaled) {
+			break
+		}
+		for i := range scaled {
+			scaled[i] /= 2
+		}
+		k--
+	}
+
+	buf = append(buf, tinyEncoding<<4|byte(n))
+	index := tinyCompressionIndex(t.compression)
+	buf = append(buf, byte(index))
+	if index == tinyCompressionInline {
+		var scratch [8]byte
+		binary.BigEndian.PutUint64(scratch[:], math.Float64bits(t.compression))
+		buf = append(buf, scratch[:]...)
+	}
+
+	if n > 0 {
+		var scratch [binary.MaxVarintLen64]byte
+		buf = append(buf, scratch[:binary.PutVarint(scratch[:], int64(k))]...)
+		buf = append(buf, scratch[:binary.PutVarint(scratch[:], scaled[0])]...)
+		for i := 1; i < n; i++ {
+			buf = append(buf, scratch[:binary.PutUvarint(scratch[:], uint64(scaled[i]-scaled[i-1]))]...)
+		}
+	}
+	for _, count := range t.summary.counts {
+		buf = encodeUint32(buf, count)
+	}
+
+	// extremes that are the first and last means are restored from them
+	// rather than taking 16 more bytes, but only if the scaled means decode
+	// to exactly the extremes
+	ends := n > 0 && math.Ldexp(float64(scaled[0]), -k) == t.min && math.Ldexp(float64(scaled[n-1]), -k) == t.max
+
+	// so are the moments, when they are those of the centroids
+	var recomputed TDigest
+	for i, mean := range t.summary.means {
+		count := t.summary.counts[i]
+		recomputed.accumulate(uint64(count), mean*float64(count), t.summary.M2(i))
+	}
+	same := exact && recomputed.moments() == t.moments()
+	return t.appendSections(buf, ends, same), true
+}
+
+func anyNonZero(values []int64) bool {
+	for _, v := range values {
+		if v != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func tinyCompressionIndex(compression float64) int {
+	for i, c := range tinyCompressions {
+		if c == compression {
+			return i
+		}
+	}
+	return tinyCompressionInline
+}
+
+// fromTiny decodes the tiny encoding.
+func fromTiny(buf []byte, maxCentroids int) (t *TDigest, err error) {
+	if len(buf) < 2 {
+		return nil, errors.New("serialization too short for header")
+	}
+
+	numCentroids := int(buf[0] & 0x0f)
+	if numCentroids > maxCentroids {
+		return nil, fmt.Errorf("bad number of centroids in serialization: %d", numCentroids)
+	}
+
+	var compression float64
+	switch index := int(buf[1]); {
+	case index < len(tinyCompressions):
+		compression = tinyCompressions[index]
+		buf = buf[2:]
+	case index == tinyCompressionInline && len(buf) >= 10:
+		compression = math.Float64frombits(binary.BigEndian.Uint64(buf[2:]))
+		buf = buf[10:]
+	default:
+		return nil, fmt.Errorf("invalid compression in serialization: %d", index)
+	}
+
+	means := make([]float64, numCentroids)
+	if numCentroids > 0 {
+		k, n := binary.Varint(buf)
+		if n <= 0 || k < -1100 || k > 1100 {
+			return nil, errors.New("invalid mean scale in serialization")
+		}
+		buf = buf[n:]
+
+		var scaled int64
+		for i := range means {
+			if i == 0 {
+				scaled, n = binary.Varint(buf)
+			} else {
+				var delta uint64
+				delta, n = binary.Uvarint(buf)
+				if delta > 1<<(tinyMaxBits+1) {
+					n = 0
+				}
+				scaled += int64(delta)
+			}
+			if n <= 0 || scaled > 1<<tinyMaxBits || scaled < -1<<tinyMaxBits {
+				return nil, errors.New("invalid mean in serialization")
+			}
+			buf = buf[n:]
+			means[i] = math.Ldexp(float64(scaled), -int(k))
+		}
+	}
+
+	counts := make([]uint32, numCentroids)
+	for i := range counts {
+		counts[i], buf, err = decodeUint32(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return restore(New(compression), means, counts, buf)
+}
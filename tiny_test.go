@@ -0,0 +1,149 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func assertTinyRoundTrip(t *testing.T, t1 *TDigest) *TDigest {
+	t.Helper()
+
+	serialized := t1.Marshal(nil)
+	if serialized[0]>>4 != tinyEncoding {
+		t.Fatalf("Expected the tiny encoding for %d centroids, got % x", t1.summary.Len(), serialized[:4])
+	}
+
+	t2, err := FromBytes(serialized)
+	assertNoError(t, err)
+
+	if t2.Count() != t1.Count() || t2.summary.Len() != t1.summary.Len() || t2.compression != t1.compression {
+		t.Fatalf("Deserialized to something different. Count %d != %d, Len %d != %d, compression %v != %v",
+			t2.Count(), t1.Count(), t2.summary.Len(), t1.summary.Len(), t2.compression, t1.compression)
+	}
+	for i := 0; i < t1.summary.Len(); i++ {
+		expected, got := t1.summary.Mean(i), t2.summary.Mean(i)
+		if math.Abs(got-expected) > math.Abs(expected)/(1<<24) {
+			t.Errorf("mean %d: got %v, expected %v", i, got, expected)
+		}
+		if t2.summary.Count(i) != t1.summary.Count(i) {
+			t.Errorf("count %d: got %d, expected %d", i, t2.summary.Count(i), t1.summary.Count(i))
+		}
+	}
+	return t2
+}
+
+func TestTinySerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7e1))
+
+	for n := 0; n <= tinyMaxCentroids; n++ {
+		floats, integers, negative := New(100), New(100), New(100)
+		for i := 0; i < n; i++ {
+			assertNoError(t, floats.AddCentroid(100*rng.ExpFloat64(), uint32(1+rng.Intn(1000))))
+			assertNoError(t, integers.Add(float64(rng.Intn(5000))))
+			assertNoError(t, negative.Add(-1e6*rng.Float64()))
+		}
+
+		for _, t1 := range []*TDigest{floats, integers, negative} {
+			assertTinyRoundTrip(t, t1)
+		}
+
+		// integer means are exact
+		decoded := assertTinyRoundTrip(t, integers)
+		for i := 0; i < n; i++ {
+			if decoded.summary.Mean(i) != integers.summary.Mean(i) {
+				t.Errorf("integer mean %d: got %v, expected %v", i, decoded.summary.Mean(i), integers.summary.Mean(i))
+			}
+		}
+	}
+}
+
+func TestTinySerializationExtremes(t *testing.T) {
+	// 0.1 and 0.3 are not dyadic rationals, so their scaled means are not
+	// exact and the extremes must be stored separately
+	t1 := New(100)
+	assertNoError(t, t1.Add(0.1))
+	assertNoError(t, t1.Add(0.3))
+	t2 := assertTinyRoundTrip(t, t1)
+	if t2.Min() != 0.1 || t2.Max() != 0.3 {
+		t.Errorf("got extremes %v and %v, expected 0.1 and 0.3", t2.Min(), t2.Max())
+	}
+}
+
+func TestTinySerializationOptions(t *testing.T) {
+	// compressions missing from the table are stored inline
+	t1 := New(42, WithCentroidVariance())
+	for _, x := range []float64{1, 2, 3, 3.5, 1e-3, 0} {
+		assertNoError(t, t1.Add(x))
+	}
+	assertNoError(t, t1.SetMetadata([]byte("tiny")))
+
+	t2 := assertTinyRoundTrip(t, t1)
+	if string(t2.Metadata()) != "tiny" || t2.summary.m2 == nil {
+		t.Errorf("Expected the sections to survive the tiny encoding")
+	}
+
+	// means of very different magnitudes fall back to the float32 deltas
+	wide := New(100)
+	assertNoError(t, wide.Add(1e-12))
+	assertNoError(t, wide.Add(1e12))
+	if got := wide.Marshal(nil)[0]; got != 0 {
+		t.Errorf("Expected the small encoding for a wide range of means, got %x", got)
+	}
+
+	infinite := New(100, WithInfiniteValues())
+	assertNoError(t, infinite.Add(math.Inf(1)))
+	decoded, err := FromBytes(infinite.Marshal(nil))
+	assertNoError(t, err)
+	if !math.IsInf(decoded.Quantile(0.5), 1) {
+		t.Errorf("Expected an infinite mean to survive serialization, got %v", decoded.Quantile(0.5))
+	}
+}
+
+func TestTinyDeserializationRejectsInvalid(t *testing.T) {
+	t1 := New(100)
+	for i := 0; i < 5; i++ {
+		assertNoError(t, t1.Add(float64(i)))
+	}
+	serialized := t1.Marshal(nil)
+
+	for n := 0; n < len(serialized); n++ {
+		if _, err := FromBytes(serialized[:n]); err == nil {
+			t.Errorf("Expected a %d byte payload to be rejected", n)
+		}
+	}
+
+	if _, err := FromBytesWithLimit(serialized, 4); err == nil {
+		t.Errorf("Expected FromBytesWithLimit to enforce the limit")
+	}
+
+	badCompression := append([]byte(nil), serialized...)
+	badCompression[1] = byte(len(tinyCompressions))
+	if _, err := FromBytes(badCompression); err == nil {
+		t.Errorf("Expected an unknown compression index to be rejected")
+	}
+}
+
+func TestTinySerializationSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x512e))
+
+	// a corpus of per-user latency digests in whole microseconds, most with
+	// a handful of samples. Integer means are exact in the tiny encoding, so
+	// that the extremes need not be stored separately.
+	var tiny, standard int
+	for i := 0; i < 10000; i++ {
+		digest := New(100)
+		for j := rng.Intn(8); j >= 0; j-- {
+			assertNoError(t, digest.Add(math.Round(100000*rng.ExpFloat64())))
+		}
+
+		tiny += len(digest.Marshal(nil))
+		standard += len(digest.appendStandard(nil))
+	}
+
+	t.Logf("tiny encoding: %d bytes, standard encoding: %d bytes (%.1f%%)",
+		tiny, standard, 100*float64(tiny)/float64(standard))
+	if 4*tiny > 3*standard {
+		t.Errorf("Expected the tiny encoding to save at least a quarter of the corpus, got %d bytes for %d", tiny, standard)
+	}
+}
@@ -0,0 +1,183 @@
+package tdigest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Scale multiplies every sample of the digest by factor, as when converting
+// latencies from nanoseconds to milliseconds, in a single pass over the
+// centroids. The extremes, the moments of the samples, the exact tails and
+// the grid of WithQuantization are scaled along, so that Quantile(q)
+// afterwards is factor*Quantile(q) before, up to rounding. A negative
+// factor reverses the order of the samples, turning the quantile q into the
+// quantile 1-q.
+//
+// It returns an error if factor is 0, NaN or infinite, or if the scaled
+// samples would overflow, leaving the digest untouched.
+func (t *TDigest) Scale(factor float64) error {
+	if factor == 0 || math.IsNaN(factor) || math.IsInf(factor, 0) {
+		return fmt.Errorf("invalid factor: %v", factor)
+	}
+	t.checkRead()
+	if t.count > 0 && !t.infinite && (math.IsInf(t.min*factor, 0) || math.IsInf(t.max*factor, 0)) {
+		return errors.New("scaled samples overflow")
+	}
+
+	defer t.checkThresholds()
+	t.beginWrite()
+	defer t.endWrite()
+
+	s := t.summary
+	for i := range s.means {
+		s.means[i] *= factor
+	}
+	for i := range s.m2 {
+		s.m2[i] *= factor * factor
+	}
+	t.sum *= factor
+	t.m2 *= factor * factor
+	t.m3 *= factor * factor * factor
+	t.m4 *= factor * factor * factor * factor
+	t.step *= math.Abs(factor)
+	if t.count > 0 {
+		t.min, t.max = t.min*factor, t.max*factor
+		if factor < 0 {
+			t.min, t.max = t.max, t.min
+		}
+	}
+	if e := t.tails; e != nil {
+		for _, values := range [][]float64{e.low, e.high} {
+			for i := range values {
+				values[i] *= factor
+			}
+		}
+	}
+
+	if factor < 0 {
+		for i, j := 0, s.Len()-1; i < j; i, j = i+1, j-1 {
+			s.Swap(i, j)
+		}
+		s.rebuildTree(s.base)
+		t.sweeping = false
+		if e := t.tails; e != nil {
+			e.low, e.high = reversed(e.high), reversed(e.low)
+		}
+	}
+	return nil
+}
+
+// Shift adds delta to every sample of the digest, as when correcting a
+// known constant bias of the measurements, in a single pass over the
+// centroids. The extremes, the sum and the exact tails are shifted along,
+// while the counts and the spacing of the centroids stay the same, so that
+// Quantile(q) afterwards is Quantile(q)+delta before, up to rounding. The
+// samples are not snapped again to the grid of WithQuantization.
+//
+// It returns an error if delta is NaN or infinite, or if the shifted samples
+// would overflow, leaving the digest untouched.
+func (t *TDigest) Shift(delta float64) error {
+	if math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return fmt.Errorf("invalid delta: %v", delta)
+	}
+	t.checkRead()
+	if t.count > 0 && !t.infinite && (math.IsInf(t.min+delta, 0) || math.IsInf(t.max+delta, 0)) {
+		return errors.New("shifted samples overflow")
+	}
+
+	defer t.checkThresholds()
+	t.beginWrite()
+	defer t.endWrite()
+
+	s := t.summary
+	for i := range s.means {
+		s.means[i] += delta
+	}
+	t.sum += float64(t.count) * delta
+	if t.count > 0 {
+		t.min, t.max = t.min+delta, t.max+delta
+	}
+	if e := t.tails; e != nil {
+		for _, values := range [][]float64{e.low, e.high} {
+			for i := range values {
+				values[i] += delta
+			}
+		}
+	}
+	return nil
+}
+
+// DecayWeights multiplies the weight of every sample of the digest by
+// factor, so that repeatedly decaying it lets old samples fade away in
+// favor of the ones added since. As in AddWeightedF, the scaled count of
+// every centroid is rounded to one of the two closest counts at random, up
+// with a probability equal to its fractional part, which keeps the
+// estimates unbiased and lets even centroids of a single sample fade away.
+// Centroids left without samples are deleted, Count and the moments of the
+// samples are updated along, and the extremes are moved in to the means of
+// the centroids left when their centroids are deleted. Samples added
+// afterwards are merged with the decayed centroids according to the new
+// Count.
+//
+// It returns an error if factor is not in (0, 1].
+func (t *TDigest) DecayWeights(factor float64) error {
+	if !(factor > 0 && factor <= 1) {
+		return fmt.Errorf("invalid factor: %v", factor)
+	}
+
+	t.beginWrite()
+	t.decayWeights(factor)
+	t.endWrite()
+	t.checkThresholds()
+	return nil
+}
+
+func (t *TDigest) decayWeights(factor float64) {
+	s := t.summary
+	n, w := s.Len(), 0
+	if n == 0 {
+		return
+	}
+	first, last := s.Mean(0), s.Mean(n-1)
+	for i := 0; i < n; i++ {
+		c := float64(s.counts[i])
+		kept, frac := math.Modf(c * factor)
+		if frac > 0 && float64(t.pcg.Uint32()) < frac*(1<<32) {
+			kept++
+		}
+		t.discard(uint64(c-kept), s.means[i]*(c-kept))
+		if s.m2 != nil {
+			s.m2[i] *= kept / c
+		}
+
+		s.counts[i] = uint32(kept)
+		if kept > 0 {
+			s.move(w, i)
+			w++
+		}
+	}
+	s.cut(w, n)
+	t.sweeping = false
+
+	// the extremes go with the first and last centroids
+	if s.Len() == 0 {
+		t.setMoments(moments{})
+		t.min, t.max = math.Inf(1), math.Inf(-1)
+		return
+	}
+	if s.Mean(0) != first {
+		t.min = math.Max(t.min, s.Mean(0))
+	}
+	if s.Mean(s.Len()-1) != last {
+		t.max = math.Min(t.max, s.Mean(s.Len()-1))
+	}
+}
+
+// reversed reverses values in place and returns them.
+func reversed(values []float64) []float64 {
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+	return values
+}
@@ -0,0 +1,245 @@
+package tdigest
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var transformQuantiles = []float64{0, 0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999, 1}
+
+func TestScale(t *testing.T) {
+	for name, opts := range optionVariants(map[string][]Option{
+		"tails":     {WithExactTails(10)},
+		"quantized": {WithQuantization(1)},
+		"midpoint":  {WithInterpolation(InterpolationMidpoint)},
+	}) {
+		for _, factor := range []float64{1e-6, 4, 1, -1, -0.25} {
+			rng := rand.New(rand.NewSource(0x5ca1e))
+			tdigest := New(100, opts...)
+			for i := 0; i < 20000; i++ {
+				assertNoError(t, tdigest.Add(1e6*rng.ExpFloat64()))
+			}
+			before := tdigest.Quantiles(transformQuantiles)
+			mean, variance, skewness := tdigest.Mean(), tdigest.Variance(), tdigest.Skewness()
+			lo, hi := tdigest.Min(), tdigest.Max()
+
+			assertNoError(t, tdigest.Scale(factor))
+
+			after := tdigest.Quantiles(transformQuantiles)
+			for i, q := range transformQuantiles {
+				expected := factor * before[i]
+				if factor < 0 {
+					expected = factor * before[len(before)-1-i]
+				}
+				// the estimates are only symmetric up to the interpolation
+				// between centroids, which reversing them changes
+				tolerance := 1e-9 * math.Abs(expected)
+				if factor < 0 && q > 0 && q < 1 {
+					tolerance = math.Max(0.02*math.Abs(expected), 0.001*math.Abs(factor)*(hi-lo))
+				}
+				if math.Abs(after[i]-expected) > tolerance {
+					t.Errorf("%s %v: Quantile(%v) = %v, expected %v", name, factor, q, after[i], expected)
+				}
+			}
+
+			if min, max := tdigest.Min(), tdigest.Max(); factor > 0 && (min != factor*lo || max != factor*hi) ||
+				factor < 0 && (min != factor*hi || max != factor*lo) {
+				t.Errorf("%s %v: got extremes %v and %v from %v and %v", name, factor, min, max, lo, hi)
+			}
+			for _, c := range [][2]float64{
+				{tdigest.Mean(), factor * mean},
+				{tdigest.Variance(), factor * factor * variance},
+				{tdigest.Skewness(), math.Copysign(skewness, factor)},
+			} {
+				if math.Abs(c[0]-c[1]) > 1e-9*math.Abs(c[1]) {
+					t.Errorf("%s %v: got moment %v, expected %v", name, factor, c[0], c[1])
+				}
+			}
+
+			for i := 0; i < 1000; i++ {
+				assertNoError(t, tdigest.Add(factor*1e6*rng.ExpFloat64()))
+			}
+			if tdigest.Count() != 21000 {
+				t.Errorf("%s %v: got %d samples after adding to the scaled digest", name, factor, tdigest.Count())
+			}
+		}
+	}
+}
+
+func TestScaleLimits(t *testing.T) {
+	empty := New(100)
+	assertNoError(t, empty.Scale(-2))
+	if !bytes.Equal(empty.Marshal(nil), New(100).Marshal(nil)) || !math.IsInf(empty.min, 1) || !math.IsInf(empty.max, -1) {
+		t.Errorf("Scaling an empty digest changed it")
+	}
+
+	tdigest := New(100)
+	for i := 0; i < 100; i++ {
+		assertNoError(t, tdigest.Add(float64(i)*1e300))
+	}
+	before := tdigest.Marshal(nil)
+	for _, factor := range []float64{0, math.NaN(), math.Inf(1), math.Inf(-1), 1e10} {
+		if err := tdigest.Scale(factor); err == nil {
+			t.Errorf("Expected an error for factor %v", factor)
+		}
+	}
+	if after := tdigest.Marshal(nil); !bytes.Equal(before, after) {
+		t.Errorf("A failed Scale changed the digest")
+	}
+}
+
+func TestShift(t *testing.T) {
+	for name, opts := range optionVariants(map[string][]Option{
+		"tails":    {WithExactTails(10)},
+		"midpoint": {WithInterpolation(InterpolationMidpoint)},
+	}) {
+		for _, delta := range []float64{-2, 0, 0.5, 1000} {
+			rng := rand.New(rand.NewSource(0x5417))
+			tdigest := New(100, opts...)
+			for i := 0; i < 20000; i++ {
+				assertNoError(t, tdigest.Add(10+rng.NormFloat64()))
+			}
+			before := tdigest.Quantiles(transformQuantiles)
+			mean, variance, kurtosis := tdigest.Mean(), tdigest.Variance(), tdigest.Kurtosis()
+			lo, hi, count := tdigest.Min(), tdigest.Max(), tdigest.Count()
+
+			assertNoError(t, tdigest.Shift(delta))
+
+			after := tdigest.Quantiles(transformQuantiles)
+			for i, q := range transformQuantiles {
+				if expected := before[i] + delta; math.Abs(after[i]-expected) > 1e-9*math.Abs(expected) {
+					t.Errorf("%s %v: Quantile(%v) = %v, expected %v", name, delta, q, after[i], expected)
+				}
+			}
+			if tdigest.Min() != lo+delta || tdigest.Max() != hi+delta || tdigest.Count() != count {
+				t.Errorf("%s %v: got %d samples in [%v, %v], expected %d in [%v, %v]", name, delta,
+					tdigest.Count(), tdigest.Min(), tdigest.Max(), count, lo+delta, hi+delta)
+			}
+			for _, c := range [][2]float64{
+				{tdigest.Mean(), mean + delta},
+				{tdigest.Variance(), variance},
+				{tdigest.Kurtosis(), kurtosis},
+			} {
+				if math.Abs(c[0]-c[1]) > 1e-9*math.Abs(c[1]) {
+					t.Errorf("%s %v: got moment %v, expected %v", name, delta, c[0], c[1])
+				}
+			}
+
+			// the first mean of the encoding changes while the others are
+			// encoded relative to it
+			decoded, err := FromBytes(tdigest.Marshal(nil))
+			assertNoError(t, err)
+			if !bytes.Equal(decoded.Marshal(nil), tdigest.Marshal(nil)) {
+				t.Errorf("%s %v: the shifted digest does not survive serialization", name, delta)
+			}
+			roundtrip := decoded.Quantiles(transformQuantiles)
+			for i, q := range transformQuantiles {
+				if math.Abs(roundtrip[i]-after[i]) > 1e-3 {
+					t.Errorf("%s %v: decoded Quantile(%v) = %v, expected %v", name, delta, q, roundtrip[i], after[i])
+				}
+			}
+		}
+	}
+
+	tdigest := New(100)
+	for i := 0; i < 100; i++ {
+		assertNoError(t, tdigest.Add(float64(i)*1e306))
+	}
+	before := tdigest.Marshal(nil)
+	for _, delta := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.MaxFloat64} {
+		if err := tdigest.Shift(delta); err == nil {
+			t.Errorf("Expected an error for delta %v", delta)
+		}
+	}
+	if after := tdigest.Marshal(nil); !bytes.Equal(before, after) {
+		t.Errorf("A failed Shift changed the digest")
+	}
+}
+
+func TestDecayWeights(t *testing.T) {
+	for name, opts := range optionVariants(nil) {
+		rng := rand.New(rand.NewSource(0xdeca7))
+		tdigest := New(100, opts...)
+		for i := 0; i < 100000; i++ {
+			assertNoError(t, tdigest.Add(rng.NormFloat64()))
+		}
+		before := tdigest.Quantiles(transformQuantiles)
+		mean, variance := tdigest.Mean(), tdigest.Variance()
+
+		assertNoError(t, tdigest.DecayWeights(0.5))
+
+		if got := float64(tdigest.Count()); math.Abs(got-50000) > 500 {
+			t.Errorf("%s: got %v samples after halving 100000", name, got)
+		}
+		var total uint64
+		tdigest.ForEachCentroid(func(mean float64, count uint32) bool {
+			total += uint64(count)
+			return true
+		})
+		if total != tdigest.Count() {
+			t.Errorf("%s: the centroids hold %d samples, expected Count() = %d", name, total, tdigest.Count())
+		}
+
+		after := tdigest.Quantiles(transformQuantiles)
+		for i, q := range transformQuantiles[1 : len(transformQuantiles)-1] {
+			if math.Abs(after[i+1]-before[i+1]) > 0.05 {
+				t.Errorf("%s: Quantile(%v) = %v, expected about %v", name, q, after[i+1], before[i+1])
+			}
+		}
+		if math.Abs(tdigest.Mean()-mean) > 0.01 || math.Abs(tdigest.Variance()-variance) > 0.02 {
+			t.Errorf("%s: got mean %v and variance %v, expected about %v and %v", name,
+				tdigest.Mean(), tdigest.Variance(), mean, variance)
+		}
+		if tdigest.Min() < before[0] || tdigest.Max() > before[len(before)-1] {
+			t.Errorf("%s: got extremes %v and %v outside of %v and %v", name,
+				tdigest.Min(), tdigest.Max(), before[0], before[len(before)-1])
+		}
+
+		// new samples outweigh the decayed ones
+		for i := 0; i < 100000; i++ {
+			assertNoError(t, tdigest.Add(10+rng.NormFloat64()))
+		}
+		if q := tdigest.Quantile(0.4); q < 5 {
+			t.Errorf("%s: Quantile(0.4) = %v, expected the recent samples to dominate", name, q)
+		}
+
+		for i := 0; i < 100 && tdigest.Count() > 0; i++ {
+			assertNoError(t, tdigest.DecayWeights(0.5))
+		}
+		if tdigest.Count() != 0 || tdigest.summary.Len() != 0 || !math.IsNaN(tdigest.Min()) {
+			t.Errorf("%s: expected repeated decays to empty the digest, got %d samples", name, tdigest.Count())
+		}
+	}
+
+	tdigest := New(100)
+	for i := 0; i < 100; i++ {
+		assertNoError(t, tdigest.Add(float64(i)))
+	}
+	before := tdigest.Marshal(nil)
+	for _, factor := range []float64{0, -0.5, 1.5, math.NaN(), math.Inf(1)} {
+		if err := tdigest.DecayWeights(factor); err == nil {
+			t.Errorf("Expected an error for factor %v", factor)
+		}
+	}
+	assertNoError(t, tdigest.DecayWeights(1))
+	if after := tdigest.Marshal(nil); !bytes.Equal(before, after) {
+		t.Errorf("DecayWeights(1) or a failed decay changed the digest")
+	}
+
+	// dropping the extreme centroids moves the extremes to the ones left
+	edges := New(100)
+	assertNoError(t, edges.Add(0))
+	assertNoError(t, edges.AddWeighted(10, 1000))
+	assertNoError(t, edges.Add(20))
+	for i := 0; i < 10 && (edges.summary.Mean(0) == 0 || edges.summary.Mean(edges.summary.Len()-1) == 20); i++ {
+		assertNoError(t, edges.DecayWeights(0.5))
+	}
+	if s := edges.summary; s.Mean(0) != 10 || s.Mean(s.Len()-1) != 10 {
+		t.Fatalf("Expected only the centroid at 10 to be left, got %v", edges.Centroids())
+	}
+	if edges.Min() != edges.summary.Mean(0) || edges.Max() != edges.summary.Mean(edges.summary.Len()-1) {
+		t.Errorf("got extremes %v and %v, expected both to be 10", edges.Min(), edges.Max())
+	}
+}
@@ -0,0 +1,119 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+)
+
+// Number is the set of the integer and floating-point types of the samples
+// of a TypedDigest.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// maxExactInteger is the largest magnitude up to which a float64 holds
+// every integer exactly.
+const maxExactInteger = 1 << 53
+
+// AddT adds the sample v of any integer or floating-point type to t, like
+// Add. It returns an error for integers a float64 does not hold exactly,
+// whose magnitude exceeds 2^53, and ErrInvalidValue for the floats rejected
+// by Add.
+func AddT[T Number](t *TDigest, v T) error {
+	x, err := toFloat(v)
+	if err != nil {
+		return err
+	}
+	return t.Add(x)
+}
+
+// toFloat converts v to a float64, and returns an error for integers that
+// would lose precision.
+func toFloat[T Number](v T) (float64, error) {
+	x := float64(v)
+	if !isFloat[T]() && math.Abs(x) >= maxExactInteger {
+		// 2^53+1 rounds down to 2^53, which converts back exactly
+		if math.Abs(x) > maxExactInteger || T(x) != v {
+			return 0, fmt.Errorf("integer %v is too large to be held exactly", v)
+		}
+	}
+	return x, nil
+}
+
+// isFloat reports whether T is a floating-point type.
+func isFloat[T Number]() bool {
+	half := 0.5
+	return T(half) != 0
+}
+
+// TypedDigest wraps a digest of samples of an integer or floating-point
+// type, converting them to and from float64 so that call sites do not. The
+// quantiles of integer types are rounded to the nearest integer.
+type TypedDigest[T Number] struct {
+	digest *TDigest
+}
+
+// NewTypedDigest creates a digest of samples of type T configured by
+// compression and opts like New.
+func NewTypedDigest[T Number](compression float64, opts ...Option) *TypedDigest[T] {
+	return &TypedDigest[T]{digest: New(compression, opts...)}
+}
+
+// Add adds the sample v like AddT.
+func (d *TypedDigest[T]) Add(v T) error {
+	return AddT(d.digest, v)
+}
+
+// AddBatch adds every sample of values like TDigest.AddBatch. It returns an
+// error and adds nothing if some sample is rejected by Add.
+func (d *TypedDigest[T]) AddBatch(values []T) error {
+	xs := make([]float64, len(values))
+	for i, v := range values {
+		x, err := toFloat(v)
+		if err != nil {
+			return err
+		}
+		xs[i] = x
+	}
+	return d.digest.AddBatch(xs)
+}
+
+// Quantile returns the estimate of TDigest.Quantile converted to T, rounded
+// to the nearest integer for integer types, which return 0 for an empty
+// digest.
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (d *TypedDigest[T]) Quantile(q float64) T {
+	x := d.digest.Quantile(q)
+	if isFloat[T]() {
+		return T(x)
+	}
+	if math.IsNaN(x) {
+		return 0
+	}
+	return T(math.Round(x))
+}
+
+// CDF returns the estimate of TDigest.CDF for v.
+func (d *TypedDigest[T]) CDF(v T) float64 {
+	return d.digest.CDF(float64(v))
+}
+
+// Count returns the number of samples added to the digest.
+func (d *TypedDigest[T]) Count() uint64 {
+	return d.digest.Count()
+}
+
+// Merge adds the samples of other, which is left unchanged, like
+// TDigest.Merge.
+func (d *TypedDigest[T]) Merge(other *TypedDigest[T]) error {
+	return d.digest.Merge(other.digest)
+}
+
+// Digest returns the digest of the samples converted to float64, for the
+// queries that TypedDigest does not wrap. It is shared with d, not a copy.
+func (d *TypedDigest[T]) Digest() *TDigest {
+	return d.digest
+}
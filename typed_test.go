@@ -0,0 +1,91 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAddT(t *testing.T) {
+	tdigest := New(100)
+	assertNoError(t, AddT(tdigest, int8(-3)))
+	assertNoError(t, AddT(tdigest, uint64(1<<53)))
+	assertNoError(t, AddT(tdigest, float32(0.5)))
+	assertNoError(t, AddT(tdigest, int64(-1<<53)))
+	if tdigest.Count() != 4 || tdigest.Max() != 1<<53 || tdigest.Min() != -1<<53 {
+		t.Errorf("got %d samples in [%v, %v]", tdigest.Count(), tdigest.Min(), tdigest.Max())
+	}
+
+	for _, err := range []error{
+		AddT(tdigest, int64(1<<53+1)),
+		AddT(tdigest, int64(-1<<53-1)),
+		AddT(tdigest, uint64(math.MaxUint64)),
+		AddT(tdigest, int64(math.MaxInt64)),
+	} {
+		if err == nil {
+			t.Errorf("Expected an error for an integer beyond 2^53")
+		}
+	}
+	if err := AddT(tdigest, float32(math.NaN())); err != ErrInvalidValue {
+		t.Errorf("got %v for NaN, expected ErrInvalidValue", err)
+	}
+	if tdigest.Count() != 4 {
+		t.Errorf("rejected samples changed the count to %d", tdigest.Count())
+	}
+}
+
+func TestTypedDigest(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7d))
+
+	ints := NewTypedDigest[int64](100)
+	if q := ints.Quantile(0.5); q != 0 {
+		t.Errorf("Quantile(0.5) of an empty digest = %v, expected 0", q)
+	}
+	for i := 0; i < 100000; i++ {
+		assertNoError(t, ints.Add(rng.Int63n(1000000)))
+	}
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99} {
+		if got, expected := ints.Quantile(q), int64(q*1000000); got < expected-20000 || got > expected+20000 {
+			t.Errorf("int64 Quantile(%v) = %v, expected %v", q, got, expected)
+		}
+	}
+	assertNear(t, "CDF(250000)", ints.CDF(250000), 0.25, 0.01)
+
+	counts := NewTypedDigest[uint32](100)
+	values := make([]uint32, 1000)
+	for i := range values {
+		values[i] = uint32(i % 10)
+	}
+	assertNoError(t, counts.AddBatch(values))
+	if counts.Count() != 1000 || counts.Quantile(0) != 0 || counts.Quantile(1) != 9 {
+		t.Errorf("got %d uint32 samples in [%v, %v]", counts.Count(), counts.Quantile(0), counts.Quantile(1))
+	}
+	// quantiles of integers are integers
+	if q := counts.Quantile(0.5); q != 4 && q != 5 {
+		t.Errorf("uint32 Quantile(0.5) = %v, expected 4 or 5", q)
+	}
+	if math.IsNaN(counts.Digest().Quantile(0.5)) {
+		t.Errorf("Expected the wrapped digest to hold the samples")
+	}
+
+	floats, other := NewTypedDigest[float32](100), NewTypedDigest[float32](100)
+	for i := 0; i < 1000; i++ {
+		assertNoError(t, floats.Add(float32(rng.Float64())))
+		assertNoError(t, other.Add(float32(1+rng.Float64())))
+	}
+	assertNoError(t, floats.Merge(other))
+	if got := floats.Quantile(0.5); math.Abs(float64(got)-1) > 0.05 {
+		t.Errorf("float32 Quantile(0.5) = %v, expected 1", got)
+	}
+	if q := floats.Quantile(0.25); q == float32(math.Round(float64(q))) {
+		t.Errorf("float32 Quantile(0.25) = %v, expected a fraction", q)
+	}
+
+	big := NewTypedDigest[int64](100)
+	if err := big.AddBatch([]int64{1, 2, 1<<53 + 1}); err == nil {
+		t.Errorf("Expected an error for a batch with an integer beyond 2^53")
+	}
+	if big.Count() != 0 {
+		t.Errorf("A rejected batch added %d samples", big.Count())
+	}
+}
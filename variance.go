@@ -0,0 +1,75 @@
+package tdigest
+
+import "math"
+
+// WithCentroidVariance makes the digest track the variance of the samples
+// of every centroid, at the cost of one more float64 per centroid.
+//
+// Quantile and CDF then use the spread of every centroid to bound the
+// interval its samples are interpolated over, which mostly helps in the
+// tails and wherever the samples form clusters narrower than the distance
+// between centroids. Variances are kept through merges, compression and
+// serialization.
+func WithCentroidVariance() Option {
+	return func(t *TDigest) {
+		t.variance = true
+	}
+}
+
+// spreadBounds returns the interval over which the samples of the centroid
+// at index are assumed to be spread: the one of a uniform distribution with
+// the same variance, clamped to where linear interpolation between the means
+// of neighboring centroids crosses their shared rank. Centroids that overlap
+// their neighbors are thus interpolated exactly as without variances.
+func (s summary) spreadBounds(index int) (lo, hi float64) {
+	mean, count := s.means[index], float64(s.counts[index])
+	half := math.Sqrt(3 * s.m2[index] / count)
+
+	lo, hi = mean-half, mean+half
+	if index > 0 {
+		prev, c := s.means[index-1], float64(s.counts[index-1])
+		lo = math.Max(lo, prev+(mean-prev)*c/(c+count))
+	}
+	if index+1 < s.Len() {
+		next, c := s.means[index+1], float64(s.counts[index+1])
+		hi = math.Min(hi, mean+(next-mean)*count/(count+c))
+	}
+	return lo, hi
+}
+
+// quantileSpread is Quantile for digests tracking variances.
+func (t *TDigest) quantileSpread(q float64) float64 {
+	index := q * float64(t.count-1)
+	i, total := t.summary.FloorSum(index)
+
+	// the k-th sample of a centroid of count samples sits at (k+0.5)/count
+	// of its interval
+	lo, hi := t.summary.spreadBounds(i)
+	f := math.Min((index-total+0.5)/float64(t.summary.Count(i)), 1)
+	mean := t.summary.Mean(i)
+	if f < 0.5 {
+		return lo + 2*f*(mean-lo)
+	}
+	return mean + (2*f-1)*(hi-mean)
+}
+
+// cdfSpread is CDF for digests tracking variances.
+func (t *TDigest) cdfSpread(value float64) float64 {
+	var total float64
+	for i := 0; i < t.summary.Len(); i++ {
+		lo, hi := t.summary.spreadBounds(i)
+		count := float64(t.summary.Count(i))
+
+		mean := t.summary.Mean(i)
+		switch {
+		case value < lo:
+			return total / float64(t.count)
+		case value < mean:
+			return (total + count*(value-lo)/(mean-lo)/2) / float64(t.count)
+		case value < hi:
+			return (total + count*(0.5+(value-mean)/(hi-mean)/2)) / float64(t.count)
+		}
+		total += count
+	}
+	return 1
+}
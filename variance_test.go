@@ -0,0 +1,148 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// totalVariance returns the variance of the samples summarized by the
+// centroids, using their sums of squared deviations.
+func totalVariance(s *summary) float64 {
+	var n, mean float64
+	for i := 0; i < s.Len(); i++ {
+		n += float64(s.Count(i))
+		mean += float64(s.Count(i)) * s.Mean(i)
+	}
+	mean /= n
+
+	var m2 float64
+	for i := 0; i < s.Len(); i++ {
+		d := s.Mean(i) - mean
+		m2 += s.M2(i) + float64(s.Count(i))*d*d
+	}
+	return m2 / n
+}
+
+func exactVariance(data []float64) float64 {
+	var mean float64
+	for _, x := range data {
+		mean += x
+	}
+	mean /= float64(len(data))
+
+	var m2 float64
+	for _, x := range data {
+		m2 += (x - mean) * (x - mean)
+	}
+	return m2 / float64(len(data))
+}
+
+func TestCentroidVarianceBookkeeping(t *testing.T) {
+	data := make([]float64, 50000)
+	for i := range data {
+		data[i] = 3 + 2*rand.NormFloat64()
+	}
+
+	t1 := New(10, WithCentroidVariance())
+	t2 := New(10, WithCentroidVariance())
+	for i, x := range data {
+		if i%2 == 0 {
+			assertNoError(t, t1.Add(x))
+		} else {
+			assertNoError(t, t2.AddWeighted(x, 1))
+		}
+	}
+	assertNoError(t, t1.Merge(t2))
+	assertNoError(t, t1.Compress())
+
+	exact := exactVariance(data)
+	if got := totalVariance(t1.summary); math.Abs(got-exact) > 1e-9*exact {
+		t.Errorf("Centroid variances add up to %v, expected %v", got, exact)
+	}
+
+	for i := 0; i < t1.summary.Len(); i++ {
+		if t1.summary.Count(i) == 1 && t1.summary.M2(i) != 0 {
+			t.Fatalf("Singleton centroid %d has a variance of %v", i, t1.summary.M2(i))
+		}
+	}
+
+	// the means lose some precision when serialized as float32 deltas
+	t3, err := FromBytes(t1.Marshal(nil))
+	assertNoError(t, err)
+	if t3.summary.m2 == nil {
+		t.Fatalf("Expected the variances to survive serialization")
+	}
+	if got := totalVariance(t3.summary); math.Abs(got-exact) > 1e-6*exact {
+		t.Errorf("Decoded centroid variances add up to %v, expected %v", got, exact)
+	}
+
+	rolled, err := Rollup([]*TDigest{t1, t3}, 5)
+	assertNoError(t, err)
+	if got := totalVariance(rolled.summary); math.Abs(got-exact) > 1e-6*exact {
+		t.Errorf("Rolled up centroid variances add up to %v, expected %v", got, exact)
+	}
+}
+
+func TestCentroidVarianceAccuracy(t *testing.T) {
+	streams := map[string]func() float64{
+		"uniform":     rand.Float64,
+		"normal":      rand.NormFloat64,
+		"exponential": rand.ExpFloat64,
+	}
+
+	for name, next := range streams {
+		data := make([]float64, 100000)
+		for i := range data {
+			data[i] = next()
+		}
+
+		plain := New(50)
+		spread := New(50, WithCentroidVariance())
+		for _, x := range data {
+			assertNoError(t, plain.Add(x))
+			assertNoError(t, spread.Add(x))
+		}
+		sort.Float64s(data)
+
+		var plainQ, spreadQ, plainCDF, spreadCDF float64
+		for i := 1; i < 1000; i++ {
+			q := float64(i) / 1000
+			exact := quantile(q, data)
+			plainQ += math.Abs(plain.Quantile(q) - exact)
+			spreadQ += math.Abs(spread.Quantile(q) - exact)
+			plainCDF += math.Abs(plain.CDF(exact) - q)
+			spreadCDF += math.Abs(spread.CDF(exact) - q)
+		}
+
+		if spreadQ > plainQ {
+			t.Errorf("%s: quantile error %.5f with variances, %.5f without", name, spreadQ, plainQ)
+		}
+		if spreadCDF > 0.9*plainCDF {
+			t.Errorf("%s: CDF error %.5f with variances, %.5f without", name, spreadCDF, plainCDF)
+		}
+	}
+}
+
+func TestCentroidVarianceOff(t *testing.T) {
+	tdigest := New(10)
+	for i := 0; i < 10000; i++ {
+		assertNoError(t, tdigest.Add(rand.Float64()))
+	}
+	if tdigest.summary.m2 != nil {
+		t.Fatalf("Expected no variances without WithCentroidVariance")
+	}
+
+	decoded, err := FromBytes(tdigest.Marshal(nil))
+	assertNoError(t, err)
+	if decoded.summary.m2 != nil {
+		t.Errorf("Expected no variances after decoding")
+	}
+
+	rolled, err := Rollup([]*TDigest{tdigest, New(10, WithCentroidVariance())}, 10)
+	assertNoError(t, err)
+	if rolled.summary.m2 != nil {
+		t.Errorf("Expected no variances rolling up digests without them")
+	}
+}
@@ -0,0 +1,206 @@
+package tdigest
+
+import (
+	"fmt"
+	"time"
+)
+
+// WindowedTDigest holds the samples of a sliding window of time, such as
+// the last 5 minutes, in a ring of digests that each hold the samples of a
+// bucket of time, such as 10 seconds. Samples are added to the bucket of
+// the current time, and a bucket is cleared once it falls out of the
+// window, so that the samples older than the window expire at once, a
+// bucket at a time. The buckets are aligned on multiples of their duration
+// since the zero time.
+//
+// Queries merge the digests of the buckets in the window. The merge of the
+// buckets before the current one is kept until the next bucket starts, so
+// that only the current bucket is merged into it again after additions.
+type WindowedTDigest struct {
+	window, bucket time.Duration
+	now            func() time.Time
+
+	// buckets is the ring of digests, the one at current holding the bucket
+	// that starts at start, and the ones before it the earlier buckets.
+	buckets []*TDigest
+	current int
+	start   time.Time
+
+	// closed is the merge of the buckets before the current one, and view
+	// that of all the buckets as of the generation of the current one. They
+	// are nil until needed.
+	closed     *TDigest
+	view       *TDigest
+	generation uint64
+}
+
+// NewWindowedTDigest creates a digest of the samples of the given window,
+// made of buckets of the given duration, each a digest created with New
+// and the given compression and options. The window is rounded up to a
+// multiple of the buckets and, as the current bucket only holds the
+// samples added since it started, holds the samples of at least the
+// window minus a bucket. The time is that of now, or of time.Now if now is
+// nil.
+//
+// It panics if bucket is not positive or window is shorter than bucket.
+func NewWindowedTDigest(compression float64, window, bucket time.Duration, now func() time.Time, opts ...Option) *WindowedTDigest {
+	if bucket <= 0 {
+		panic("bucket must be positive")
+	}
+	if window < bucket {
+		panic("window must be at least one bucket")
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	w := &WindowedTDigest{
+		window:  window,
+		bucket:  bucket,
+		now:     now,
+		buckets: make([]*TDigest, (window+bucket-1)/bucket),
+	}
+	for i := range w.buckets {
+		w.buckets[i] = New(compression, opts...)
+	}
+	w.start = now().Truncate(bucket)
+	return w
+}
+
+// Window returns the duration of the window, rounded up to a multiple of
+// the buckets.
+func (w *WindowedTDigest) Window() time.Duration {
+	return time.Duration(len(w.buckets)) * w.bucket
+}
+
+// Bucket returns the duration of the buckets.
+func (w *WindowedTDigest) Bucket() time.Duration {
+	return w.bucket
+}
+
+// rotate moves the current bucket to the one holding at, clearing the
+// buckets that fall out of the window, and ignores times before the current
+// bucket.
+func (w *WindowedTDigest) rotate(at time.Time) {
+	steps := at.Sub(w.start) / w.bucket
+	if steps <= 0 {
+		return
+	}
+	w.start = w.start.Add(steps * w.bucket)
+	if steps > time.Duration(len(w.buckets)) {
+		steps = time.Duration(len(w.buckets))
+	}
+	for ; steps > 0; steps-- {
+		w.current = (w.current + 1) % len(w.buckets)
+		w.buckets[w.current] = w.buckets[w.current].emptyCopy()
+	}
+	w.closed, w.view = nil, nil
+}
+
+// Add adds value to the bucket of the current time, like TDigest.Add.
+func (w *WindowedTDigest) Add(value float64) error {
+	w.rotate(w.now())
+	return w.buckets[w.current].Add(value)
+}
+
+// AddWeighted adds value to the bucket of the current time, like
+// TDigest.AddWeighted.
+func (w *WindowedTDigest) AddWeighted(value float64, count uint32) error {
+	w.rotate(w.now())
+	return w.buckets[w.current].AddWeighted(value, count)
+}
+
+// Merge adds the samples of the buckets of other to the buckets of w
+// covering the same time, after moving w to the later of its current time
+// and that of other. The buckets of other that are out of the window by
+// then are left out, and other is left unchanged.
+//
+// It returns an error if other has a different window or bucket duration.
+func (w *WindowedTDigest) Merge(other *WindowedTDigest) error {
+	if other.bucket != w.bucket || len(other.buckets) != len(w.buckets) {
+		return fmt.Errorf("cannot merge windows of %v in buckets of %v and of %v in buckets of %v",
+			w.Window(), w.bucket, other.Window(), other.bucket)
+	}
+
+	w.rotate(w.now())
+	w.rotate(other.now())
+	if other == w {
+		for _, b := range w.buckets {
+			if err := b.Merge(b); err != nil {
+				return err
+			}
+		}
+		w.closed, w.view = nil, nil
+		return nil
+	}
+
+	n := len(w.buckets)
+	for k := 0; k < n; k++ {
+		// the k-th bucket before the current one of other is the j-th one
+		// before the current one of w
+		j := int(w.start.Sub(other.start)/w.bucket) + k
+		if j >= n {
+			break
+		}
+		if j < 0 {
+			// other went back in time since its last bucket started
+			continue
+		}
+		b := other.buckets[(other.current-k+n)%n]
+		if err := w.buckets[(w.current-j+n)%n].Merge(b); err != nil {
+			return err
+		}
+	}
+	w.closed, w.view = nil, nil
+	return nil
+}
+
+// Digest returns a digest of the samples in the window, the merge of the
+// digests of its buckets. It is kept by w for the next queries, and must
+// not be modified.
+func (w *WindowedTDigest) Digest() *TDigest {
+	w.rotate(w.now())
+
+	current := w.buckets[w.current]
+	if w.view != nil && w.generation == current.generation {
+		return w.view
+	}
+
+	n := len(w.buckets)
+	if w.closed == nil {
+		w.closed = current.emptyCopy()
+		for k := 1; k < n; k++ {
+			// the centroids of the buckets are valid samples, which Merge
+			// accepts
+			_ = w.closed.Merge(w.buckets[(w.current+k)%n])
+		}
+	}
+
+	w.view = w.closed.Clone()
+	_ = w.view.Merge(current)
+	w.generation = current.generation
+	return w.view
+}
+
+// Quantile returns the estimate of TDigest.Quantile for the samples in the
+// window.
+func (w *WindowedTDigest) Quantile(q float64) float64 {
+	return w.Digest().Quantile(q)
+}
+
+// CDF returns the estimate of TDigest.CDF for the samples in the window.
+func (w *WindowedTDigest) CDF(value float64) float64 {
+	return w.Digest().CDF(value)
+}
+
+// Count returns the number of samples in the window, without merging the
+// buckets.
+func (w *WindowedTDigest) Count() uint64 {
+	w.rotate(w.now())
+
+	var count uint64
+	for _, b := range w.buckets {
+		count += b.Count()
+	}
+	return count
+}
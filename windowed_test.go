@@ -0,0 +1,109 @@
+package tdigest
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWindowedTDigest(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	w := NewWindowedTDigest(100, 5*time.Minute, 10*time.Second, clock.Now)
+	if w.Window() != 5*time.Minute || w.Bucket() != 10*time.Second {
+		t.Errorf("Got a window of %v in buckets of %v", w.Window(), w.Bucket())
+	}
+
+	// a sample per second, whose value is the minute it was added in
+	for i := 0; i < 600; i++ {
+		assertNoError(t, w.Add(float64(i/60)))
+		clock.Advance(time.Second)
+	}
+
+	// the window holds the last 29 buckets and the current one, which just
+	// started
+	if w.Count() != 290 {
+		t.Errorf("Count() = %d, expected 290", w.Count())
+	}
+	if q := w.Quantile(0); q != 5 {
+		t.Errorf("Quantile(0) = %v, expected the samples before the window to expire", q)
+	}
+	// the 50 samples of minute 5 left, 60 of minute 6 and half of minute 7
+	assertNear(t, "CDF(7)", w.CDF(7), 140.0/290, 1e-9)
+	if w.Digest() != w.Digest() {
+		t.Errorf("Expected the merged buckets to be kept between queries")
+	}
+
+	view := w.Digest()
+	assertNoError(t, w.AddWeighted(100, 10))
+	if w.Digest() == view || w.Digest().Count() != 300 || w.Quantile(1) != 100 {
+		t.Errorf("Expected an addition to update the merged buckets, got %d samples", w.Digest().Count())
+	}
+
+	clock.Advance(4*time.Minute + 50*time.Second)
+	if w.Count() != 10 || w.Quantile(0) != 100 {
+		t.Errorf("Expected only the current bucket to be left, got %d samples", w.Count())
+	}
+	clock.Advance(10 * time.Second)
+	if w.Count() != 0 || !math.IsNaN(w.Quantile(0.5)) {
+		t.Errorf("Expected every sample to expire, got %d samples", w.Count())
+	}
+
+	clock.Advance(time.Hour)
+	assertNoError(t, w.Add(1))
+	if w.Count() != 1 {
+		t.Errorf("Expected a single sample after a long pause, got %d", w.Count())
+	}
+
+	for _, c := range []struct{ window, bucket time.Duration }{{time.Minute, 0}, {time.Second, time.Minute}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for a window of %v in buckets of %v", c.window, c.bucket)
+				}
+			}()
+			NewWindowedTDigest(100, c.window, c.bucket, clock.Now)
+		}()
+	}
+}
+
+func TestWindowedTDigestMerge(t *testing.T) {
+	early := &fakeClock{now: time.Unix(1000, 0)}
+	late := &fakeClock{now: time.Unix(1030, 0)}
+	a := NewWindowedTDigest(100, time.Minute, 10*time.Second, early.Now, WithCentroidVariance())
+	b := NewWindowedTDigest(100, time.Minute, 10*time.Second, late.Now, WithCentroidVariance())
+
+	// a sample per second in a and every other second in b
+	for i := 0; i < 60; i++ {
+		assertNoError(t, a.Add(1))
+		if i%2 == 0 {
+			assertNoError(t, b.Add(2))
+		}
+		early.Advance(time.Second)
+		late.Advance(time.Second)
+	}
+
+	// the windows hold the buckets from 1040 on by the time of b, with 20
+	// samples of a and 25 of b
+	assertNoError(t, a.Merge(b))
+	if a.Count() != 45 || b.Count() != 25 {
+		t.Errorf("Got %d samples after merging, and %d in the merged window, expected 45 and 25", a.Count(), b.Count())
+	}
+	assertNear(t, "CDF(1.5)", a.CDF(1.5), 20.0/45, 1e-9)
+	if !a.Digest().variance {
+		t.Errorf("Expected the buckets to keep the options")
+	}
+
+	assertNoError(t, a.Merge(a))
+	if a.Count() != 90 {
+		t.Errorf("Got %d samples after merging a window into itself, expected 90", a.Count())
+	}
+
+	for _, other := range []*WindowedTDigest{
+		NewWindowedTDigest(100, 2*time.Minute, 10*time.Second, late.Now),
+		NewWindowedTDigest(100, time.Minute, 20*time.Second, late.Now),
+	} {
+		if err := a.Merge(other); err == nil {
+			t.Errorf("Expected an error merging a window of %v in buckets of %v", other.Window(), other.Bucket())
+		}
+	}
+}